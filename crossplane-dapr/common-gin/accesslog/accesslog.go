@@ -0,0 +1,270 @@
+// Package accesslog writes one structured slog record per HTTP request for the
+// Gin services, replacing gin.Logger. Successful probe and scrape requests are
+// sampled, and configured headers and JSON body fields are redacted before
+// they reach the log.
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Redacted replaces the value of a redacted header or body field.
+const Redacted = "[REDACTED]"
+
+const defaultMaxBodyBytes = 4096
+
+var (
+	defaultProbePaths    = []string{"/health", "/metrics"}
+	defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Dapr-Api-Token"}
+	defaultRedactFields  = []string{"password", "secret", "token", "cardNumber", "cvv"}
+)
+
+// Config controls what the access log records. Nil lists and a zero
+// MaxBodyBytes take the defaults.
+type Config struct {
+	// ProbeSampleRate is the share of 2xx requests to ProbePaths that are
+	// logged, from 0 (none) to 1 (all). Other requests are always logged.
+	ProbeSampleRate float64
+	// ProbePaths are the paths, and their sub-paths, of probe and scrape
+	// endpoints.
+	ProbePaths []string
+	// Headers are the request headers to record.
+	Headers []string
+	// RedactHeaders are recorded as Redacted instead of their value.
+	RedactHeaders []string
+	// Body records JSON request bodies up to MaxBodyBytes.
+	Body         bool
+	MaxBodyBytes int
+	// RedactFields are JSON object keys, at any depth, whose values are
+	// replaced with Redacted. Matching is case-insensitive.
+	RedactFields []string
+}
+
+// LoadConfigFromEnv reads the ACCESS_LOG_* environment variables.
+func LoadConfigFromEnv() Config {
+	cfg := Config{
+		ProbeSampleRate: 0.01,
+		Headers:         envList("ACCESS_LOG_HEADERS"),
+		RedactHeaders:   envList("ACCESS_LOG_REDACT_HEADERS"),
+		RedactFields:    envList("ACCESS_LOG_REDACT_FIELDS"),
+		ProbePaths:      envList("ACCESS_LOG_PROBE_PATHS"),
+	}
+	if value, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("ACCESS_LOG_PROBE_SAMPLE_RATE")), 64); err == nil {
+		cfg.ProbeSampleRate = value
+	}
+	if value, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("ACCESS_LOG_BODY"))); err == nil {
+		cfg.Body = value
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv("ACCESS_LOG_MAX_BODY_BYTES"))); err == nil {
+		cfg.MaxBodyBytes = value
+	}
+	return cfg
+}
+
+func envList(key string) []string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Entry describes one finished request.
+type Entry struct {
+	Request  *http.Request
+	Route    string
+	Status   int
+	Latency  time.Duration
+	Bytes    int
+	ClientIP string
+	// Body is what CaptureBody returned for the request.
+	Body []byte
+}
+
+// Logger samples, redacts and writes access records. It is safe for
+// concurrent use.
+type Logger struct {
+	sampleRate    float64
+	probePaths    []string
+	headers       []string
+	redactHeaders map[string]bool
+	body          bool
+	maxBodyBytes  int
+	redactFields  map[string]bool
+	probes        atomic.Uint64
+}
+
+func New(cfg Config) *Logger {
+	l := &Logger{
+		sampleRate:    min(max(cfg.ProbeSampleRate, 0), 1),
+		probePaths:    cfg.ProbePaths,
+		headers:       cfg.Headers,
+		redactHeaders: map[string]bool{},
+		body:          cfg.Body,
+		maxBodyBytes:  cfg.MaxBodyBytes,
+		redactFields:  map[string]bool{},
+	}
+	if l.probePaths == nil {
+		l.probePaths = defaultProbePaths
+	}
+	if l.maxBodyBytes <= 0 {
+		l.maxBodyBytes = defaultMaxBodyBytes
+	}
+	redactHeaders := cfg.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = defaultRedactHeaders
+	}
+	for _, header := range redactHeaders {
+		l.redactHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+	redactFields := cfg.RedactFields
+	if redactFields == nil {
+		redactFields = defaultRedactFields
+	}
+	for _, field := range redactFields {
+		l.redactFields[strings.ToLower(field)] = true
+	}
+	return l
+}
+
+// CaptureBody returns up to MaxBodyBytes of the request body when bodies are
+// recorded, leaving r.Body readable from the start for the handler.
+func (l *Logger) CaptureBody(r *http.Request) []byte {
+	if !l.body || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	captured, err := io.ReadAll(io.LimitReader(r.Body, int64(l.maxBodyBytes)+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(captured), r.Body), Closer: r.Body}
+	if err != nil {
+		return nil
+	}
+	return captured
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Log writes entry to logger unless sampling drops it. Server errors are
+// logged at error level and client errors at warn level.
+func (l *Logger) Log(ctx context.Context, logger *slog.Logger, entry Entry) {
+	if !l.sampled(entry) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Int("status", entry.Status),
+		slog.Float64("latencyMs", float64(entry.Latency.Microseconds())/1000),
+		slog.Int("bytes", max(entry.Bytes, 0)),
+		slog.String("clientIp", entry.ClientIP),
+		slog.String("route", entry.Route),
+	}
+	if entry.Request.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("requestBytes", entry.Request.ContentLength))
+	}
+	if headers := l.headerAttrs(entry.Request.Header); len(headers) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "headers", Value: slog.GroupValue(headers...)})
+	}
+	if body, ok := l.redactBody(entry.Body); ok {
+		attrs = append(attrs, slog.String("body", body))
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case entry.Status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case entry.Status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(ctx, level, "http request", attrs...)
+}
+
+// sampled keeps every request except 2xx probe calls, of which it keeps
+// ProbeSampleRate evenly spread.
+func (l *Logger) sampled(entry Entry) bool {
+	if entry.Status < 200 || entry.Status > 299 || !l.isProbe(entry.Request.URL.Path) {
+		return true
+	}
+	n := float64(l.probes.Add(1))
+	return math.Floor(n*l.sampleRate) > math.Floor((n-1)*l.sampleRate)
+}
+
+func (l *Logger) isProbe(path string) bool {
+	for _, probe := range l.probePaths {
+		if path == probe || strings.HasPrefix(path, strings.TrimSuffix(probe, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Logger) headerAttrs(header http.Header) []slog.Attr {
+	var attrs []slog.Attr
+	for _, name := range l.headers {
+		name = http.CanonicalHeaderKey(name)
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if l.redactHeaders[name] {
+			value = Redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return attrs
+}
+
+// redactBody returns body as compact JSON with the redacted fields replaced.
+// Bodies that are not JSON or exceed MaxBodyBytes are not recorded.
+func (l *Logger) redactBody(body []byte) (string, bool) {
+	if len(body) == 0 || len(body) > l.maxBodyBytes {
+		return "", false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return "", false
+	}
+	redacted, err := json.Marshal(l.redact(value))
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+func (l *Logger) redact(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, field := range typed {
+			if l.redactFields[strings.ToLower(key)] {
+				typed[key] = Redacted
+				continue
+			}
+			typed[key] = l.redact(field)
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = l.redact(item)
+		}
+	}
+	return value
+}
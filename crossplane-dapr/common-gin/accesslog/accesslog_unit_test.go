@@ -0,0 +1,160 @@
+//go:build !integration && !contract && !e2e
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture returns a JSON logger and a function that decodes what it wrote.
+func capture(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return logger, func() []map[string]any {
+		t.Helper()
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestLogRecordsTheRequest(t *testing.T) {
+	t.Parallel()
+
+	logger, records := capture(t)
+	accessLog := New(Config{})
+	req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{"id":"ORD-1"}`))
+	accessLog.Log(context.Background(), logger, Entry{
+		Request:  req,
+		Route:    "/publish",
+		Status:   http.StatusAccepted,
+		Latency:  1500 * time.Microsecond,
+		Bytes:    42,
+		ClientIP: "10.0.0.7",
+	})
+	accessLog.Log(context.Background(), logger, Entry{Request: httptest.NewRequest(http.MethodGet, "/orders/ORD-9", nil), Route: "/orders/:id", Status: http.StatusNotFound, Bytes: -1})
+	accessLog.Log(context.Background(), logger, Entry{Request: httptest.NewRequest(http.MethodPost, "/orders", nil), Route: "/orders", Status: http.StatusInternalServerError})
+
+	got := records()
+	if len(got) != 3 {
+		t.Fatalf("logged %d records, want 3", len(got))
+	}
+	first := got[0]
+	if first["msg"] != "http request" || first["level"] != "INFO" || first["status"] != 202.0 || first["latencyMs"] != 1.5 ||
+		first["bytes"] != 42.0 || first["clientIp"] != "10.0.0.7" || first["route"] != "/publish" || first["requestBytes"] != 14.0 {
+		t.Fatalf("unexpected record %v", first)
+	}
+	if _, ok := first["body"]; ok {
+		t.Fatalf("body recorded without Config.Body: %v", first)
+	}
+	if got[1]["level"] != "WARN" || got[1]["bytes"] != 0.0 || got[1]["route"] != "/orders/:id" {
+		t.Fatalf("unexpected client error record %v", got[1])
+	}
+	if got[2]["level"] != "ERROR" {
+		t.Fatalf("unexpected server error record %v", got[2])
+	}
+}
+
+func TestLogSamplesSuccessfulProbes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rate float64
+		want int
+	}{
+		{name: "none", rate: 0, want: 0},
+		{name: "quarter", rate: 0.25, want: 25},
+		{name: "all", rate: 1, want: 100},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, records := capture(t)
+			accessLog := New(Config{ProbeSampleRate: tc.rate})
+			for range 100 {
+				for _, path := range []string{"/health/ready", "/metrics"} {
+					accessLog.Log(context.Background(), logger, Entry{Request: httptest.NewRequest(http.MethodGet, path, nil), Route: path, Status: http.StatusOK})
+				}
+			}
+			// Failing probes and other routes are never sampled away.
+			accessLog.Log(context.Background(), logger, Entry{Request: httptest.NewRequest(http.MethodGet, "/health/ready", nil), Status: http.StatusServiceUnavailable})
+			accessLog.Log(context.Background(), logger, Entry{Request: httptest.NewRequest(http.MethodGet, "/healthz", nil), Status: http.StatusOK})
+
+			if got := len(records()); got != 2*tc.want+2 {
+				t.Fatalf("logged %d records, want %d", got, 2*tc.want+2)
+			}
+		})
+	}
+}
+
+func TestLogRedactsHeadersAndBodyFields(t *testing.T) {
+	t.Parallel()
+
+	logger, records := capture(t)
+	accessLog := New(Config{
+		Headers:      []string{"authorization", "User-Agent", "X-Missing"},
+		Body:         true,
+		RedactFields: []string{"cardNumber", "password"},
+	})
+	body := `{"id":"ORD-1","amount":10.10,"payment":{"CardNumber":"4111111111111111"},"users":[{"password":"hunter2","name":"ana"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("User-Agent", "curl/8")
+
+	captured := accessLog.CaptureBody(req)
+	if rest, _ := io.ReadAll(req.Body); string(rest) != body {
+		t.Fatalf("handler read %q after capture, want the whole body", rest)
+	}
+	accessLog.Log(context.Background(), logger, Entry{Request: req, Status: http.StatusAccepted, Body: captured})
+
+	record := records()[0]
+	headers, _ := record["headers"].(map[string]any)
+	if len(headers) != 2 || headers["Authorization"] != Redacted || headers["User-Agent"] != "curl/8" {
+		t.Fatalf("headers = %v, want Authorization redacted and User-Agent kept", record["headers"])
+	}
+	want := `{"amount":10.10,"id":"ORD-1","payment":{"CardNumber":"[REDACTED]"},"users":[{"name":"ana","password":"[REDACTED]"}]}`
+	if record["body"] != want {
+		t.Fatalf("body = %v, want %s", record["body"], want)
+	}
+}
+
+func TestCaptureBodySkipsBodiesItCannotRedact(t *testing.T) {
+	t.Parallel()
+
+	logger, records := capture(t)
+	accessLog := New(Config{Body: true, MaxBodyBytes: 16})
+	for _, body := range []string{`{"id":"ORD-1","amount":10}`, `not json`} {
+		req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(body))
+		captured := accessLog.CaptureBody(req)
+		if rest, _ := io.ReadAll(req.Body); string(rest) != body {
+			t.Fatalf("handler read %q after capture, want %q", rest, body)
+		}
+		accessLog.Log(context.Background(), logger, Entry{Request: req, Status: http.StatusAccepted, Body: captured})
+	}
+	for _, record := range records() {
+		if _, ok := record["body"]; ok {
+			t.Fatalf("recorded a body that is too large or not JSON: %v", record)
+		}
+	}
+}
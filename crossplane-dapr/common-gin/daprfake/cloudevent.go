@@ -0,0 +1,80 @@
+package daprfake
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"time"
+)
+
+const (
+	cloudEventsContentType = "application/cloudevents+json"
+	daprEventType          = "com.dapr.event.sent"
+)
+
+// envelope wraps a published payload in a CloudEvent the way daprd does;
+// raw payloads are delivered untouched.
+func (s *Sidecar) envelope(pubsub, topic, contentType string, body []byte, raw bool, traceparent string) (Message, error) {
+	message := Message{PubSubName: pubsub, Topic: topic, ContentType: contentType, traceparent: traceparent}
+	if message.ContentType == "" {
+		message.ContentType = "application/json"
+	}
+	if raw {
+		message.Body = body
+		return message, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(message.ContentType)
+	var event map[string]any
+	isJSON := json.Unmarshal(body, &event) == nil
+	switch {
+	case mediaType == cloudEventsContentType && !isJSON:
+		return Message{}, fmt.Errorf("payload is not a JSON CloudEvent")
+	case isJSON && (mediaType == cloudEventsContentType || event["specversion"] != nil):
+		if id, _ := event["id"].(string); id == "" {
+			event["id"] = newID()
+		}
+	default:
+		event = map[string]any{
+			"specversion":     "1.0",
+			"id":              newID(),
+			"source":          s.appID,
+			"type":            daprEventType,
+			"time":            time.Now().UTC().Format(time.RFC3339Nano),
+			"datacontenttype": message.ContentType,
+			"data":            data(mediaType, body),
+		}
+	}
+	event["topic"] = topic
+	event["pubsubname"] = pubsub
+	if value, _ := event["traceparent"].(string); value != "" {
+		message.traceparent = value
+	} else if traceparent != "" {
+		event["traceparent"] = traceparent
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("encode cloud event: %w", err)
+	}
+	message.Body, message.ContentType = encoded, cloudEventsContentType
+	return message, nil
+}
+
+// data embeds JSON payloads as JSON and anything else as a string.
+func data(mediaType string, body []byte) any {
+	if (mediaType == "application/json" || mediaType == "") && json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
+
+// newID returns a random UUID, as daprd uses for CloudEvent ids.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
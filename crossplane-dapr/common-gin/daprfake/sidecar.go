@@ -0,0 +1,480 @@
+// Package daprfake is an in-process stand-in for the daprd sidecar in Go
+// tests. It serves the publish, health and metadata APIs the services call,
+// reads /dapr/subscribe from subscribing apps and pushes every published
+// message to the matching subscription routes, with Dapr's redelivery and
+// dead-letter behaviour. Apps are plain http.Handlers, so a test can wire a
+// producer router and a consumer router together without a network.
+package daprfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is a Dapr subscription status.
+type Status string
+
+const (
+	StatusSuccess Status = "SUCCESS"
+	StatusRetry   Status = "RETRY"
+	StatusDrop    Status = "DROP"
+)
+
+const (
+	defaultAppID         = "daprfake"
+	defaultMaxAttempts   = 3
+	defaultRetryInterval = 10 * time.Millisecond
+)
+
+// Message is a message accepted by the publish API, as delivered to
+// subscribers.
+type Message struct {
+	PubSubName  string
+	Topic       string
+	ContentType string
+	Body        []byte
+
+	traceparent string
+}
+
+// Delivery is one attempt to push a Message to a subscription route.
+type Delivery struct {
+	PubSubName string
+	Topic      string
+	Route      string
+	Attempt    int
+	Status     Status
+	Body       []byte
+}
+
+// Option customises a Sidecar.
+type Option func(*Sidecar)
+
+// WithAppID sets the source of the CloudEvents the sidecar wraps payloads
+// in, which Dapr takes from the publishing app id.
+func WithAppID(appID string) Option {
+	return func(s *Sidecar) {
+		if appID != "" {
+			s.appID = appID
+		}
+	}
+}
+
+// WithComponents declares the components the sidecar reports on
+// /v1.0/metadata. When a pubsub component is declared, publishing to any
+// other pubsub fails with 404 as it does in daprd.
+func WithComponents(components ...Component) Option {
+	return func(s *Sidecar) {
+		s.components = append(s.components, components...)
+	}
+}
+
+// WithMaxAttempts sets how many times a message answered with RETRY, or
+// failing with an error status, is delivered before it is given up on.
+func WithMaxAttempts(attempts int) Option {
+	return func(s *Sidecar) {
+		if attempts > 0 {
+			s.maxAttempts = attempts
+		}
+	}
+}
+
+// WithRetryInterval sets the wait between delivery attempts.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(s *Sidecar) {
+		if interval >= 0 {
+			s.retryInterval = interval
+		}
+	}
+}
+
+// Component is a Dapr component loaded by the sidecar, such as
+// {Name: "order-pubsub", Type: "pubsub.in-memory"}.
+type Component struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+type subscriber struct {
+	app          http.Handler
+	subscription Subscription
+}
+
+// Sidecar emulates daprd. It is an http.Handler serving the publish APIs
+// and an HTTPDoer, so producers can use it as their HTTP client. Messages
+// are delivered asynchronously; WaitIdle waits for them.
+type Sidecar struct {
+	appID         string
+	maxAttempts   int
+	retryInterval time.Duration
+	components    []Component
+	mux           *http.ServeMux
+
+	mu          sync.Mutex
+	unhealthy   bool
+	subscribers []subscriber
+	published   []Message
+	deliveries  []Delivery
+	pending     int
+	idle        chan struct{}
+}
+
+func New(opts ...Option) *Sidecar {
+	s := &Sidecar{
+		appID:         defaultAppID,
+		maxAttempts:   defaultMaxAttempts,
+		retryInterval: defaultRetryInterval,
+		mux:           http.NewServeMux(),
+		idle:          make(chan struct{}),
+	}
+	close(s.idle)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	s.mux.HandleFunc("POST /v1.0/publish/{pubsub}/{topic}", s.publish)
+	s.mux.HandleFunc("POST /v1.0-alpha1/publish/bulk/{pubsub}/{topic}", s.publishBulk)
+	s.mux.HandleFunc("GET /v1.0/healthz/outbound", s.healthz)
+	s.mux.HandleFunc("GET /v1.0/metadata", s.metadata)
+	return s
+}
+
+// SetHealthy makes the sidecar report itself healthy or not on
+// /v1.0/healthz/outbound.
+func (s *Sidecar) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unhealthy = !healthy
+}
+
+func (s *Sidecar) healthz(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	unhealthy := s.unhealthy
+	s.mu.Unlock()
+	if unhealthy {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// metadata reports the declared components and the registered
+// subscriptions.
+func (s *Sidecar) metadata(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	subscriptions := make([]Subscription, 0, len(s.subscribers))
+	for _, sub := range s.subscribers {
+		subscriptions = append(subscriptions, sub.subscription)
+	}
+	s.mu.Unlock()
+	components := append([]Component{}, s.components...)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":            s.appID,
+		"components":    components,
+		"subscriptions": subscriptions,
+	})
+}
+
+// knownPubSub reports whether publishing to pubsub is allowed: any name when
+// no pubsub component is declared, otherwise only declared ones.
+func (s *Sidecar) knownPubSub(pubsub string) bool {
+	declared := false
+	for _, component := range s.components {
+		if strings.HasPrefix(component.Type, "pubsub.") {
+			declared = true
+			if component.Name == pubsub {
+				return true
+			}
+		}
+	}
+	return !declared
+}
+
+func (s *Sidecar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Do serves req in process, whatever its host.
+func (s *Sidecar) Do(req *http.Request) (*http.Response, error) {
+	res := httptest.NewRecorder()
+	s.ServeHTTP(res, req)
+	return res.Result(), nil
+}
+
+// Subscribe reads the subscriptions app serves on /dapr/subscribe and
+// delivers matching messages published from now on to it.
+func (s *Sidecar) Subscribe(app http.Handler) error {
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		return fmt.Errorf("GET /dapr/subscribe answered %d", res.Code)
+	}
+	var subscriptions []Subscription
+	if err := json.Unmarshal(res.Body.Bytes(), &subscriptions); err != nil {
+		return fmt.Errorf("decode subscriptions: %w", err)
+	}
+	for i, subscription := range subscriptions {
+		if err := subscription.validate(); err != nil {
+			return fmt.Errorf("subscription %d: %w", i, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subscription := range subscriptions {
+		s.subscribers = append(s.subscribers, subscriber{app: app, subscription: subscription})
+	}
+	return nil
+}
+
+// Published returns the messages accepted so far, dead-lettered ones
+// included.
+func (s *Sidecar) Published() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.published...)
+}
+
+// Deliveries returns every delivery attempt so far, in the order they were
+// answered.
+func (s *Sidecar) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+// WaitIdle waits until every published message has been delivered, given
+// up on or dead-lettered, or ctx is done.
+func (s *Sidecar) WaitIdle(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		idle := s.idle
+		s.mu.Unlock()
+		select {
+		case <-idle:
+			s.mu.Lock()
+			done := s.pending == 0
+			s.mu.Unlock()
+			if done {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("wait for deliveries: %w", ctx.Err())
+		}
+	}
+}
+
+func (s *Sidecar) publish(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ERR_PUBSUB_EVENTS_SER", err)
+		return
+	}
+	pubsub, topic := r.PathValue("pubsub"), r.PathValue("topic")
+	if !s.knownPubSub(pubsub) {
+		writeError(w, http.StatusNotFound, "ERR_PUBSUB_NOT_FOUND", fmt.Errorf("pubsub %s not found", pubsub))
+		return
+	}
+	raw := r.URL.Query().Get("metadata.rawPayload") == "true"
+	message, err := s.envelope(pubsub, topic, r.Header.Get("Content-Type"), body, raw, r.Header.Get("traceparent"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ERR_PUBSUB_CLOUD_EVENTS_SER", err)
+		return
+	}
+	s.enqueue(message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkPublishEntry is one entry of a bulk publish request body.
+type bulkPublishEntry struct {
+	EntryID     string          `json:"entryId"`
+	Event       json.RawMessage `json:"event"`
+	ContentType string          `json:"contentType"`
+}
+
+func (s *Sidecar) publishBulk(w http.ResponseWriter, r *http.Request) {
+	var entries []bulkPublishEntry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		writeError(w, http.StatusBadRequest, "ERR_PUBSUB_EVENTS_SER", err)
+		return
+	}
+	pubsub, topic := r.PathValue("pubsub"), r.PathValue("topic")
+	if !s.knownPubSub(pubsub) {
+		writeError(w, http.StatusNotFound, "ERR_PUBSUB_NOT_FOUND", fmt.Errorf("pubsub %s not found", pubsub))
+		return
+	}
+	raw := r.URL.Query().Get("metadata.rawPayload") == "true"
+	messages := make([]Message, 0, len(entries))
+	for _, entry := range entries {
+		event := []byte(entry.Event)
+		// Non-JSON events travel as JSON strings.
+		var text string
+		if json.Unmarshal(entry.Event, &text) == nil {
+			event = []byte(text)
+		}
+		message, err := s.envelope(pubsub, topic, entry.ContentType, event, raw, r.Header.Get("traceparent"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "ERR_PUBSUB_CLOUD_EVENTS_SER", fmt.Errorf("entry %s: %w", entry.EntryID, err))
+			return
+		}
+		messages = append(messages, message)
+	}
+	for _, message := range messages {
+		s.enqueue(message)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// enqueue records message and starts one delivery per matching
+// subscription.
+func (s *Sidecar) enqueue(message Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = append(s.published, message)
+	for _, sub := range s.subscribers {
+		if sub.subscription.PubSubName != message.PubSubName || sub.subscription.Topic != message.Topic {
+			continue
+		}
+		if s.pending == 0 {
+			s.idle = make(chan struct{})
+		}
+		s.pending++
+		go s.deliver(sub, message)
+	}
+}
+
+// deliver pushes message to sub until it is accepted, dropped or out of
+// attempts, then dead-letters it when the subscription has a dead-letter topic.
+func (s *Sidecar) deliver(sub subscriber, message Message) {
+	defer s.done()
+
+	route, ok := sub.subscription.route(message)
+	if !ok {
+		s.record(Delivery{PubSubName: message.PubSubName, Topic: message.Topic, Attempt: 1, Status: StatusDrop, Body: message.Body})
+		return
+	}
+	status := StatusRetry
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(s.retryInterval)
+		}
+		status = s.push(sub, route, message)
+		s.record(Delivery{PubSubName: message.PubSubName, Topic: message.Topic, Route: route, Attempt: attempt, Status: status, Body: message.Body})
+		if status != StatusRetry {
+			break
+		}
+	}
+	if status != StatusSuccess && sub.subscription.DeadLetterTopic != "" {
+		deadLetter := message
+		deadLetter.Topic = sub.subscription.DeadLetterTopic
+		s.enqueue(deadLetter)
+	}
+}
+
+func (s *Sidecar) record(delivery Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, delivery)
+}
+
+func (s *Sidecar) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	if s.pending == 0 {
+		close(s.idle)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, errorCode string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"errorCode": errorCode, "message": err.Error()})
+}
+
+// push makes one delivery attempt: 404 drops, other errors retry, and a 2xx
+// without a status succeeds, as in daprd.
+func (s *Sidecar) push(sub subscriber, route string, message Message) Status {
+	body, contentType := message.Body, message.ContentType
+	bulk := sub.subscription.BulkSubscribe != nil && sub.subscription.BulkSubscribe.Enabled
+	if bulk {
+		body, contentType = bulkSubscribeBody(message), "application/json"
+	}
+	req := httptest.NewRequest(http.MethodPost, route, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if message.traceparent != "" {
+		req.Header.Set("traceparent", message.traceparent)
+	}
+	res := httptest.NewRecorder()
+	sub.app.ServeHTTP(res, req)
+
+	switch {
+	case res.Code == http.StatusNotFound:
+		return StatusDrop
+	case res.Code < 200 || res.Code > 299:
+		return StatusRetry
+	}
+	if bulk {
+		var answer struct {
+			Statuses []struct {
+				Status Status `json:"status"`
+			} `json:"statuses"`
+		}
+		if err := json.Unmarshal(res.Body.Bytes(), &answer); err != nil || len(answer.Statuses) != 1 {
+			return StatusRetry
+		}
+		return normalize(answer.Statuses[0].Status)
+	}
+	var answer struct {
+		Status Status `json:"status"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &answer); err != nil {
+		return StatusSuccess
+	}
+	return normalize(answer.Status)
+}
+
+// normalize treats a missing status as SUCCESS and an unknown one as an
+// error to retry.
+func normalize(status Status) Status {
+	switch status {
+	case "", StatusSuccess:
+		return StatusSuccess
+	case StatusDrop:
+		return StatusDrop
+	default:
+		return StatusRetry
+	}
+}
+
+// bulkSubscribeBody frames message as a one-entry bulk subscribe request.
+func bulkSubscribeBody(message Message) []byte {
+	event := json.RawMessage(message.Body)
+	if !json.Valid(message.Body) {
+		event, _ = json.Marshal(string(message.Body))
+	}
+	body, _ := json.Marshal(map[string]any{
+		"id":         newID(),
+		"pubsubname": message.PubSubName,
+		"topic":      message.Topic,
+		"entries": []map[string]any{{
+			"entryId":     "1",
+			"event":       event,
+			"contentType": message.ContentType,
+		}},
+	})
+	return body
+}
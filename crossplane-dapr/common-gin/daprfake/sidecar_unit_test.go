@@ -0,0 +1,345 @@
+//go:build !integration && !contract && !e2e
+
+package daprfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testApp is a subscriber that serves subscriptions and answers each
+// delivery on a path with the next scripted status.
+type testApp struct {
+	*http.ServeMux
+	mu       sync.Mutex
+	requests map[string][]*http.Request
+	bodies   map[string][]string
+}
+
+func newTestApp(t *testing.T, subscriptions []Subscription, answers map[string][]string) *testApp {
+	t.Helper()
+	app := &testApp{ServeMux: http.NewServeMux(), requests: map[string][]*http.Request{}, bodies: map[string][]string{}}
+	app.HandleFunc("GET /dapr/subscribe", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(subscriptions)
+	})
+	for path := range answers {
+		app.HandleFunc("POST "+path, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			app.mu.Lock()
+			attempt := len(app.requests[path])
+			app.requests[path] = append(app.requests[path], r)
+			app.bodies[path] = append(app.bodies[path], string(body))
+			app.mu.Unlock()
+
+			answer := answers[path][min(attempt, len(answers[path])-1)]
+			switch answer {
+			case "404":
+				w.WriteHeader(http.StatusNotFound)
+			case "500":
+				w.WriteHeader(http.StatusInternalServerError)
+			case "":
+				w.WriteHeader(http.StatusOK)
+			default:
+				_, _ = io.WriteString(w, answer)
+			}
+		})
+	}
+	return app
+}
+
+func (a *testApp) received(path string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.bodies[path]...)
+}
+
+func publish(t *testing.T, sidecar *Sidecar, path, contentType, body string, header http.Header) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:3500"+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := sidecar.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	if res.StatusCode != http.StatusNoContent {
+		answer, _ := io.ReadAll(res.Body)
+		t.Fatalf("POST %s = %d %s, want 204", path, res.StatusCode, answer)
+	}
+}
+
+func waitIdle(t *testing.T, sidecar *Sidecar) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sidecar.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeEvent(t *testing.T, body string) map[string]any {
+	t.Helper()
+	var event map[string]any
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		t.Fatalf("decode delivered event %s: %v", body, err)
+	}
+	return event
+}
+
+func TestSidecarWrapsPayloadsInCloudEvents(t *testing.T) {
+	t.Parallel()
+
+	sidecar := New(WithAppID("producer-gin"))
+	app := newTestApp(t, []Subscription{{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders"}}, map[string][]string{"/orders": {`{"status":"SUCCESS"}`}})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/orders", "application/json", `{"id":"ORD-1"}`, header)
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/orders", "application/cloudevents+json",
+		`{"specversion":"1.0","id":"evt-2","source":"checkout","type":"com.agnostic.order.created.v1","data":{"id":"ORD-2"}}`, nil)
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/other", "application/json", `{"id":"ORD-3"}`, nil)
+	waitIdle(t, sidecar)
+
+	received := app.received("/orders")
+	if len(received) != 2 {
+		t.Fatalf("received %d deliveries, want 2: %v", len(received), received)
+	}
+	byID := map[string]map[string]any{}
+	for _, body := range received {
+		event := decodeEvent(t, body)
+		data, _ := event["data"].(map[string]any)
+		byID[data["id"].(string)] = event
+	}
+	wrapped := byID["ORD-1"]
+	if wrapped["specversion"] != "1.0" || wrapped["source"] != "producer-gin" || wrapped["type"] != daprEventType ||
+		wrapped["topic"] != "orders" || wrapped["pubsubname"] != "order-pubsub" || wrapped["id"] == "" ||
+		wrapped["traceparent"] != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("unexpected wrapped event: %v", wrapped)
+	}
+	structured := byID["ORD-2"]
+	if structured["id"] != "evt-2" || structured["source"] != "checkout" || structured["topic"] != "orders" {
+		t.Fatalf("unexpected structured event: %v", structured)
+	}
+	for _, req := range app.requests["/orders"] {
+		if req.Header.Get("Content-Type") != cloudEventsContentType {
+			t.Fatalf("content type = %q, want %s", req.Header.Get("Content-Type"), cloudEventsContentType)
+		}
+	}
+	if got := len(sidecar.Published()); got != 3 {
+		t.Fatalf("published %d messages, want 3", got)
+	}
+}
+
+func TestSidecarDeliversRawPayloadsUntouched(t *testing.T) {
+	t.Parallel()
+
+	sidecar := New()
+	app := newTestApp(t, []Subscription{{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders"}}, map[string][]string{"/orders": {""}})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/orders?metadata.rawPayload=true", "application/json", `{"id":"ORD-1"}`, nil)
+	waitIdle(t, sidecar)
+
+	if received := app.received("/orders"); len(received) != 1 || received[0] != `{"id":"ORD-1"}` {
+		t.Fatalf("received %v, want the raw payload", received)
+	}
+	if deliveries := sidecar.Deliveries(); deliveries[0].Status != StatusSuccess {
+		t.Fatalf("empty 200 answer status = %s, want SUCCESS", deliveries[0].Status)
+	}
+}
+
+func TestSidecarHonorsSubscriptionStatuses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		answers      []string
+		wantStatuses []Status
+		deadLettered bool
+	}{
+		{name: "success", answers: []string{`{"status":"SUCCESS"}`}, wantStatuses: []Status{StatusSuccess}},
+		{name: "retry then success", answers: []string{`{"status":"RETRY"}`, "500", `{"status":"SUCCESS"}`}, wantStatuses: []Status{StatusRetry, StatusRetry, StatusSuccess}},
+		{name: "retries exhausted", answers: []string{`{"status":"RETRY"}`}, wantStatuses: []Status{StatusRetry, StatusRetry, StatusRetry}, deadLettered: true},
+		{name: "drop", answers: []string{`{"status":"DROP"}`}, wantStatuses: []Status{StatusDrop}, deadLettered: true},
+		{name: "not found", answers: []string{"404"}, wantStatuses: []Status{StatusDrop}, deadLettered: true},
+		{name: "unknown status", answers: []string{`{"status":"MAYBE"}`, `{"status":"SUCCESS"}`}, wantStatuses: []Status{StatusRetry, StatusSuccess}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sidecar := New(WithRetryInterval(time.Millisecond))
+			subscriptions := []Subscription{
+				{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders", DeadLetterTopic: "orders-dead-letter"},
+				{PubSubName: "order-pubsub", Topic: "orders-dead-letter", Route: "/orders/dead-letter"},
+			}
+			app := newTestApp(t, subscriptions, map[string][]string{"/orders": tc.answers, "/orders/dead-letter": {""}})
+			if err := sidecar.Subscribe(app); err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+			publish(t, sidecar, "/v1.0/publish/order-pubsub/orders", "application/json", `{"id":"ORD-1"}`, nil)
+			waitIdle(t, sidecar)
+
+			var statuses []Status
+			for i, delivery := range sidecar.Deliveries() {
+				if delivery.Topic != "orders" {
+					continue
+				}
+				if delivery.Attempt != len(statuses)+1 {
+					t.Fatalf("delivery %d attempt = %d", i, delivery.Attempt)
+				}
+				statuses = append(statuses, delivery.Status)
+			}
+			if len(statuses) != len(tc.wantStatuses) {
+				t.Fatalf("statuses = %v, want %v", statuses, tc.wantStatuses)
+			}
+			for i := range statuses {
+				if statuses[i] != tc.wantStatuses[i] {
+					t.Fatalf("statuses = %v, want %v", statuses, tc.wantStatuses)
+				}
+			}
+			deadLettered := app.received("/orders/dead-letter")
+			if (len(deadLettered) == 1) != tc.deadLettered {
+				t.Fatalf("dead-lettered deliveries = %v, want dead-lettered %v", deadLettered, tc.deadLettered)
+			}
+			if tc.deadLettered {
+				if event := decodeEvent(t, deadLettered[0]); event["topic"] != "orders" {
+					t.Fatalf("dead-lettered event topic = %v, want the original topic", event["topic"])
+				}
+			}
+		})
+	}
+}
+
+func TestSidecarAppliesRoutingRules(t *testing.T) {
+	t.Parallel()
+
+	sidecar := New()
+	subscriptions := []Subscription{{
+		PubSubName: "order-pubsub",
+		Topic:      "orders",
+		Routes: &Routes{
+			Rules:   []Rule{{Match: `event.type == "com.agnostic.order.created.v2"`, Path: "/orders/v2"}},
+			Default: "/orders",
+		},
+	}}
+	app := newTestApp(t, subscriptions, map[string][]string{"/orders": {""}, "/orders/v2": {""}})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/orders", "application/cloudevents+json",
+		`{"specversion":"1.0","id":"evt-1","source":"producer-gin","type":"com.agnostic.order.created.v2","data":{}}`, nil)
+	publish(t, sidecar, "/v1.0/publish/order-pubsub/orders", "application/json", `{"id":"ORD-2"}`, nil)
+	waitIdle(t, sidecar)
+
+	if got := app.received("/orders/v2"); len(got) != 1 || !strings.Contains(got[0], `"evt-1"`) {
+		t.Fatalf("v2 route received %v", got)
+	}
+	if got := app.received("/orders"); len(got) != 1 || !strings.Contains(got[0], `"ORD-2"`) {
+		t.Fatalf("default route received %v", got)
+	}
+}
+
+func TestSidecarRejectsUnsupportedSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	for _, subscription := range []Subscription{
+		{PubSubName: "order-pubsub", Topic: "orders"},
+		{Topic: "orders", Route: "/orders"},
+		{PubSubName: "order-pubsub", Topic: "orders", Routes: &Routes{Rules: []Rule{{Match: `event.data.amount > 10`, Path: "/big"}}}},
+	} {
+		app := newTestApp(t, []Subscription{subscription}, nil)
+		if err := New().Subscribe(app); err == nil {
+			t.Fatalf("subscribe %+v error = nil", subscription)
+		}
+	}
+}
+
+func TestSidecarBulkPublishAndBulkSubscribe(t *testing.T) {
+	t.Parallel()
+
+	sidecar := New(WithRetryInterval(time.Millisecond))
+	subscriptions := []Subscription{{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders", BulkSubscribe: &BulkSubscribe{Enabled: true}}}
+	app := newTestApp(t, subscriptions, map[string][]string{"/orders": {`{"statuses":[{"entryId":"1","status":"RETRY"}]}`, `{"statuses":[{"entryId":"1","status":"SUCCESS"}]}`}})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, sidecar, "/v1.0-alpha1/publish/bulk/order-pubsub/orders", "application/json",
+		`[{"entryId":"0","event":{"specversion":"1.0","id":"evt-1","source":"producer-gin","type":"com.agnostic.order.created.v1","data":{"id":"ORD-1"}},"contentType":"application/cloudevents+json"}]`, nil)
+	waitIdle(t, sidecar)
+
+	received := app.received("/orders")
+	if len(received) != 2 {
+		t.Fatalf("received %d bulk deliveries, want a retry and a success", len(received))
+	}
+	var request struct {
+		Topic   string `json:"topic"`
+		Entries []struct {
+			EntryID     string         `json:"entryId"`
+			Event       map[string]any `json:"event"`
+			ContentType string         `json:"contentType"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(received[1]), &request); err != nil {
+		t.Fatalf("decode bulk subscribe request: %v", err)
+	}
+	if request.Topic != "orders" || len(request.Entries) != 1 || request.Entries[0].Event["id"] != "evt-1" || request.Entries[0].ContentType != cloudEventsContentType {
+		t.Fatalf("unexpected bulk subscribe request: %s", received[1])
+	}
+}
+
+func TestSidecarReportsHealthAndMetadata(t *testing.T) {
+	t.Parallel()
+
+	sidecar := New(WithComponents(Component{Name: "order-pubsub", Type: "pubsub.in-memory", Version: "v1"}))
+	app := newTestApp(t, []Subscription{{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders"}}, map[string][]string{"/orders": {""}})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:3500"+path, nil)
+		res, _ := sidecar.Do(req)
+		return res
+	}
+	if res := get("/v1.0/healthz/outbound"); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("healthz = %d, want 204", res.StatusCode)
+	}
+	sidecar.SetHealthy(false)
+	if res := get("/v1.0/healthz/outbound"); res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("unhealthy healthz = %d, want 500", res.StatusCode)
+	}
+
+	var metadata struct {
+		Components    []Component    `json:"components"`
+		Subscriptions []Subscription `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(get("/v1.0/metadata").Body).Decode(&metadata); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if len(metadata.Components) != 1 || metadata.Components[0].Name != "order-pubsub" ||
+		len(metadata.Subscriptions) != 1 || metadata.Subscriptions[0].Topic != "orders" {
+		t.Fatalf("unexpected metadata: %+v", metadata)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:3500/v1.0/publish/other-pubsub/orders", bytes.NewBufferString(`{}`))
+	if res, _ := sidecar.Do(req); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("publish to an undeclared pubsub = %d, want 404", res.StatusCode)
+	}
+}
@@ -0,0 +1,80 @@
+package daprfake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Subscription is one entry of an app's /dapr/subscribe answer.
+type Subscription struct {
+	PubSubName      string            `json:"pubsubname"`
+	Topic           string            `json:"topic"`
+	Route           string            `json:"route,omitempty"`
+	Routes          *Routes           `json:"routes,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	DeadLetterTopic string            `json:"deadLetterTopic,omitempty"`
+	BulkSubscribe   *BulkSubscribe    `json:"bulkSubscribe,omitempty"`
+}
+
+// Routes are the routing rules of a v2 subscription. The first rule whose
+// Match holds picks the path, otherwise Default does.
+type Routes struct {
+	Rules   []Rule `json:"rules,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// Rule routes events to Path. Match must compare one string attribute,
+// such as event.type == "com.agnostic.order.created.v2"; the fake does not
+// evaluate other CEL expressions.
+type Rule struct {
+	Match string `json:"match"`
+	Path  string `json:"path"`
+}
+
+// BulkSubscribe makes the sidecar deliver with the bulk subscribe format.
+type BulkSubscribe struct {
+	Enabled bool `json:"enabled"`
+}
+
+var matchExpression = regexp.MustCompile(`^\s*event\.([a-z0-9]+)\s*==\s*(?:"([^"]*)"|'([^']*)')\s*$`)
+
+func (s Subscription) validate() error {
+	if s.PubSubName == "" || s.Topic == "" {
+		return errors.New("pubsubname and topic are required")
+	}
+	if s.Routes == nil {
+		if s.Route == "" {
+			return fmt.Errorf("topic %s: route is required", s.Topic)
+		}
+		return nil
+	}
+	for _, rule := range s.Routes.Rules {
+		if !matchExpression.MatchString(rule.Match) {
+			return fmt.Errorf("topic %s: unsupported match expression %q", s.Topic, rule.Match)
+		}
+	}
+	return nil
+}
+
+// route returns the path message is delivered to, or false when no rule
+// matches and there is no default, in which case Dapr drops the message.
+func (s Subscription) route(message Message) (string, bool) {
+	if s.Routes == nil {
+		return s.Route, true
+	}
+	var event map[string]any
+	_ = json.Unmarshal(message.Body, &event)
+	for _, rule := range s.Routes.Rules {
+		groups := matchExpression.FindStringSubmatch(rule.Match)
+		want := groups[2] + groups[3]
+		if value, _ := event[groups[1]].(string); value == want {
+			return rule.Path, true
+		}
+	}
+	if s.Routes.Default != "" {
+		return s.Routes.Default, true
+	}
+	return s.Route, s.Route != ""
+}
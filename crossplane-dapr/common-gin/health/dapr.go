@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTPDoer sends the check requests to the Dapr sidecar.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DaprSidecar checks that the sidecar at baseURL is up and its outbound
+// components are initialised, using /v1.0/healthz/outbound.
+func DaprSidecar(client HTTPDoer, baseURL string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		res, err := get(ctx, client, baseURL+"/v1.0/healthz/outbound")
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("dapr sidecar outbound health returned status %d", res.StatusCode)
+		}
+		return nil
+	})
+}
+
+// Subscription names a topic subscription the sidecar should have registered.
+type Subscription struct {
+	PubSubName string `json:"pubsubname"`
+	Topic      string `json:"topic"`
+}
+
+// daprMetadata is the part of the /v1.0/metadata answer the checks read.
+type daprMetadata struct {
+	Components []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"components"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// DaprMetadata checks through /v1.0/metadata that the sidecar loaded every
+// component in components and registered every subscription in
+// subscriptions.
+func DaprMetadata(client HTTPDoer, baseURL string, components []string, subscriptions []Subscription) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		res, err := get(ctx, client, baseURL+"/v1.0/metadata")
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("dapr metadata returned status %d", res.StatusCode)
+		}
+		var metadata daprMetadata
+		if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&metadata); err != nil {
+			return fmt.Errorf("decode dapr metadata: %w", err)
+		}
+
+		loaded := map[string]bool{}
+		for _, component := range metadata.Components {
+			loaded[component.Name] = true
+		}
+		var missing []string
+		for _, name := range components {
+			if !loaded[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("dapr components not loaded: %s", strings.Join(missing, ", "))
+		}
+
+		registered := map[Subscription]bool{}
+		for _, subscription := range metadata.Subscriptions {
+			registered[subscription] = true
+		}
+		for _, subscription := range subscriptions {
+			if !registered[subscription] {
+				missing = append(missing, subscription.PubSubName+"/"+subscription.Topic)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("dapr subscriptions not registered: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+func get(ctx context.Context, client HTTPDoer, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", req.URL.Path, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,227 @@
+// Package health runs pluggable dependency checks for the Gin services and
+// serves them as Kubernetes probes and as a detailed report shaped like a
+// Spring Boot Actuator health response.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the health of one check or of the whole service.
+type Status string
+
+const (
+	StatusUp   Status = "UP"
+	StatusDown Status = "DOWN"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// Checker reports a dependency as down by returning an error.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+// Component is the result of one check in a Report.
+type Component struct {
+	Status  Status         `json:"status"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Report is the outcome of running every check. The service is UP only when
+// every component is.
+type Report struct {
+	Status     Status               `json:"status"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Option customises a Health.
+type Option func(*Health)
+
+// WithTimeout bounds how long each check may take before it counts as down.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Health) {
+		if timeout > 0 {
+			h.timeout = timeout
+		}
+	}
+}
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+// Health holds the registered checks. The zero set of checks is always UP.
+type Health struct {
+	timeout  time.Duration
+	mu       sync.RWMutex
+	checks   []check
+	started  atomic.Bool
+	draining atomic.Bool
+	now      func() time.Time
+}
+
+func New(opts ...Option) *Health {
+	h := &Health{timeout: defaultTimeout, now: time.Now}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+// Register adds checker under name. Registering a name again replaces it.
+func (h *Health) Register(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.checks {
+		if h.checks[i].name == name {
+			h.checks[i].checker = checker
+			return
+		}
+	}
+	h.checks = append(h.checks, check{name: name, checker: checker})
+}
+
+// Run runs every check concurrently, each bounded by the timeout.
+func (h *Health) Run(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]check(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Components: make(map[string]Component, len(checks))}
+	results := make([]Component, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, c.checker)
+		}()
+	}
+	wg.Wait()
+	for i, c := range checks {
+		report.Components[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if report.Status == StatusUp {
+		h.started.Store(true)
+	}
+	return report
+}
+
+func (h *Health) run(ctx context.Context, checker Checker) (component Component) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	start := h.now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			component = Component{Status: StatusDown, Details: map[string]any{"error": fmt.Sprint("check panicked: ", recovered)}}
+		}
+		component.Details["latencyMs"] = float64(h.now().Sub(start).Microseconds()) / 1000
+	}()
+
+	err := checker.Check(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return Component{Status: StatusDown, Details: map[string]any{"error": err.Error()}}
+	}
+	return Component{Status: StatusUp, Details: map[string]any{}}
+}
+
+// Started reports whether every check has passed at least once.
+func (h *Health) Started() bool {
+	return h.started.Load()
+}
+
+// Drain marks the service as shutting down. From then on readiness answers
+// DOWN without running checks, so Kubernetes stops routing traffic to the pod
+// before the server closes.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether Drain was called.
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// LiveHandler serves the liveness probe. It does not run checks: a
+// dependency outage must not get the process restarted.
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, Report{Status: StatusUp})
+	})
+}
+
+// ReadyHandler serves the readiness probe: UP, or DOWN with 503 while any
+// check fails or the service is draining.
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Draining() {
+			writeJSON(w, Report{Status: StatusDown})
+			return
+		}
+		writeJSON(w, Report{Status: h.Run(r.Context()).Status})
+	})
+}
+
+// StartupHandler serves the startup probe. It answers 503 until every check
+// has passed once, then UP for good.
+func (h *Health) StartupHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.Started() {
+			h.Run(r.Context())
+		}
+		status := StatusUp
+		if !h.Started() {
+			status = StatusDown
+		}
+		writeJSON(w, Report{Status: status})
+	})
+}
+
+// DetailHandler serves the full Report, with 503 when the service is DOWN.
+func (h *Health) DetailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, h.Run(r.Context()))
+	})
+}
+
+// Names returns the registered check names, sorted.
+func (h *Health) Names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, len(h.checks))
+	for i, c := range h.checks {
+		names[i] = c.name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,180 @@
+//go:build !integration && !contract && !e2e
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/daprfake"
+)
+
+func up() Checker { return CheckerFunc(func(context.Context) error { return nil }) }
+
+func down(message string) Checker {
+	return CheckerFunc(func(context.Context) error { return errors.New(message) })
+}
+
+func serve(t *testing.T, handler http.Handler) (int, Report) {
+	t.Helper()
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var report Report
+	if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report %s: %v", res.Body.String(), err)
+	}
+	return res.Code, report
+}
+
+func TestRunReportsEveryComponent(t *testing.T) {
+	t.Parallel()
+
+	h := New(WithTimeout(20 * time.Millisecond))
+	h.Register("db", up())
+	h.Register("broker", down("connection refused"))
+	h.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	h.Register("broken", CheckerFunc(func(context.Context) error { panic("boom") }))
+
+	report := h.Run(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("status = %s, want DOWN", report.Status)
+	}
+	want := map[string]struct {
+		status Status
+		error  string
+	}{
+		"db":     {status: StatusUp},
+		"broker": {status: StatusDown, error: "connection refused"},
+		"slow":   {status: StatusDown, error: "context deadline exceeded"},
+		"broken": {status: StatusDown, error: "check panicked: boom"},
+	}
+	for name, expected := range want {
+		component, ok := report.Components[name]
+		if !ok {
+			t.Fatalf("component %s missing from %+v", name, report.Components)
+		}
+		if component.Status != expected.status {
+			t.Fatalf("%s status = %s, want %s", name, component.Status, expected.status)
+		}
+		if got, _ := component.Details["error"].(string); got != expected.error {
+			t.Fatalf("%s error = %q, want %q", name, got, expected.error)
+		}
+		if _, ok := component.Details["latencyMs"].(float64); !ok {
+			t.Fatalf("%s has no latencyMs: %+v", name, component.Details)
+		}
+	}
+	if h.Started() {
+		t.Fatal("started before every check passed")
+	}
+}
+
+func TestRegisterReplacesCheckWithTheSameName(t *testing.T) {
+	t.Parallel()
+
+	h := New()
+	h.Register("broker", down("not yet"))
+	h.Register("db", up())
+	h.Register("broker", up())
+
+	if names := h.Names(); len(names) != 2 || names[0] != "broker" || names[1] != "db" {
+		t.Fatalf("names = %v, want [broker db]", names)
+	}
+	if report := h.Run(context.Background()); report.Status != StatusUp {
+		t.Fatalf("status = %s, want UP", report.Status)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+
+	failing := true
+	h := New()
+	h.Register("broker", CheckerFunc(func(context.Context) error {
+		if failing {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+
+	if code, report := serve(t, h.LiveHandler()); code != http.StatusOK || report.Status != StatusUp || report.Components != nil {
+		t.Fatalf("live = %d %+v, want 200 UP without components", code, report)
+	}
+	if code, report := serve(t, h.ReadyHandler()); code != http.StatusServiceUnavailable || report.Status != StatusDown || report.Components != nil {
+		t.Fatalf("ready = %d %+v, want 503 DOWN without components", code, report)
+	}
+	if code, _ := serve(t, h.StartupHandler()); code != http.StatusServiceUnavailable {
+		t.Fatalf("startup = %d, want 503 before the first passing run", code)
+	}
+	code, report := serve(t, h.DetailHandler())
+	if code != http.StatusServiceUnavailable || report.Components["broker"].Details["error"] != "unavailable" {
+		t.Fatalf("detail = %d %+v, want 503 with the broker error", code, report)
+	}
+
+	failing = false
+	if code, _ := serve(t, h.StartupHandler()); code != http.StatusOK {
+		t.Fatalf("startup = %d, want 200 once every check passed", code)
+	}
+	failing = true
+	if code, _ := serve(t, h.StartupHandler()); code != http.StatusOK {
+		t.Fatalf("startup = %d, want 200 to stay latched", code)
+	}
+	if code, _ := serve(t, h.ReadyHandler()); code != http.StatusServiceUnavailable {
+		t.Fatalf("ready = %d, want 503 to follow the checks", code)
+	}
+}
+
+func TestDaprChecks(t *testing.T) {
+	t.Parallel()
+
+	sidecar := daprfake.New(daprfake.WithComponents(
+		daprfake.Component{Name: "order-pubsub", Type: "pubsub.redis", Version: "v1"},
+	))
+	app := http.NewServeMux()
+	app.HandleFunc("GET /dapr/subscribe", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode([]daprfake.Subscription{{PubSubName: "order-pubsub", Topic: "orders", Route: "/orders"}})
+	})
+	if err := sidecar.Subscribe(app); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	const baseURL = "http://localhost:3500"
+	ctx := context.Background()
+
+	if err := DaprSidecar(sidecar, baseURL).Check(ctx); err != nil {
+		t.Fatalf("sidecar check: %v", err)
+	}
+	sidecar.SetHealthy(false)
+	if err := DaprSidecar(sidecar, baseURL).Check(ctx); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("unhealthy sidecar check error = %v, want status 500", err)
+	}
+
+	tests := []struct {
+		name          string
+		components    []string
+		subscriptions []Subscription
+		wantErr       string
+	}{
+		{name: "all present", components: []string{"order-pubsub"}, subscriptions: []Subscription{{PubSubName: "order-pubsub", Topic: "orders"}}},
+		{name: "missing component", components: []string{"order-pubsub", "idempotency-store"}, wantErr: "dapr components not loaded: idempotency-store"},
+		{name: "missing subscription", subscriptions: []Subscription{{PubSubName: "order-pubsub", Topic: "payments"}}, wantErr: "dapr subscriptions not registered: order-pubsub/payments"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := DaprMetadata(sidecar, baseURL, tc.components, tc.subscriptions).Check(ctx)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("metadata check: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Fatalf("metadata check error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
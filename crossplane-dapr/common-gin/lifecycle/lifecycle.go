@@ -0,0 +1,209 @@
+// Package lifecycle runs the HTTP server of a Gin service until it is told to
+// stop, then shuts it down in the order Kubernetes needs: readiness fails
+// first, traffic drains away, in-flight requests finish and background
+// workers stop, all within a deadline.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+)
+
+// Process exit codes reported by ExitCode.
+const (
+	ExitOK              = 0
+	ExitError           = 1
+	ExitDrainIncomplete = 2
+)
+
+const (
+	defaultDrainPeriod     = 5 * time.Second
+	defaultShutdownTimeout = 20 * time.Second
+)
+
+// ErrDrainIncomplete reports that in-flight requests or background workers
+// were still running when the shutdown deadline passed.
+var ErrDrainIncomplete = errors.New("drain incomplete")
+
+// Config times the shutdown. DrainPeriod is how long the server keeps
+// serving after readiness fails, so endpoints and the Dapr sidecar stop
+// sending traffic. Timeout bounds the wait for in-flight requests and stop
+// hooks after that.
+type Config struct {
+	DrainPeriod time.Duration
+	Timeout     time.Duration
+}
+
+// Option customises Serve and Run.
+type Option func(*options)
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+type options struct {
+	config Config
+	health *health.Health
+	hooks  []hook
+	logger *slog.Logger
+}
+
+// WithConfig sets the drain period and shutdown timeout. Zero values keep the
+// defaults of 5s and 20s; a negative drain period skips the wait.
+func WithConfig(config Config) Option {
+	return func(o *options) {
+		if config.DrainPeriod != 0 {
+			o.config.DrainPeriod = max(config.DrainPeriod, 0)
+		}
+		if config.Timeout > 0 {
+			o.config.Timeout = config.Timeout
+		}
+	}
+}
+
+// WithHealth makes the shutdown flip h to draining before anything else, so
+// the readiness probe fails while the server still serves.
+func WithHealth(h *health.Health) Option {
+	return func(o *options) {
+		o.health = h
+	}
+}
+
+// WithStopHook stops a background worker once the server no longer accepts
+// requests. Hooks run in registration order and share the shutdown deadline.
+func WithStopHook(name string, stop func(ctx context.Context) error) Option {
+	return func(o *options) {
+		if stop != nil {
+			o.hooks = append(o.hooks, hook{name: name, stop: stop})
+		}
+	}
+}
+
+// WithLogger logs the shutdown steps to logger instead of slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	resolved := options{
+		config: Config{DrainPeriod: defaultDrainPeriod, Timeout: defaultShutdownTimeout},
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&resolved)
+		}
+	}
+	return resolved
+}
+
+// Run listens on server.Addr and serves until ctx is done, see Serve.
+func Run(ctx context.Context, server *http.Server, opts ...Option) error {
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return abort(newOptions(opts), fmt.Errorf("listen on %s: %w", addr, err))
+	}
+	return Serve(ctx, server, listener, opts...)
+}
+
+// Serve serves on listener until ctx is done, typically by a termination
+// signal, and then shuts down. It returns nil when everything drained in
+// time, an error wrapping ErrDrainIncomplete when the deadline cut work off
+// and any other error when the server failed while serving, even if it failed
+// during the shutdown. The stop hooks run on every path.
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, opts ...Option) error {
+	resolved := newOptions(opts)
+	logger := resolved.logger
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return abort(resolved, fmt.Errorf("serve: %w", err))
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down", "drainPeriod", resolved.config.DrainPeriod, "timeout", resolved.config.Timeout)
+	if resolved.health != nil {
+		resolved.health.Drain()
+	}
+	time.Sleep(resolved.config.DrainPeriod)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), resolved.config.Timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("finish in-flight requests: %w", err))
+	}
+	failed := <-serveErr
+	errs = append(errs, stopHooks(shutdownCtx, resolved.hooks)...)
+	if failed != nil && !errors.Is(failed, http.ErrServerClosed) {
+		// The server failed on its own while draining; that is a serve
+		// error whatever else went wrong.
+		err := errors.Join(append([]error{fmt.Errorf("serve: %w", failed)}, errs...)...)
+		logger.Error("server failed during shutdown", "error", err)
+		return err
+	}
+	if len(errs) > 0 {
+		err := fmt.Errorf("%w: %w", ErrDrainIncomplete, errors.Join(errs...))
+		logger.Error("shutdown did not drain", "error", err)
+		return err
+	}
+	logger.Info("shutdown complete")
+	return nil
+}
+
+// abort stops the workers of a server that failed before the shutdown began.
+func abort(resolved options, cause error) error {
+	if resolved.health != nil {
+		resolved.health.Drain()
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), resolved.config.Timeout)
+	defer cancel()
+	err := errors.Join(append([]error{cause}, stopHooks(stopCtx, resolved.hooks)...)...)
+	resolved.logger.Error("server failed", "error", err)
+	return err
+}
+
+// stopHooks runs hooks in registration order and returns their errors.
+func stopHooks(ctx context.Context, hooks []hook) []error {
+	var errs []error
+	for _, h := range hooks {
+		if err := h.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", h.name, err))
+		}
+	}
+	return errs
+}
+
+// ExitCode maps the result of Serve or Run to a process exit code.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrDrainIncomplete):
+		return ExitDrainIncomplete
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,306 @@
+//go:build !integration && !contract && !e2e
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+)
+
+// recorder collects the shutdown steps in the order they happen.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type testServer struct {
+	url     string
+	started chan struct{}
+	release chan struct{}
+	done    chan error
+}
+
+// startServer serves /health/ready from h and a /slow endpoint that blocks
+// until release is closed.
+func startServer(t *testing.T, ctx context.Context, h *health.Health, events *recorder, opts ...Option) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &testServer{
+		url:     "http://" + listener.Addr().String(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		done:    make(chan error, 1),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /health/ready", h.ReadyHandler())
+	mux.HandleFunc("GET /ping", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "pong")
+	})
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(s.started)
+		<-s.release
+		events.add("request finished")
+		_, _ = io.WriteString(w, "done")
+	})
+	opts = append([]Option{WithHealth(h), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)
+	go func() {
+		s.done <- Serve(ctx, &http.Server{Handler: mux}, listener, opts...)
+	}()
+	return s
+}
+
+func (s *testServer) get(path string) (int, error) {
+	res, err := http.Get(s.url + path)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	return res.StatusCode, nil
+}
+
+func TestServeShutsDownInOrder(t *testing.T) {
+	t.Parallel()
+
+	events := &recorder{}
+	h := health.New()
+	ctx, stop := context.WithCancel(context.Background())
+	s := startServer(t, ctx, h, events,
+		WithConfig(Config{DrainPeriod: 100 * time.Millisecond, Timeout: 5 * time.Second}),
+		WithStopHook("worker", func(context.Context) error {
+			events.add("worker stopped")
+			return nil
+		}),
+	)
+	if code, err := s.get("/health/ready"); err != nil || code != http.StatusOK {
+		t.Fatalf("ready before shutdown = %d, %v, want 200", code, err)
+	}
+
+	slow := make(chan error, 1)
+	go func() {
+		code, err := s.get("/slow")
+		if err == nil && code != http.StatusOK {
+			err = errors.New(http.StatusText(code))
+		}
+		slow <- err
+	}()
+	<-s.started
+	stop()
+
+	deadline := time.Now().Add(time.Second)
+	for !h.Draining() {
+		if time.Now().After(deadline) {
+			t.Fatal("readiness did not flip after the stop signal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	events.add("readiness failed")
+	if code, err := s.get("/health/ready"); err != nil || code != http.StatusServiceUnavailable {
+		t.Fatalf("ready while draining = %d, %v, want 503", code, err)
+	}
+	if code, err := s.get("/ping"); err != nil || code != http.StatusOK {
+		t.Fatalf("request during the drain period = %d, %v, want 200", code, err)
+	}
+
+	// Hold the in-flight request past the drain period so shutdown has to
+	// wait for it.
+	time.Sleep(150 * time.Millisecond)
+	close(s.release)
+
+	if err := <-s.done; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if err := <-slow; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	want := []string{"readiness failed", "request finished", "worker stopped"}
+	if got := events.list(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("shutdown order = %v, want %v", got, want)
+	}
+	if _, err := s.get("/ping"); err == nil {
+		t.Fatal("server still accepts connections after shutdown")
+	}
+}
+
+func TestServeReportsIncompleteDrain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		inFlight bool
+		hook     func(ctx context.Context) error
+	}{
+		{name: "in-flight request outlives the deadline", inFlight: true},
+		{name: "worker fails to stop", hook: func(context.Context) error { return errors.New("queue still busy") }},
+		{name: "worker outlives the deadline", hook: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, stop := context.WithCancel(context.Background())
+			opts := []Option{WithConfig(Config{DrainPeriod: -1, Timeout: 50 * time.Millisecond})}
+			if tc.hook != nil {
+				opts = append(opts, WithStopHook("worker", tc.hook))
+			}
+			s := startServer(t, ctx, health.New(), &recorder{}, opts...)
+			defer close(s.release)
+			if tc.inFlight {
+				go func() { _, _ = s.get("/slow") }()
+				<-s.started
+			}
+			stop()
+
+			err := <-s.done
+			if !errors.Is(err, ErrDrainIncomplete) {
+				t.Fatalf("serve error = %v, want ErrDrainIncomplete", err)
+			}
+			if code := ExitCode(err); code != ExitDrainIncomplete {
+				t.Fatalf("exit code = %d, want %d", code, ExitDrainIncomplete)
+			}
+		})
+	}
+}
+
+func TestRunFailsWhenTheAddressIsTaken(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	stopped := false
+	err = Run(context.Background(), &http.Server{Addr: listener.Addr().String()},
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithStopHook("worker", func(context.Context) error {
+			stopped = true
+			return nil
+		}),
+	)
+	if err == nil || errors.Is(err, ErrDrainIncomplete) {
+		t.Fatalf("run error = %v, want a listen error", err)
+	}
+	if !stopped {
+		t.Fatal("stop hook did not run after the listen error")
+	}
+	if code := ExitCode(err); code != ExitError {
+		t.Fatalf("exit code = %d, want %d", code, ExitError)
+	}
+	if code := ExitCode(nil); code != ExitOK {
+		t.Fatalf("exit code for a clean shutdown = %d, want %d", code, ExitOK)
+	}
+}
+
+// failingListener accepts nothing and fails every Accept once fail is
+// closed.
+type failingListener struct {
+	net.Listener
+	fail chan struct{}
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	<-l.fail
+	return nil, errors.New("accept failed")
+}
+
+func TestServeStopsWorkersWhenTheServerFails(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// failAfterStop fails the listener during the drain period instead
+		// of before the stop signal.
+		failAfterStop bool
+	}{
+		{name: "before the stop signal"},
+		{name: "during the shutdown", failAfterStop: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			defer inner.Close()
+			listener := failingListener{Listener: inner, fail: make(chan struct{})}
+
+			events := &recorder{}
+			h := health.New()
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			done := make(chan error, 1)
+			go func() {
+				done <- Serve(ctx, &http.Server{Handler: http.NotFoundHandler()}, listener,
+					WithHealth(h),
+					WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+					WithConfig(Config{DrainPeriod: 100 * time.Millisecond, Timeout: time.Second}),
+					WithStopHook("worker", func(context.Context) error {
+						events.add("worker stopped")
+						return errors.New("queue still busy")
+					}),
+				)
+			}()
+
+			if tc.failAfterStop {
+				stop()
+				deadline := time.Now().Add(time.Second)
+				for !h.Draining() {
+					if time.Now().After(deadline) {
+						t.Fatal("readiness did not flip after the stop signal")
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			close(listener.fail)
+
+			err = <-done
+			if err == nil || errors.Is(err, ErrDrainIncomplete) || !strings.Contains(err.Error(), "accept failed") {
+				t.Fatalf("serve error = %v, want the serve error", err)
+			}
+			if code := ExitCode(err); code != ExitError {
+				t.Fatalf("exit code = %d, want %d", code, ExitError)
+			}
+			if got := events.list(); len(got) != 1 || got[0] != "worker stopped" {
+				t.Fatalf("events = %v, want the worker stopped once", got)
+			}
+			if !strings.Contains(err.Error(), "stop worker: queue still busy") {
+				t.Fatalf("serve error = %v, want the stop hook error too", err)
+			}
+			if !h.Draining() {
+				t.Fatal("readiness still passes after the server failed")
+			}
+		})
+	}
+}
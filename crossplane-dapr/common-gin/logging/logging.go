@@ -0,0 +1,200 @@
+// Package logging builds the slog.Logger of the Gin services: the level and
+// output format come from LOG_LEVEL and LOG_FORMAT, and every record carries
+// the OpenTelemetry resource attributes and pod metadata of the process so
+// Loki lines line up with traces and metrics. Records logged with a context
+// carrying a span also carry its trace_id and span_id.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Format is the line format of the log output.
+type Format string
+
+const (
+	// FormatText is slog's key=value text output.
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per line.
+	FormatJSON Format = "json"
+	// FormatLogfmt is strict logfmt with ts, level and msg keys, UTC
+	// timestamps and lowercase levels.
+	FormatLogfmt Format = "logfmt"
+)
+
+// ParseFormat reads a LOG_FORMAT value. Matching is case-insensitive.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(value))); format {
+	case FormatText, FormatJSON, FormatLogfmt:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported log format %q", value)
+	}
+}
+
+// ParseLevel reads a LOG_LEVEL value, defaulting to info.
+func ParseLevel(value string) slog.Level {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "WARN", "WARNING":
+		return slog.LevelWarn
+	case "ERROR":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// podAttributes maps the pod metadata the manifests inject to OpenTelemetry
+// resource attribute keys.
+var podAttributes = []struct{ env, key string }{
+	{env: "APP_SERVICE", key: "service.name"},
+	{env: "APP_VERSION", key: "service.version"},
+	{env: "DEPLOYMENT_ENV", key: "deployment.environment"},
+	{env: "POD_NAMESPACE", key: "k8s.namespace.name"},
+	{env: "POD_NAME", key: "k8s.pod.name"},
+	{env: "NODE_NAME", key: "k8s.node.name"},
+}
+
+// Config selects the level, format and constant attributes of a logger.
+type Config struct {
+	Level      slog.Level
+	Format     Format
+	Attributes map[string]string
+}
+
+// LoadConfigFromEnv reads LOG_LEVEL and LOG_FORMAT, and collects the
+// attributes from the pod metadata variables, OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_SERVICE_NAME, later sources overriding earlier ones as the
+// OpenTelemetry SDK does. An unsupported LOG_FORMAT falls back to text and is
+// returned as an error so the caller can report it once logging works.
+func LoadConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Level:      ParseLevel(os.Getenv("LOG_LEVEL")),
+		Format:     FormatText,
+		Attributes: map[string]string{},
+	}
+	for _, attribute := range podAttributes {
+		if value := strings.TrimSpace(os.Getenv(attribute.env)); value != "" {
+			cfg.Attributes[attribute.key] = value
+		}
+	}
+	for key, value := range ParseResourceAttributes(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		cfg.Attributes[key] = value
+	}
+	if serviceName := strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME")); serviceName != "" {
+		cfg.Attributes["service.name"] = serviceName
+	}
+
+	value := os.Getenv("LOG_FORMAT")
+	if strings.TrimSpace(value) == "" {
+		return cfg, nil
+	}
+	format, err := ParseFormat(value)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Format = format
+	return cfg, nil
+}
+
+// ParseResourceAttributes parses the W3C Baggage style list of
+// OTEL_RESOURCE_ATTRIBUTES: comma-separated key=value pairs with
+// percent-encoded values. Malformed pairs are skipped.
+func ParseResourceAttributes(value string) map[string]string {
+	attributes := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, raw, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		attributes[key] = decoded
+	}
+	return attributes
+}
+
+// New builds a logger that writes cfg.Format lines to w, with cfg.Attributes
+// on every record in key order and the trace and span ids of the context
+// passed to the *Context logging methods.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: cfg.Level}
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case FormatLogfmt:
+		handlerOpts.ReplaceAttr = logfmtAttr
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	keys := make([]string, 0, len(cfg.Attributes))
+	for key := range cfg.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, cfg.Attributes[key]))
+	}
+	return slog.New(traceHandler{handler.WithAttrs(attrs)})
+}
+
+// traceHandler adds the ids of the span in the record context.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+// logfmtAttr renames slog's built-in keys to the logfmt conventions Loki and
+// Grafana expect.
+func logfmtAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.TimeKey:
+		if t, ok := attr.Value.Any().(time.Time); ok {
+			return slog.String("ts", t.UTC().Format(time.RFC3339Nano))
+		}
+	case slog.LevelKey:
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, strings.ToLower(level.String()))
+		}
+	}
+	return attr
+}
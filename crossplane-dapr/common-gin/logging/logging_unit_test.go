@@ -0,0 +1,149 @@
+//go:build !integration && !contract && !e2e
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoadConfigFromEnvMergesResourceAttributes(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("APP_SERVICE", "producer-gin")
+	t.Setenv("APP_VERSION", "0.1.0")
+	t.Setenv("POD_NAME", "producer-gin-7d9f")
+	t.Setenv("POD_NAMESPACE", "")
+	t.Setenv("NODE_NAME", "")
+	t.Setenv("DEPLOYMENT_ENV", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.version=1.2.3, service.stack=gin,k8s.namespace.name=agnostic%20apps,broken,=empty")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Level != slog.LevelDebug || cfg.Format != FormatJSON {
+		t.Fatalf("level, format = %v, %s, want DEBUG, json", cfg.Level, cfg.Format)
+	}
+	want := map[string]string{
+		"service.name":       "producer-gin",
+		"service.version":    "1.2.3",
+		"service.stack":      "gin",
+		"k8s.pod.name":       "producer-gin-7d9f",
+		"k8s.namespace.name": "agnostic apps",
+	}
+	if len(cfg.Attributes) != len(want) {
+		t.Fatalf("attributes = %v, want %v", cfg.Attributes, want)
+	}
+	for key, value := range want {
+		if cfg.Attributes[key] != value {
+			t.Fatalf("attribute %s = %q, want %q", key, cfg.Attributes[key], value)
+		}
+	}
+
+	t.Setenv("OTEL_SERVICE_NAME", "producer-gin-canary")
+	t.Setenv("LOG_FORMAT", "xml")
+	cfg, err = LoadConfigFromEnv()
+	if err == nil || cfg.Format != FormatText {
+		t.Fatalf("invalid format = %s, %v, want text and an error", cfg.Format, err)
+	}
+	if cfg.Attributes["service.name"] != "producer-gin-canary" {
+		t.Fatalf("service.name = %q, want OTEL_SERVICE_NAME to win", cfg.Attributes["service.name"])
+	}
+}
+
+func TestNewWritesTheConfiguredFormat(t *testing.T) {
+	t.Parallel()
+
+	attributes := map[string]string{"service.name": "consumer-gin", "k8s.pod.name": "consumer-gin-0"}
+	tests := []struct {
+		format Format
+		check  func(t *testing.T, line string)
+	}{
+		{format: FormatJSON, check: func(t *testing.T, line string) {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+			if record["msg"] != "consumed order event" || record["level"] != "INFO" ||
+				record["service.name"] != "consumer-gin" || record["k8s.pod.name"] != "consumer-gin-0" || record["orderId"] != "ORD-1" {
+				t.Fatalf("unexpected record %v", record)
+			}
+		}},
+		{format: FormatLogfmt, check: func(t *testing.T, line string) {
+			if !strings.HasPrefix(line, "ts=") || !strings.Contains(line, " level=info ") ||
+				!strings.Contains(line, `msg="consumed order event" k8s.pod.name=consumer-gin-0 service.name=consumer-gin orderId=ORD-1`) {
+				t.Fatalf("unexpected logfmt line %q", line)
+			}
+		}},
+		{format: FormatText, check: func(t *testing.T, line string) {
+			if !strings.HasPrefix(line, "time=") || !strings.Contains(line, " level=INFO ") || !strings.Contains(line, "service.name=consumer-gin") {
+				t.Fatalf("unexpected text line %q", line)
+			}
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.format), func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			logger := New(&out, Config{Level: slog.LevelInfo, Format: tc.format, Attributes: attributes})
+			logger.Debug("filtered out")
+			logger.Info("consumed order event", "orderId", "ORD-1")
+			tc.check(t, strings.TrimSpace(out.String()))
+		})
+	}
+}
+
+func TestNewTagsRecordsWithTheContextSpan(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger := New(&out, Config{Level: slog.LevelInfo, Format: FormatJSON}).With("route", "/orders")
+	traceID := trace.TraceID{1}
+	parent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{1}}))
+	child := trace.ContextWithSpanContext(parent, trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{2}}))
+
+	logger.InfoContext(parent, "received")
+	logger.InfoContext(child, "handled")
+	logger.Info("no span")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{trace.SpanID{1}.String(), trace.SpanID{2}.String(), ""}
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d lines, want %d: %q", len(lines), len(want), out.String())
+	}
+	for i, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		spanID, _ := record["span_id"].(string)
+		if spanID != want[i] || record["route"] != "/orders" {
+			t.Fatalf("record %d = %v, want span_id %q", i, record, want[i])
+		}
+		if traceIDValue, _ := record["trace_id"].(string); want[i] != "" && traceIDValue != traceID.String() {
+			t.Fatalf("record %d trace_id = %q, want %s", i, traceIDValue, traceID)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{"text", " json ", "LOGFMT"} {
+		if _, err := ParseFormat(value); err != nil {
+			t.Fatalf("parse %q: %v", value, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Fatal("parse yaml succeeded, want an error")
+	}
+}
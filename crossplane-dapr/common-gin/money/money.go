@@ -0,0 +1,261 @@
+// Package money provides the exact decimal amount shared by the Gin services.
+// Money has the semantics of Java's BigDecimal so order events round-trip
+// byte for byte with the Ktor services.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxDigits is the maximum number of digits in a decimal literal.
+	MaxDigits = 100
+	// MaxAbsScale bounds the scale of a decimal literal in both directions,
+	// so an amount such as 1E+999999999 cannot make arithmetic build numbers
+	// with millions of digits.
+	MaxAbsScale = 100
+)
+
+// Money is an exact decimal amount: an unscaled integer and a scale, so 55.10
+// and 55.1 are distinct values that compare equal with Cmp. It marshals as a
+// JSON number using BigDecimal.toString, matching the Ktor
+// BigDecimalAsNumberSerializer byte for byte. The zero value is 0. Values are
+// immutable; every operation returns a new Money. Compare values with Equal
+// or Cmp, not ==.
+type Money struct {
+	// unscaled is nil for zero.
+	unscaled *big.Int
+	scale    int32
+}
+
+// Parse parses a decimal literal as accepted by new BigDecimal(String), for
+// example "55.10", "-3" or "1E+3". Literals with more than MaxDigits digits
+// or a scale beyond MaxAbsScale are rejected.
+func Parse(value string) (Money, error) {
+	mantissa, exponent := value, int64(0)
+	if i := strings.IndexAny(value, "eE"); i >= 0 {
+		parsed, err := strconv.ParseInt(value[i+1:], 10, 32)
+		if err != nil {
+			return Money{}, fmt.Errorf("invalid decimal %q", value)
+		}
+		mantissa, exponent = value[:i], parsed
+	}
+
+	sign := ""
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	digits, fraction, _ := strings.Cut(mantissa, ".")
+	if digits+fraction == "" || !isDigits(digits) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid decimal %q", value)
+	}
+	if len(digits)+len(fraction) > MaxDigits {
+		return Money{}, fmt.Errorf("decimal %q has more than %d digits", value, MaxDigits)
+	}
+	scale := int64(len(fraction)) - exponent
+	if scale < -MaxAbsScale || scale > MaxAbsScale {
+		return Money{}, fmt.Errorf("decimal %q is out of range", value)
+	}
+
+	unscaled, _ := new(big.Int).SetString(sign+digits+fraction, 10)
+	return newMoney(unscaled, int32(scale)), nil
+}
+
+// MustParse is Parse for constants; it panics on invalid input.
+func MustParse(value string) Money {
+	money, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return money
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func newMoney(unscaled *big.Int, scale int32) Money {
+	if unscaled.Sign() == 0 {
+		return Money{scale: scale}
+	}
+	return Money{unscaled: unscaled, scale: scale}
+}
+
+// unscaledValue returns a copy of the unscaled value that callers may modify.
+func (m Money) unscaledValue() *big.Int {
+	if m.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(m.unscaled)
+}
+
+// Scale returns the number of digits after the decimal point. It is negative
+// for values such as 1E+3.
+func (m Money) Scale() int {
+	return int(m.scale)
+}
+
+// Sign returns -1, 0 or +1.
+func (m Money) Sign() int {
+	if m.unscaled == nil {
+		return 0
+	}
+	return m.unscaled.Sign()
+}
+
+// Cmp compares m and other numerically, ignoring scale.
+func (m Money) Cmp(other Money) int {
+	a, b := alignScales(m, other)
+	return a.Cmp(b)
+}
+
+// Equal reports whether m and other have the same value and scale, like
+// BigDecimal.equals.
+func (m Money) Equal(other Money) bool {
+	return m.scale == other.scale && m.Cmp(other) == 0
+}
+
+// Add returns m + other with the larger of the two scales.
+func (m Money) Add(other Money) Money {
+	a, b := alignScales(m, other)
+	return newMoney(a.Add(a, b), max(m.scale, other.scale))
+}
+
+// MulInt returns m * n with the scale of m.
+func (m Money) MulInt(n int64) Money {
+	unscaled := m.unscaledValue()
+	return newMoney(unscaled.Mul(unscaled, big.NewInt(n)), m.scale)
+}
+
+func alignScales(a, b Money) (*big.Int, *big.Int) {
+	x, y := a.unscaledValue(), b.unscaledValue()
+	if a.scale < b.scale {
+		x.Mul(x, pow10(int64(b.scale)-int64(a.scale)))
+	} else if b.scale < a.scale {
+		y.Mul(y, pow10(int64(a.scale)-int64(b.scale)))
+	}
+	return x, y
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+// sortKeyExponentBias makes every adjusted exponent of an int32 scale
+// non-negative and at most sortKeyExponentWidth digits long.
+const (
+	sortKeyExponentBias  = 1 << 32
+	sortKeyExponentWidth = 10
+)
+
+// SortKey returns a string whose byte order is the numeric order of the
+// values, so text columns such as a SQLite index can filter amounts exactly.
+// Values that compare equal with Cmp, such as 55.1 and 55.10, share a key.
+//
+// Zero is "1". A positive value is "2", its biased adjusted exponent and its
+// digits without trailing zeros. A negative value is "0" followed by the
+// nines' complement of the exponent and digits and a ":" terminator, so a
+// longer digit string sorts first.
+func (m Money) SortKey() string {
+	sign := m.Sign()
+	if sign == 0 {
+		return "1"
+	}
+	unscaled := m.unscaledValue()
+	coefficient := unscaled.Abs(unscaled).String()
+	exponent := int64(len(coefficient)-1) - int64(m.scale) + sortKeyExponentBias
+	digits := strings.TrimRight(coefficient, "0")
+	if sign > 0 {
+		return fmt.Sprintf("2%0*d%s", sortKeyExponentWidth, exponent, digits)
+	}
+
+	var b strings.Builder
+	b.WriteString("0")
+	b.WriteString(complementDigits(fmt.Sprintf("%0*d", sortKeyExponentWidth, exponent)))
+	b.WriteString(complementDigits(digits))
+	b.WriteString(":")
+	return b.String()
+}
+
+func complementDigits(digits string) string {
+	complement := []byte(digits)
+	for i, digit := range complement {
+		complement[i] = '9' - digit + '0'
+	}
+	return string(complement)
+}
+
+// String formats m like BigDecimal.toString: plain notation unless the scale
+// is negative or the value is smaller than 1E-6, then scientific notation.
+func (m Money) String() string {
+	unscaled := m.unscaledValue()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+		unscaled.Neg(unscaled)
+	}
+	coefficient := unscaled.String()
+	scale := int64(m.scale)
+	adjusted := int64(len(coefficient)-1) - scale
+
+	if scale >= 0 && adjusted >= -6 {
+		switch {
+		case scale == 0:
+			return sign + coefficient
+		case int64(len(coefficient)) > scale:
+			point := int64(len(coefficient)) - scale
+			return sign + coefficient[:point] + "." + coefficient[point:]
+		default:
+			return sign + "0." + strings.Repeat("0", int(scale)-len(coefficient)) + coefficient
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	b.WriteString(coefficient[:1])
+	if len(coefficient) > 1 {
+		b.WriteString(".")
+		b.WriteString(coefficient[1:])
+	}
+	b.WriteString("E")
+	if adjusted > 0 {
+		b.WriteString("+")
+	}
+	b.WriteString(strconv.FormatInt(adjusted, 10))
+	return b.String()
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or, like the Ktor serializer, a string
+// holding a decimal literal.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	literal := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &literal); err != nil {
+			return err
+		}
+	}
+	parsed, err := Parse(literal)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
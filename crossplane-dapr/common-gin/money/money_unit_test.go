@@ -0,0 +1,134 @@
+//go:build !integration && !contract && !e2e
+
+package money
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value     string
+		wantText  string
+		wantScale int
+		wantErr   bool
+	}{
+		{value: "55.1", wantText: "55.1", wantScale: 1},
+		{value: "55.10", wantText: "55.10", wantScale: 2},
+		{value: "+7", wantText: "7", wantScale: 0},
+		{value: "-0.5", wantText: "-0.5", wantScale: 1},
+		{value: ".25", wantText: "0.25", wantScale: 2},
+		{value: "0.00", wantText: "0.00", wantScale: 2},
+		{value: "1E+3", wantText: "1E+3", wantScale: -3},
+		{value: "12.5e-8", wantText: "1.25E-7", wantScale: 9},
+		{value: "0.0000001", wantText: "1E-7", wantScale: 7},
+		{value: "1E+100", wantText: "1E+100", wantScale: -100},
+		{value: "", wantErr: true},
+		{value: "1.2.3", wantErr: true},
+		{value: "1e", wantErr: true},
+		{value: "--1", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "1E+101", wantErr: true},
+		{value: "1E+999999999", wantErr: true},
+		{value: "1E-999999999", wantErr: true},
+		{value: "0." + strings.Repeat("0", MaxAbsScale) + "1", wantErr: true},
+		{value: strings.Repeat("9", MaxDigits+1), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		money, err := Parse(tc.value)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("Parse(%q) = %s, want error", tc.value, money)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.value, err)
+		}
+		if money.String() != tc.wantText || money.Scale() != tc.wantScale {
+			t.Fatalf("Parse(%q) = %s (scale %d), want %s (scale %d)", tc.value, money, money.Scale(), tc.wantText, tc.wantScale)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	t.Parallel()
+
+	total := MustParse("0.1").Add(MustParse("0.2"))
+	if total.String() != "0.3" || total.Cmp(MustParse("0.30")) != 0 {
+		t.Fatalf("0.1 + 0.2 = %s, want 0.3", total)
+	}
+	if got := MustParse("19.99").MulInt(3).Add(MustParse("0.5")); got.String() != "60.47" {
+		t.Fatalf("3 * 19.99 + 0.5 = %s, want 60.47", got)
+	}
+	if a, b := MustParse("55.1"), MustParse("55.10"); a.Cmp(b) != 0 || a.Equal(b) || !a.Equal(MustParse("55.1")) {
+		t.Fatal("expected equal values with distinct scales")
+	}
+
+	var zero Money
+	if zero.Sign() != 0 || zero.Add(MustParse("1.5")).String() != "1.5" {
+		t.Fatalf("zero value = %s, want 0", zero)
+	}
+
+	// Operations must not modify their operands.
+	price := MustParse("2.50")
+	_ = price.MulInt(4)
+	_ = price.Add(price)
+	if price.String() != "2.50" {
+		t.Fatalf("price = %s after arithmetic, want 2.50", price)
+	}
+}
+
+func TestSortKeyOrdersNumerically(t *testing.T) {
+	t.Parallel()
+
+	values := []string{
+		"-1E+100", "-1000", "-55.10", "-55.1", "-55.01", "-10", "-9.99", "-1", "-0.3", "-0.1", "-1E-100",
+		"0", "0.00", "1E-100", "0.1", "0.10", "0.3", "1", "9.99", "10", "55.01", "55.1", "55.10", "1000", "1E+100",
+	}
+	for _, a := range values {
+		for _, b := range values {
+			x, y := MustParse(a), MustParse(b)
+			if got, want := strings.Compare(x.SortKey(), y.SortKey()), x.Cmp(y); got != want {
+				t.Fatalf("compare keys of %s and %s = %d, want %d (%q, %q)", a, b, got, want, x.SortKey(), y.SortKey())
+			}
+		}
+	}
+	if sum := MustParse("0.1").Add(MustParse("0.2")); sum.SortKey() != MustParse("0.3").SortKey() {
+		t.Fatalf("0.1 + 0.2 key = %q, want the key of 0.3", sum.SortKey())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	var order struct {
+		Amount Money `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"42.50"}`), &order); err != nil {
+		t.Fatalf("decode string amount: %v", err)
+	}
+	if order.Amount.String() != "42.50" {
+		t.Fatalf("amount = %s, want 42.50", order.Amount)
+	}
+	for _, body := range []string{`{"amount":true}`, `{"amount":1E+999999999}`} {
+		if err := json.Unmarshal([]byte(body), &order); err == nil {
+			t.Fatalf("decode %s succeeded, want an error", body)
+		}
+	}
+
+	encoded, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{})
+	if err != nil {
+		t.Fatalf("encode zero amount: %v", err)
+	}
+	if string(encoded) != `{"amount":0}` {
+		t.Fatalf("unexpected zero amount encoding: %s", encoded)
+	}
+}
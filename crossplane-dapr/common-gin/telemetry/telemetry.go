@@ -0,0 +1,100 @@
+// Package telemetry sets up OpenTelemetry tracing the same way in every Gin
+// service: an OTLP span exporter configured from the standard OTEL_*
+// environment variables, W3C and B3 propagation, and a request filter that
+// keeps probe and scrape traffic out of the trace backend.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultOTLPProtocol = "grpc"
+
+// Setup installs a global OpenTelemetry tracer provider exporting spans over
+// OTLP, together with the propagator returned by NewPropagator. Exporter and
+// resource settings come from the standard OTEL_* environment variables
+// (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_PROTOCOL,
+// OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES), which override serviceName.
+// The returned function flushes pending spans and must be called on shutdown.
+func Setup(ctx context.Context, serviceName string, logger *slog.Logger) (func(context.Context) error, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	exporter, err := newSpanExporter(ctx, os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"), logger)
+	if err != nil {
+		return nil, fmt.Errorf("create span exporter: %w", err)
+	}
+
+	res, err := newResource(ctx, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("create telemetry resource: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(NewPropagator())
+
+	logger.Debug("opentelemetry tracing configured", "serviceName", serviceName)
+	return tracerProvider.Shutdown, nil
+}
+
+// NewPropagator returns the propagator used by the Gin services: W3C trace
+// context and baggage, plus B3 multi-header extraction for callers that only
+// send x-b3-* headers. W3C traceparent wins when both are present.
+func NewPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
+
+// IsTracedRequest keeps probe and scrape traffic out of the trace backend;
+// pass it to otelgin.WithFilter.
+func IsTracedRequest(r *http.Request) bool {
+	return r.URL.Path != "/health" && !strings.HasPrefix(r.URL.Path, "/health/") && r.URL.Path != "/metrics"
+}
+
+func newSpanExporter(ctx context.Context, protocol string, logger *slog.Logger) (sdktrace.SpanExporter, error) {
+	normalized := strings.ToLower(strings.TrimSpace(protocol))
+	if normalized == "" {
+		normalized = defaultOTLPProtocol
+	}
+
+	switch normalized {
+	case "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	default:
+		logger.Warn("unsupported OTLP protocol, falling back to grpc exporter", "protocol", protocol)
+		return otlptracegrpc.New(ctx)
+	}
+}
+
+func newResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	// Detectors are merged in order, so OTEL_SERVICE_NAME and
+	// OTEL_RESOURCE_ATTRIBUTES override the configured service name.
+	return resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+}
@@ -0,0 +1,88 @@
+//go:build !integration && !contract && !e2e
+
+package telemetry
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewResourceUsesOtelEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.version=1.2.3,k8s.pod.name=producer-gin-0")
+
+	res, err := newResource(context.Background(), "producer-gin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attributes := map[string]string{}
+	for _, kv := range res.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attributes["service.name"] != "producer-gin" {
+		t.Fatalf("unexpected service.name: %q", attributes["service.name"])
+	}
+	if attributes["service.version"] != "1.2.3" || attributes["k8s.pod.name"] != "producer-gin-0" {
+		t.Fatalf("expected OTEL_RESOURCE_ATTRIBUTES to be applied, got %v", attributes)
+	}
+}
+
+func TestNewPropagatorExtractsW3CAndB3(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		carrier propagation.MapCarrier
+		traceID string
+	}{
+		{
+			name:    "w3c traceparent",
+			carrier: propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "b3 multi header",
+			carrier: propagation.MapCarrier{"x-b3-traceid": "463ac35c9f6413ad48485a3953bb6124", "x-b3-spanid": "a2fb4a1d1a96d312", "x-b3-sampled": "1"},
+			traceID: "463ac35c9f6413ad48485a3953bb6124",
+		},
+		{
+			name: "w3c wins over b3",
+			carrier: propagation.MapCarrier{
+				"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"x-b3-traceid": "463ac35c9f6413ad48485a3953bb6124",
+				"x-b3-spanid":  "a2fb4a1d1a96d312",
+			},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+	for _, tc := range tests {
+		ctx := NewPropagator().Extract(context.Background(), tc.carrier)
+		if got := trace.SpanContextFromContext(ctx).TraceID().String(); got != tc.traceID {
+			t.Fatalf("%s: trace id = %s, want %s", tc.name, got, tc.traceID)
+		}
+	}
+}
+
+func TestIsTracedRequest(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]bool{
+		"/health":         false,
+		"/health/live":    false,
+		"/health/ready":   false,
+		"/health/started": false,
+		"/metrics":        false,
+		"/publish":        true,
+		"/orders":         true,
+		"/healthz":        true,
+	} {
+		if got := IsTracedRequest(httptest.NewRequest("GET", path, nil)); got != want {
+			t.Fatalf("IsTracedRequest(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package com.agnostic.common.serialization
+
+import com.agnostic.common.events.OrderCreatedV1
+import kotlinx.serialization.Serializable
+import kotlinx.serialization.builtins.ListSerializer
+import kotlinx.serialization.json.Json
+import org.assertj.core.api.Assertions.assertThat
+import org.junit.jupiter.api.Test
+
+/**
+ * Pins the OrderCreatedV1 wire format against golden/order-created-v1-amounts.json.
+ * The Gin services assert the same fixture, so both stacks stay byte-identical.
+ */
+class BigDecimalAsNumberSerializerGoldenTest {
+    @Serializable
+    private data class GoldenCase(
+        val name: String,
+        val input: String,
+        val canonical: String,
+    )
+
+    private val json = Json { encodeDefaults = true }
+
+    private fun goldenCases(): List<GoldenCase> {
+        val fixture =
+            requireNotNull(javaClass.getResource("/golden/order-created-v1-amounts.json")) {
+                "missing golden fixture"
+            }.readText()
+        return json.decodeFromString(ListSerializer(GoldenCase.serializer()), fixture)
+    }
+
+    @Test
+    fun `re-encodes golden amounts byte for byte`() {
+        goldenCases().forEach { case ->
+            val event = JsonSupport.default.decodeFromString(OrderCreatedV1.serializer(), case.input)
+
+            assertThat(json.encodeToString(OrderCreatedV1.serializer(), event))
+                .describedAs(case.name)
+                .isEqualTo(case.canonical)
+        }
+    }
+}
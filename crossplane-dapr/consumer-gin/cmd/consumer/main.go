@@ -1,26 +1,148 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/lifecycle"
 	"github.com/agnostic/crossplane-dapr/consumer-gin/internal/consumer"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run serves until SIGTERM or SIGINT and returns the process exit code. It
+// is separate from main so deferred cleanup runs before the process exits.
+func run() int {
 	cfg := consumer.LoadConfigFromEnv()
 	slog.SetDefault(consumer.Logger())
-	router := consumer.NewRouter(cfg, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+
+	shutdownTelemetry, err := consumer.SetupTelemetry(context.Background(), cfg.ServiceName)
+	if err != nil {
+		slog.Error("failed to configure telemetry", "error", err)
+		return lifecycle.ExitError
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			slog.Warn("failed to flush telemetry", "error", err)
+		}
+	}()
+
+	var subscriptions []consumer.DaprSubscription
+	if cfg.SubscriptionsFile != "" {
+		subscriptions, err = consumer.LoadSubscriptionFile(cfg.SubscriptionsFile)
+		if err != nil {
+			slog.Error("failed to load subscriptions", "path", cfg.SubscriptionsFile, "error", err)
+			return lifecycle.ExitError
+		}
+	} else {
+		subscriptions, err = consumer.DefaultSubscriptions(cfg)
+		if err != nil {
+			slog.Error("failed to configure subscription", "error", err)
+			return lifecycle.ExitError
+		}
+	}
+	routerOpts := []consumer.Option{consumer.WithSubscriptions(subscriptions)}
+	var orders consumer.OrderStore
+	if cfg.Orders.Enabled {
+		store, err := consumer.OpenSQLiteOrderStore(cfg.Orders.Path)
+		if err != nil {
+			slog.Error("failed to open order store", "path", cfg.Orders.Path, "error", err)
+			return lifecycle.ExitError
+		}
+		defer store.Close()
+		orders = store
+		routerOpts = append(routerOpts, consumer.WithOrderStore(store))
+		slog.Info("order store enabled", "path", cfg.Orders.Path)
+	}
+	registry := consumer.DefaultEventRegistry(cfg.CloudEvents.Fallback)
+	registerOrderHandlers(registry, orders)
+	routerOpts = append(routerOpts, consumer.WithEventRegistry(registry))
+
+	if cfg.Quarantine.Enabled {
+		quarantine, err := consumer.OpenSQLiteQuarantineStore(cfg.Quarantine.Path)
+		if err != nil {
+			slog.Error("failed to open quarantine store", "path", cfg.Quarantine.Path, "error", err)
+			return lifecycle.ExitError
+		}
+		defer quarantine.Close()
+		republisher := consumer.NewDaprRepublisher(&http.Client{Timeout: 5 * time.Second}, cfg.DaprBaseURL())
+		routerOpts = append(routerOpts, consumer.WithQuarantine(quarantine, republisher))
+		slog.Info("quarantine enabled", "path", cfg.Quarantine.Path, "deadLetterTopic", cfg.Quarantine.DeadLetterTopic)
+		if cfg.Quarantine.AdminToken == "" {
+			slog.Warn("quarantine admin API disabled; set ADMIN_API_TOKEN to enable it")
+		}
+	}
+
+	inbox, closeInbox, err := consumer.NewInbox(cfg.Inbox)
+	if err != nil {
+		slog.Error("failed to configure inbox", "store", cfg.Inbox.Store, "error", err)
+		return lifecycle.ExitError
+	}
+	defer closeInbox()
+	if inbox != nil {
+		routerOpts = append(routerOpts, consumer.WithInbox(inbox, cfg.Inbox.TTL))
+	}
+
+	pool := consumer.NewWorkerPool(cfg.Workers)
+	routerOpts = append(routerOpts, consumer.WithWorkerPool(pool))
+
+	checks := consumer.NewHealth(cfg, subscriptions, &http.Client{Timeout: 5 * time.Second})
+	routerOpts = append(routerOpts, consumer.WithHealth(checks))
+
+	router := consumer.NewRouter(cfg, prometheus.DefaultRegisterer, prometheus.DefaultGatherer, routerOpts...)
 
 	slog.Info("starting consumer-gin",
 		"port", cfg.Port,
-		"pubsub", cfg.PubSubName,
-		"topic", cfg.TopicName,
-		"route", cfg.SubscriptionRoute,
+		"subscriptions", len(subscriptions),
+		"pubsub", subscriptions[0].PubSubName,
+		"topic", subscriptions[0].Topic,
+		"workers", cfg.Workers.Concurrency,
 	)
-	if err := router.Run(":" + cfg.Port); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router, ReadHeaderTimeout: 10 * time.Second}
+	err = lifecycle.Run(ctx, server,
+		lifecycle.WithConfig(cfg.Shutdown),
+		lifecycle.WithHealth(checks),
+		lifecycle.WithStopHook("worker pool", func(ctx context.Context) error {
+			if err := pool.Drain(ctx); err != nil {
+				slog.Warn("failed to drain worker pool", "inFlight", pool.InFlight(), "queued", pool.QueueDepth(), "error", err)
+				return err
+			}
+			return nil
+		}),
+	)
+	if err != nil {
 		slog.Error("consumer-gin stopped with error", "error", err)
-		return
+		return lifecycle.ExitCode(err)
 	}
 	slog.Info("consumer-gin stopped")
+	return lifecycle.ExitOK
+}
+
+// registerOrderHandlers handles both order models as received, without
+// upcasting v1 to v2, and stores them when orders is set.
+func registerOrderHandlers(registry *consumer.EventRegistry, orders consumer.OrderStore) {
+	handle := func(ctx context.Context, event consumer.OrderEvent) error {
+		if orders == nil {
+			return nil
+		}
+		return orders.Handle(ctx, event)
+	}
+	consumer.HandleEvent(registry, func(ctx context.Context, event consumer.OrderCreatedV1) error {
+		return handle(ctx, event)
+	})
+	consumer.HandleEvent(registry, func(ctx context.Context, event consumer.OrderCreatedV2) error {
+		return handle(ctx, event)
+	})
 }
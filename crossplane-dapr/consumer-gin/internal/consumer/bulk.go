@@ -0,0 +1,176 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultBulkConcurrency = 8
+
+// BulkSubscribeConfig opts the default subscription into Dapr bulk
+// subscribe. Dapr delivers up to MaxMessagesCount messages per request,
+// waiting at most MaxAwaitDurationMs to fill one; consumer-gin processes the
+// entries of Concurrency partition keys of a request at a time.
+type BulkSubscribeConfig struct {
+	Enabled            bool
+	MaxMessagesCount   int
+	MaxAwaitDurationMs int
+	Concurrency        int
+}
+
+func (c BulkSubscribeConfig) concurrency() int {
+	if c.Concurrency <= 0 {
+		return defaultBulkConcurrency
+	}
+	return c.Concurrency
+}
+
+// bulkSubscribeRequest is the body Dapr posts to a bulk subscription route.
+type bulkSubscribeRequest struct {
+	ID         string               `json:"id"`
+	Entries    []bulkSubscribeEntry `json:"entries"`
+	PubSubName string               `json:"pubsubname"`
+	Topic      string               `json:"topic"`
+	Type       string               `json:"type"`
+}
+
+type bulkSubscribeEntry struct {
+	EntryID     string            `json:"entryId"`
+	Event       json.RawMessage   `json:"event"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type bulkSubscribeStatus struct {
+	EntryID string             `json:"entryId"`
+	Status  SubscriptionStatus `json:"status"`
+}
+
+type bulkSubscribeResponse struct {
+	Statuses []bulkSubscribeStatus `json:"statuses"`
+}
+
+// parseBulkSubscribeRequest reports whether payload is a bulk subscribe
+// request, recognised by its top-level entries array.
+func parseBulkSubscribeRequest(payload []byte) (bulkSubscribeRequest, bool) {
+	var probe struct {
+		Entries json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil || !bytes.HasPrefix(bytes.TrimSpace(probe.Entries), []byte("[")) {
+		return bulkSubscribeRequest{}, false
+	}
+	var request bulkSubscribeRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return bulkSubscribeRequest{}, false
+	}
+	return request, true
+}
+
+// payload returns the message of the entry: the CloudEvent object, or the
+// raw payload Dapr passes as a JSON string.
+func (e bulkSubscribeEntry) payload() ([]byte, error) {
+	event := bytes.TrimSpace(e.Event)
+	if len(event) == 0 || string(event) == "null" {
+		return nil, errors.New("entry has no event")
+	}
+	if event[0] == '"' {
+		var raw string
+		if err := json.Unmarshal(event, &raw); err != nil {
+			return nil, fmt.Errorf("decode entry event: %w", err)
+		}
+		return []byte(raw), nil
+	}
+	return event, nil
+}
+
+// header returns the headers the entry would carry as a single delivery.
+func (e bulkSubscribeEntry) header() http.Header {
+	header := http.Header{}
+	for name, value := range e.Metadata {
+		header.Set(name, value)
+	}
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	return header
+}
+
+// serveBulk answers with one status per entry. Entries of one partition key
+// run in order, and once one is retried the later ones are retried unprocessed
+// so redelivery keeps their order.
+func serveBulk(c *gin.Context, request bulkSubscribeRequest, concurrency int, metrics consumeMetrics, consumer messageConsumer) {
+	requestLogger := loggerFromGinContext(c)
+	start := time.Now()
+	metrics.bulkSize.Observe(float64(len(request.Entries)))
+
+	statuses := make([]bulkSubscribeStatus, len(request.Entries))
+	messages := make([]message, len(request.Entries))
+	groups := map[string][]int{}
+	var keys []string
+	for i, entry := range request.Entries {
+		statuses[i].EntryID = entry.EntryID
+		payload, err := entry.payload()
+		if err != nil {
+			requestLogger.WarnContext(c.Request.Context(), "dropping undecodable bulk entry", "entryId", entry.EntryID, "error", err)
+			statuses[i].Status = SubscriptionDrop
+			continue
+		}
+		entryRequest := c.Request.Clone(c.Request.Context())
+		entryRequest.Header = entry.header()
+		messages[i] = consumer.decode(entryRequest, payload)
+		entryKey := messages[i].key()
+		if entryKey == "" {
+			entryKey = "\x00entry-" + strconv.Itoa(i)
+		}
+		if _, ok := groups[entryKey]; !ok {
+			keys = append(keys, entryKey)
+		}
+		groups[entryKey] = append(groups[entryKey], i)
+	}
+
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for _, entryKey := range keys {
+		indexes := groups[entryKey]
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			retrying := false
+			for _, i := range indexes {
+				if retrying {
+					statuses[i].Status = SubscriptionRetry
+					continue
+				}
+				statuses[i].Status, _ = consumeMessage(c.Request.Context(), consumer, messages[i])
+				retrying = statuses[i].Status == SubscriptionRetry
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := map[SubscriptionStatus]int{}
+	for _, status := range statuses {
+		metrics.observe(status.Status)
+		counts[status.Status]++
+	}
+	metrics.bulkDuration.Observe(time.Since(start).Seconds())
+	requestLogger.InfoContext(c.Request.Context(), "processed bulk subscribe request",
+		"bulkId", request.ID,
+		"topic", request.Topic,
+		"entries", len(statuses),
+		"succeeded", counts[SubscriptionSuccess],
+		"retried", counts[SubscriptionRetry],
+		"dropped", counts[SubscriptionDrop],
+	)
+	c.JSON(http.StatusOK, bulkSubscribeResponse{Statuses: statuses})
+}
@@ -0,0 +1,262 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func postBulk(t *testing.T, router http.Handler, entries ...string) bulkSubscribeResponse {
+	t.Helper()
+	body := `{"id":"bulk-1","pubsubname":"order-pubsub","topic":"orders","type":"com.dapr.event.sent","entries":[`
+	for i, entry := range entries {
+		if i > 0 {
+			body += ","
+		}
+		body += entry
+	}
+	body += "]}"
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("bulk POST = %d %s", res.Code, res.Body.String())
+	}
+	var response bulkSubscribeResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode bulk response %q: %v", res.Body.String(), err)
+	}
+	return response
+}
+
+func histogramCount(t *testing.T, gatherer prometheus.Gatherer, name string) (count uint64, sum float64) {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			histogram := family.GetMetric()[0].GetHistogram()
+			return histogram.GetSampleCount(), histogram.GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestDaprSubscribeDeclaresBulkSubscribe(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		PubSubName:        "order-pubsub",
+		TopicName:         "orders",
+		SubscriptionRoute: "/orders",
+		Bulk:              BulkSubscribeConfig{Enabled: true, MaxMessagesCount: 100, MaxAwaitDurationMs: 40},
+	}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry)
+
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	want := `[{"pubsubname":"order-pubsub","topic":"orders","route":"/orders",` +
+		`"bulkSubscribe":{"enabled":true,"maxMessagesCount":100,"maxAwaitDurationMs":40}}]`
+	if res.Code != http.StatusOK || res.Body.String() != want {
+		t.Fatalf("got %d %s, want 200 %s", res.Code, res.Body.String(), want)
+	}
+}
+
+func TestBulkSubscribeReturnsPerEntryStatuses(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(_ context.Context, event OrderEvent) error {
+		switch event.OrderID() {
+		case "ORD-RETRY":
+			return errors.New("database is locked")
+		case "ORD-DROP":
+			return fmt.Errorf("unknown customer: %w", ErrDropEvent)
+		}
+		return nil
+	})))
+
+	response := postBulk(t, router,
+		`{"entryId":"1","contentType":"application/cloudevents+json","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":{"id":"ORD-1","amount":10}}}`,
+		`{"entryId":"2","contentType":"application/json","event":"{\"id\":\"ORD-2\",\"amount\":5}"}`,
+		`{"entryId":"3","contentType":"application/cloudevents+json","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-3","source":"producer-gin","data":{"id":"ORD-RETRY","amount":1}}}`,
+		`{"entryId":"4","contentType":"application/cloudevents+json","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-4","source":"producer-gin","data":{"id":"ORD-DROP","amount":1}}}`,
+		`{"entryId":"5","contentType":"application/cloudevents+json","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-5","source":"producer-gin","data":{"amount":1}}}`,
+		`{"entryId":"6","contentType":"application/json"}`,
+	)
+
+	want := []bulkSubscribeStatus{
+		{"1", SubscriptionSuccess},
+		{"2", SubscriptionSuccess},
+		{"3", SubscriptionRetry},
+		{"4", SubscriptionDrop},
+		{"5", SubscriptionDrop},
+		{"6", SubscriptionDrop},
+	}
+	if len(response.Statuses) != len(want) {
+		t.Fatalf("statuses = %+v, want %+v", response.Statuses, want)
+	}
+	for i := range want {
+		if response.Statuses[i] != want[i] {
+			t.Fatalf("statuses = %+v, want %+v", response.Statuses, want)
+		}
+	}
+
+	for status, wantCount := range map[SubscriptionStatus]float64{SubscriptionSuccess: 2, SubscriptionRetry: 1, SubscriptionDrop: 3} {
+		if got := outcomeCount(t, registry, status); got != wantCount {
+			t.Fatalf("%s outcomes = %v, want %v", status, got, wantCount)
+		}
+	}
+	if count, sum := histogramCount(t, registry, "orders_consume_bulk_batch_size"); count != 1 || sum != 6 {
+		t.Fatalf("batch size histogram = %d samples, sum %v, want 1 sample of 6", count, sum)
+	}
+	if count, _ := histogramCount(t, registry, "orders_consume_bulk_duration_seconds"); count != 1 {
+		t.Fatalf("bulk duration histogram = %d samples, want 1", count)
+	}
+}
+
+func TestPanickingHandlerIsRetriedAloneAndInBulk(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(_ context.Context, event OrderEvent) error {
+		if event.OrderID() == "ORD-PANIC" {
+			panic("handler bug")
+		}
+		return nil
+	})))
+
+	if status := deliver(t, router, "/orders", `{"data":{"id":"ORD-PANIC","amount":1}}`, http.Header{}); status != SubscriptionRetry {
+		t.Fatalf("single delivery status = %s, want RETRY", status)
+	}
+	response := postBulk(t, router,
+		`{"entryId":"1","contentType":"application/json","event":"{\"id\":\"ORD-PANIC\",\"amount\":1}"}`,
+		`{"entryId":"2","contentType":"application/json","event":"{\"id\":\"ORD-1\",\"amount\":1}"}`,
+	)
+	want := []bulkSubscribeStatus{{"1", SubscriptionRetry}, {"2", SubscriptionSuccess}}
+	if len(response.Statuses) != len(want) || response.Statuses[0] != want[0] || response.Statuses[1] != want[1] {
+		t.Fatalf("statuses = %+v, want %+v", response.Statuses, want)
+	}
+}
+
+func TestBulkSubscribeBoundsConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, peak atomic.Int32
+	cfg := Config{
+		PubSubName:        "order-pubsub",
+		TopicName:         "orders",
+		SubscriptionRoute: "/orders",
+		Bulk:              BulkSubscribeConfig{Concurrency: 2},
+	}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(context.Context, OrderEvent) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})))
+
+	var entries []string
+	for i := range 8 {
+		entries = append(entries, fmt.Sprintf(`{"entryId":"%d","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-%d","source":"producer-gin","data":{"id":"ORD-%d","amount":1}}}`, i, i, i))
+	}
+	response := postBulk(t, router, entries...)
+
+	for _, status := range response.Statuses {
+		if status.Status != SubscriptionSuccess {
+			t.Fatalf("statuses = %+v, want all SUCCESS", response.Statuses)
+		}
+	}
+	if got := peak.Load(); got < 1 || got > 2 {
+		t.Fatalf("peak concurrency = %d, want at most 2", got)
+	}
+}
+
+func TestBulkSubscribeKeepsPerKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders", Bulk: BulkSubscribeConfig{Concurrency: 4}}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(ctx context.Context, event OrderEvent) error {
+		id := event.OrderID()
+		// Earlier entries take longer, so running a key's entries
+		// concurrently would finish them out of order.
+		step, _ := strconv.Atoi(id[strings.LastIndex(id, "-")+1:])
+		time.Sleep(time.Duration(5-step) * 5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		metadata, _ := CloudEventMetadataFromContext(ctx)
+		key := metadata.Extensions["partitionkey"]
+		handled[key] = append(handled[key], id)
+		if id == "ORD-C-1" {
+			return errors.New("database is locked")
+		}
+		return nil
+	})))
+
+	entry := func(entryID, key, orderID string) string {
+		return fmt.Sprintf(`{"entryId":"%s","event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-%s","source":"producer-gin","partitionkey":"%s","data":{"id":"%s","amount":1}}}`, entryID, entryID, key, orderID)
+	}
+	response := postBulk(t, router,
+		entry("1", "cust-a", "ORD-A-1"),
+		entry("2", "cust-b", "ORD-B-1"),
+		entry("3", "cust-a", "ORD-A-2"),
+		entry("4", "cust-c", "ORD-C-1"),
+		entry("5", "cust-a", "ORD-A-3"),
+		entry("6", "cust-b", "ORD-B-2"),
+		entry("7", "cust-c", "ORD-C-2"),
+		entry("8", "cust-a", "ORD-A-4"),
+	)
+
+	want := map[string][]string{
+		"cust-a": {"ORD-A-1", "ORD-A-2", "ORD-A-3", "ORD-A-4"},
+		"cust-b": {"ORD-B-1", "ORD-B-2"},
+		"cust-c": {"ORD-C-1"},
+	}
+	for key, ids := range want {
+		if got := handled[key]; strings.Join(got, ",") != strings.Join(ids, ",") {
+			t.Fatalf("%s handled %v, want %v", key, got, ids)
+		}
+	}
+	// ORD-C-2 must wait for the redelivery of ORD-C-1.
+	for i, status := range response.Statuses {
+		wantStatus := SubscriptionSuccess
+		if status.EntryID == "4" || status.EntryID == "7" {
+			wantStatus = SubscriptionRetry
+		}
+		if status.Status != wantStatus {
+			t.Fatalf("status %d = %+v, want %s", i, status, wantStatus)
+		}
+	}
+}
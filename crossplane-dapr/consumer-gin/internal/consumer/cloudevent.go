@@ -0,0 +1,276 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// OrderCreatedV1Type is the CloudEvents type of OrderCreatedV1 events.
+	OrderCreatedV1Type = "com.agnostic.order.created.v1"
+	// OrderCreatedV2Type is the CloudEvents type of OrderCreatedV2 events.
+	OrderCreatedV2Type = "com.agnostic.order.created.v2"
+	// daprEventType is the type Dapr gives events it wraps itself; the
+	// version then comes from the eventVersion field of the data.
+	daprEventType = "com.dapr.event.sent"
+
+	cloudEventsSpecVersion = "1.0"
+	binaryHeaderPrefix     = "ce-"
+)
+
+// CloudEvent content modes reported in CloudEventMetadata.Mode. Raw
+// payloads are not CloudEvents: either a bare event or a legacy
+// {"data":...} envelope without specversion.
+const (
+	CloudEventStructured = "structured"
+	CloudEventBinary     = "binary"
+	CloudEventRaw        = "raw"
+)
+
+// CloudEventMetadata holds the context attributes of a delivered event.
+// Extensions holds every other attribute, such as traceparent or topic,
+// with non-string values as raw JSON. Raw payloads only set Mode and, for
+// legacy envelopes, the traceparent and tracestate extensions.
+type CloudEventMetadata struct {
+	Mode            string            `json:"mode"`
+	SpecVersion     string            `json:"specversion,omitempty"`
+	ID              string            `json:"id,omitempty"`
+	Source          string            `json:"source,omitempty"`
+	Type            string            `json:"type,omitempty"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            time.Time         `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	DataSchema      string            `json:"dataschema,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// UnknownTypePolicy decides what happens to CloudEvents whose type is not
+// an order event type.
+type UnknownTypePolicy string
+
+const (
+	// UnknownTypeReject drops the event.
+	UnknownTypeReject UnknownTypePolicy = "reject"
+	// UnknownTypeIgnore acknowledges the event without handling it.
+	UnknownTypeIgnore UnknownTypePolicy = "ignore"
+	// UnknownTypeAccept decodes the data by its eventVersion field.
+	UnknownTypeAccept UnknownTypePolicy = "accept"
+)
+
+func ParseUnknownTypePolicy(value string) (UnknownTypePolicy, error) {
+	switch policy := UnknownTypePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case UnknownTypeReject, UnknownTypeIgnore, UnknownTypeAccept:
+		return policy, nil
+	case "":
+		return UnknownTypeReject, nil
+	default:
+		return "", fmt.Errorf("unsupported unknown event type policy %q", value)
+	}
+}
+
+var (
+	// ErrUnknownEventType is returned for CloudEvents whose type is not an
+	// order event type unless the policy is UnknownTypeAccept.
+	ErrUnknownEventType = errors.New("unknown event type")
+
+	errInvalidCloudEvent     = errors.New("invalid cloudevent")
+	errEventTypeVersionClash = errors.New("event type and eventVersion disagree")
+)
+
+// CloudEventDecoder decodes deliveries in structured or binary CloudEvents
+// mode, falling back to raw payloads for messages without specversion. The
+// Registry, DefaultEventRegistry when nil, maps types and versions to models.
+type CloudEventDecoder struct {
+	UnknownTypes UnknownTypePolicy
+	Registry     *EventRegistry
+}
+
+// Decode returns the order event carried by a delivery and its CloudEvents
+// metadata. header holds the request headers; binary mode is recognised by
+// ce-specversion.
+func (d CloudEventDecoder) Decode(header http.Header, payload []byte) (OrderEvent, CloudEventMetadata, error) {
+	metadata, data, err := decodeCloudEvent(header, payload)
+	if err != nil {
+		return nil, metadata, err
+	}
+	registry := d.Registry
+	if registry == nil {
+		registry = defaultEventRegistry
+	}
+	if metadata.Mode == CloudEventRaw || metadata.Type == daprEventType {
+		event, err := registry.decode(data, "")
+		return event, metadata, err
+	}
+	if version, ok := registry.eventVersion(metadata.Type); ok {
+		event, err := registry.decode(data, version)
+		return event, metadata, err
+	}
+	if d.UnknownTypes == UnknownTypeAccept {
+		event, err := registry.decode(data, "")
+		return event, metadata, err
+	}
+	return nil, metadata, fmt.Errorf("%w %q", ErrUnknownEventType, metadata.Type)
+}
+
+func decodeCloudEvent(header http.Header, payload []byte) (CloudEventMetadata, []byte, error) {
+	if header.Get(binaryHeaderPrefix+"specversion") != "" {
+		return decodeBinaryCloudEvent(header, payload)
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &attributes); err != nil || attributes["specversion"] == nil {
+		metadata := CloudEventMetadata{Mode: CloudEventRaw}
+		data := attributes["data"]
+		if len(data) == 0 {
+			return metadata, payload, nil
+		}
+		for _, name := range []string{"traceparent", "tracestate"} {
+			var value string
+			if json.Unmarshal(attributes[name], &value) == nil && value != "" {
+				if metadata.Extensions == nil {
+					metadata.Extensions = map[string]string{}
+				}
+				metadata.Extensions[name] = value
+			}
+		}
+		return metadata, data, nil
+	}
+	return decodeStructuredCloudEvent(attributes)
+}
+
+func decodeStructuredCloudEvent(attributes map[string]json.RawMessage) (CloudEventMetadata, []byte, error) {
+	metadata := CloudEventMetadata{Mode: CloudEventStructured}
+	values := make(map[string]string, len(attributes))
+	for name, raw := range attributes {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		values[name] = value
+	}
+	if err := metadata.setAttributes(values); err != nil {
+		return metadata, nil, err
+	}
+
+	data, hasData := attributes["data"]
+	encoded, hasBase64 := attributes["data_base64"]
+	switch {
+	case hasData && hasBase64:
+		return metadata, nil, fmt.Errorf("%w: data and data_base64 are mutually exclusive", errInvalidCloudEvent)
+	case hasBase64:
+		var text string
+		if err := json.Unmarshal(encoded, &text); err != nil {
+			return metadata, nil, fmt.Errorf("%w: data_base64 must be a string", errInvalidCloudEvent)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return metadata, nil, fmt.Errorf("%w: data_base64: %v", errInvalidCloudEvent, err)
+		}
+		return metadata, decoded, nil
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)):
+		// JSON carried as a string, as some publishers send text data.
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return metadata, nil, fmt.Errorf("%w: data: %v", errInvalidCloudEvent, err)
+		}
+		return metadata, []byte(text), nil
+	}
+	return metadata, data, nil
+}
+
+func decodeBinaryCloudEvent(header http.Header, payload []byte) (CloudEventMetadata, []byte, error) {
+	metadata := CloudEventMetadata{Mode: CloudEventBinary}
+	values := map[string]string{}
+	for name, headerValues := range header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, binaryHeaderPrefix) && len(headerValues) > 0 {
+			values[strings.TrimPrefix(name, binaryHeaderPrefix)] = headerValues[0]
+		}
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		values["datacontenttype"] = contentType
+	}
+	if err := metadata.setAttributes(values); err != nil {
+		return metadata, nil, err
+	}
+	return metadata, payload, nil
+}
+
+// setAttributes validates the context attributes and keeps the rest as
+// extensions.
+func (m *CloudEventMetadata) setAttributes(values map[string]string) error {
+	for name, value := range values {
+		switch name {
+		case "specversion":
+			m.SpecVersion = value
+		case "id":
+			m.ID = value
+		case "source":
+			m.Source = value
+		case "type":
+			m.Type = value
+		case "subject":
+			m.Subject = value
+		case "datacontenttype":
+			m.DataContentType = value
+		case "dataschema":
+			m.DataSchema = value
+		case "time":
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return fmt.Errorf("%w: time must be an RFC 3339 timestamp", errInvalidCloudEvent)
+			}
+			m.Time = parsed
+		default:
+			if m.Extensions == nil {
+				m.Extensions = map[string]string{}
+			}
+			m.Extensions[name] = value
+		}
+	}
+
+	if m.SpecVersion != cloudEventsSpecVersion {
+		return fmt.Errorf("%w: unsupported specversion %q", errInvalidCloudEvent, m.SpecVersion)
+	}
+	for _, required := range []struct{ name, value string }{{"id", m.ID}, {"source", m.Source}, {"type", m.Type}} {
+		if strings.TrimSpace(required.value) == "" {
+			return fmt.Errorf("%w: %s must not be blank", errInvalidCloudEvent, required.name)
+		}
+	}
+	if m.DataContentType != "" && !isJSONContentType(m.DataContentType) {
+		return fmt.Errorf("%w: unsupported datacontenttype %q", errInvalidCloudEvent, m.DataContentType)
+	}
+	return nil
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+type cloudEventMetadataKey struct{}
+
+func withCloudEventMetadata(ctx context.Context, metadata CloudEventMetadata) context.Context {
+	return context.WithValue(ctx, cloudEventMetadataKey{}, metadata)
+}
+
+// CloudEventMetadataFromContext returns the metadata of the event an
+// OrderEventHandler is called for.
+func CloudEventMetadataFromContext(ctx context.Context) (CloudEventMetadata, bool) {
+	metadata, ok := ctx.Value(cloudEventMetadataKey{}).(CloudEventMetadata)
+	return metadata, ok
+}
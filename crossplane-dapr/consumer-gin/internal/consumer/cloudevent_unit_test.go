@@ -0,0 +1,259 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCloudEventDecoder(t *testing.T) {
+	t.Parallel()
+
+	v1Data := `{"id":"ORD-1","amount":10,"eventVersion":"v1"}`
+	tests := []struct {
+		name        string
+		header      http.Header
+		payload     string
+		policy      UnknownTypePolicy
+		wantID      string
+		wantVersion string
+		want        CloudEventMetadata
+	}{
+		{
+			name:        "structured v1",
+			payload:     `{"specversion":"1.0","id":"evt-1","source":"producer-gin","type":"com.agnostic.order.created.v1","subject":"ORD-1","time":"2026-03-01T12:00:00Z","datacontenttype":"application/json","traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01","topic":"orders","data":` + v1Data + `}`,
+			wantID:      "ORD-1",
+			wantVersion: "v1",
+			want: CloudEventMetadata{
+				Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-1", Source: "producer-gin", Type: OrderCreatedV1Type,
+				Subject: "ORD-1", Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), DataContentType: "application/json",
+				Extensions: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "topic": "orders"},
+			},
+		},
+		{
+			name:        "type selects v2 without eventVersion",
+			payload:     `{"specversion":"1.0","id":"evt-2","source":"producer-gin","type":"com.agnostic.order.created.v2","data":{"id":"ORD-2","amount":1,"currency":"EUR"}}`,
+			wantID:      "ORD-2",
+			wantVersion: "v2",
+			want:        CloudEventMetadata{Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-2", Source: "producer-gin", Type: OrderCreatedV2Type},
+		},
+		{
+			name:        "data_base64",
+			payload:     `{"specversion":"1.0","id":"evt-3","source":"producer-gin","type":"com.agnostic.order.created.v1","datacontenttype":"application/json","data_base64":"eyJpZCI6Ik9SRC0zIiwiYW1vdW50IjoxfQ=="}`,
+			wantID:      "ORD-3",
+			wantVersion: "v1",
+			want:        CloudEventMetadata{Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-3", Source: "producer-gin", Type: OrderCreatedV1Type, DataContentType: "application/json"},
+		},
+		{
+			name:        "data as JSON string",
+			payload:     `{"specversion":"1.0","id":"evt-4","source":"producer-gin","type":"com.agnostic.order.created.v1","data":"{\"id\":\"ORD-4\",\"amount\":1}"}`,
+			wantID:      "ORD-4",
+			wantVersion: "v1",
+			want:        CloudEventMetadata{Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-4", Source: "producer-gin", Type: OrderCreatedV1Type},
+		},
+		{
+			name: "binary mode",
+			header: http.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"evt-5"},
+				"Ce-Source":      {"producer-gin"},
+				"Ce-Type":        {"com.agnostic.order.created.v1"},
+				"Ce-Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+				"Content-Type":   {"application/json"},
+			},
+			payload:     `{"id":"ORD-5","amount":1}`,
+			wantID:      "ORD-5",
+			wantVersion: "v1",
+			want: CloudEventMetadata{
+				Mode: CloudEventBinary, SpecVersion: "1.0", ID: "evt-5", Source: "producer-gin", Type: OrderCreatedV1Type, DataContentType: "application/json",
+				Extensions: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			},
+		},
+		{
+			name:        "dapr wrapped event",
+			payload:     `{"specversion":"1.0","id":"evt-6","source":"producer-ktor","type":"com.dapr.event.sent","data":{"id":"ORD-6","amount":1,"eventVersion":"v2"}}`,
+			wantID:      "ORD-6",
+			wantVersion: "v2",
+			want:        CloudEventMetadata{Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-6", Source: "producer-ktor", Type: daprEventType},
+		},
+		{
+			name:        "unknown type accepted",
+			payload:     `{"specversion":"1.0","id":"evt-7","source":"legacy","type":"com.example.order","data":` + v1Data + `}`,
+			policy:      UnknownTypeAccept,
+			wantID:      "ORD-1",
+			wantVersion: "v1",
+			want:        CloudEventMetadata{Mode: CloudEventStructured, SpecVersion: "1.0", ID: "evt-7", Source: "legacy", Type: "com.example.order"},
+		},
+		{
+			name:        "raw payload",
+			payload:     v1Data,
+			wantID:      "ORD-1",
+			wantVersion: "v1",
+			want:        CloudEventMetadata{Mode: CloudEventRaw},
+		},
+		{
+			name:        "legacy envelope",
+			payload:     `{"tracestate":"vendor=1","data":` + v1Data + `}`,
+			wantID:      "ORD-1",
+			wantVersion: "v1",
+			want:        CloudEventMetadata{Mode: CloudEventRaw, Extensions: map[string]string{"tracestate": "vendor=1"}},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			event, metadata, err := CloudEventDecoder{UnknownTypes: tc.policy}.Decode(tc.header, []byte(tc.payload))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if event.OrderID() != tc.wantID || event.Version() != tc.wantVersion {
+				t.Fatalf("event = %+v, want %s %s", event, tc.wantID, tc.wantVersion)
+			}
+			if !metadata.Time.Equal(tc.want.Time) {
+				t.Fatalf("time = %v, want %v", metadata.Time, tc.want.Time)
+			}
+			metadata.Time, tc.want.Time = time.Time{}, time.Time{}
+			if metadata.Mode != tc.want.Mode || metadata.SpecVersion != tc.want.SpecVersion || metadata.ID != tc.want.ID ||
+				metadata.Source != tc.want.Source || metadata.Type != tc.want.Type || metadata.Subject != tc.want.Subject ||
+				metadata.DataContentType != tc.want.DataContentType || len(metadata.Extensions) != len(tc.want.Extensions) {
+				t.Fatalf("metadata = %+v, want %+v", metadata, tc.want)
+			}
+			for name, value := range tc.want.Extensions {
+				if metadata.Extensions[name] != value {
+					t.Fatalf("extension %s = %q, want %q", name, metadata.Extensions[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudEventDecoderRejectsInvalidEvents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		payload string
+		wantErr string
+	}{
+		{name: "unsupported specversion", payload: `{"specversion":"0.3","id":"e","source":"s","type":"com.agnostic.order.created.v1","data":{"id":"ORD-1"}}`, wantErr: `unsupported specversion "0.3"`},
+		{name: "missing id", payload: `{"specversion":"1.0","source":"s","type":"com.agnostic.order.created.v1","data":{"id":"ORD-1"}}`, wantErr: "id must not be blank"},
+		{name: "missing source", payload: `{"specversion":"1.0","id":"e","type":"com.agnostic.order.created.v1","data":{"id":"ORD-1"}}`, wantErr: "source must not be blank"},
+		{name: "missing type", payload: `{"specversion":"1.0","id":"e","source":"s","data":{"id":"ORD-1"}}`, wantErr: "type must not be blank"},
+		{name: "data and data_base64", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.agnostic.order.created.v1","data":{},"data_base64":"e30="}`, wantErr: "mutually exclusive"},
+		{name: "invalid base64", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.agnostic.order.created.v1","data_base64":"%%%"}`, wantErr: "data_base64"},
+		{name: "invalid time", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.agnostic.order.created.v1","time":"yesterday","data":{"id":"ORD-1"}}`, wantErr: "time must be an RFC 3339 timestamp"},
+		{name: "non-JSON data", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.agnostic.order.created.v1","datacontenttype":"application/xml","data":"<order/>"}`, wantErr: `unsupported datacontenttype "application/xml"`},
+		{name: "type contradicts eventVersion", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.agnostic.order.created.v2","data":{"id":"ORD-1","eventVersion":"v1"}}`, wantErr: "event type and eventVersion disagree"},
+		{name: "unknown type", payload: `{"specversion":"1.0","id":"e","source":"s","type":"com.example.order","data":{"id":"ORD-1"}}`, wantErr: `unknown event type "com.example.order"`},
+		{name: "binary without id", header: http.Header{"Ce-Specversion": {"1.0"}, "Ce-Source": {"s"}, "Ce-Type": {"com.agnostic.order.created.v1"}}, payload: `{"id":"ORD-1"}`, wantErr: "id must not be blank"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := CloudEventDecoder{UnknownTypes: UnknownTypeReject}.Decode(tc.header, []byte(tc.payload))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Decode() error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseUnknownTypePolicy(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]UnknownTypePolicy{"": UnknownTypeReject, "REJECT": UnknownTypeReject, "ignore": UnknownTypeIgnore, " accept ": UnknownTypeAccept} {
+		if got, err := ParseUnknownTypePolicy(value); err != nil || got != want {
+			t.Fatalf("ParseUnknownTypePolicy(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseUnknownTypePolicy("skip"); err == nil {
+		t.Fatal("ParseUnknownTypePolicy(skip) error = nil")
+	}
+}
+
+func TestConsumeAppliesUnknownTypePolicyAndPassesMetadata(t *testing.T) {
+	t.Parallel()
+
+	unknown := `{"specversion":"1.0","id":"evt-1","source":"legacy","type":"com.example.order","data":{"id":"ORD-1","amount":1}}`
+	for _, tc := range []struct {
+		policy      UnknownTypePolicy
+		want        SubscriptionStatus
+		wantHandled bool
+		wantIgnored float64
+	}{
+		{policy: UnknownTypeReject, want: SubscriptionDrop},
+		{policy: UnknownTypeIgnore, want: SubscriptionSuccess, wantIgnored: 1},
+		{policy: UnknownTypeAccept, want: SubscriptionSuccess, wantHandled: true},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			t.Parallel()
+
+			var handled []CloudEventMetadata
+			cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders", CloudEvents: CloudEventsConfig{UnknownTypes: tc.policy}}
+			registry := prometheus.NewRegistry()
+			router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(ctx context.Context, _ OrderEvent) error {
+				metadata, ok := CloudEventMetadataFromContext(ctx)
+				if !ok {
+					return errors.New("no cloudevent metadata in context")
+				}
+				handled = append(handled, metadata)
+				return nil
+			})))
+
+			if got := deliver(t, router, "/orders", unknown, http.Header{}); got != tc.want {
+				t.Fatalf("status = %s, want %s", got, tc.want)
+			}
+			if tc.wantHandled != (len(handled) == 1) {
+				t.Fatalf("handled = %+v, want handled %v", handled, tc.wantHandled)
+			}
+			if tc.wantHandled && (handled[0].Type != "com.example.order" || handled[0].Source != "legacy" || handled[0].Mode != CloudEventStructured) {
+				t.Fatalf("handler metadata = %+v", handled[0])
+			}
+			if got := counterValue(t, registry, "orders_consume_ignored_total", "", ""); got != tc.wantIgnored {
+				t.Fatalf("ignored = %v, want %v", got, tc.wantIgnored)
+			}
+		})
+	}
+}
+
+func TestConsumeAcceptsBinaryModeCloudEvents(t *testing.T) {
+	t.Parallel()
+
+	var metadata CloudEventMetadata
+	registry := prometheus.NewRegistry()
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(OrderEventHandlerFunc(func(ctx context.Context, _ OrderEvent) error {
+		metadata, _ = CloudEventMetadataFromContext(ctx)
+		return nil
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"id":"ORD-1","amount":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", "1.0")
+	req.Header.Set("ce-id", "evt-1")
+	req.Header.Set("ce-source", "producer-gin")
+	req.Header.Set("ce-type", OrderCreatedV1Type)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), string(SubscriptionSuccess)) {
+		t.Fatalf("got %d %s, want 200 SUCCESS", res.Code, res.Body.String())
+	}
+	if metadata.Mode != CloudEventBinary || metadata.ID != "evt-1" || metadata.Type != OrderCreatedV1Type {
+		t.Fatalf("handler metadata = %+v", metadata)
+	}
+}
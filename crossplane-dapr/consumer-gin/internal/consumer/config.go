@@ -2,25 +2,106 @@ package consumer
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/lifecycle"
 )
 
 type Config struct {
 	Port              string
+	ServiceName       string
 	PubSubName        string
 	TopicName         string
 	SubscriptionRoute string
+	// SubscriptionsFile, when set, replaces the single subscription above
+	// with the YAML or JSON subscription list at this path.
+	SubscriptionsFile string
+	Orders            OrderStoreConfig
+	Inbox             InboxConfig
+	Quarantine        QuarantineConfig
+	Bulk              BulkSubscribeConfig
+	CloudEvents       CloudEventsConfig
+	Workers           WorkerPoolConfig
+	Health            HealthConfig
+	Shutdown          lifecycle.Config
+	AccessLog         accesslog.Config
+	DaprHTTPPort      string
+}
+
+// CloudEventsConfig controls how deliveries are decoded. UnknownTypes
+// applies to CloudEvents whose type is not an order event type, Fallback to
+// events the EventRegistry cannot dispatch.
+type CloudEventsConfig struct {
+	UnknownTypes UnknownTypePolicy
+	Fallback     FallbackPolicy
+}
+
+// OrderStoreConfig selects the SQLite store that persists consumed orders
+// and backs GET /orders. When disabled, events are only logged.
+type OrderStoreConfig struct {
+	Enabled bool
+	Path    string
 }
 
 func LoadConfigFromEnv() Config {
 	return Config{
 		Port:              envOrDefault("PORT", "8080"),
+		ServiceName:       envOrDefault("OTEL_SERVICE_NAME", defaultServiceName),
 		PubSubName:        envOrDefault("DAPR_PUBSUB_NAME", "order-pubsub"),
 		TopicName:         envOrDefault("DAPR_TOPIC_NAME", "orders"),
 		SubscriptionRoute: NormalizeRoute(envOrDefault("DAPR_SUBSCRIPTION_ROUTE", "/orders")),
+		SubscriptionsFile: envOrDefault("SUBSCRIPTIONS_FILE", ""),
+		Orders: OrderStoreConfig{
+			Enabled: envBoolOrDefault("ORDER_STORE_ENABLED", true),
+			Path:    envOrDefault("ORDER_STORE_PATH", "/tmp/consumer-gin-orders.db"),
+		},
+		Inbox: InboxConfig{
+			Store: envOrDefault("INBOX_STORE", "memory"),
+			Path:  envOrDefault("INBOX_PATH", "/tmp/consumer-gin-inbox.db"),
+			TTL:   envDurationOrDefault("INBOX_TTL", 24*time.Hour),
+		},
+		Quarantine: QuarantineConfig{
+			Enabled:         envBoolOrDefault("QUARANTINE_ENABLED", true),
+			Path:            envOrDefault("QUARANTINE_PATH", "/tmp/consumer-gin-quarantine.db"),
+			DeadLetterTopic: envOrDefault("DAPR_DEAD_LETTER_TOPIC", "orders-dead-letter"),
+			DeadLetterRoute: NormalizeRoute(envOrDefault("DAPR_DEAD_LETTER_ROUTE", "/orders/dead-letter")),
+			AdminToken:      os.Getenv("ADMIN_API_TOKEN"),
+		},
+		Bulk: BulkSubscribeConfig{
+			Enabled:            envBoolOrDefault("DAPR_BULK_SUBSCRIBE_ENABLED", false),
+			MaxMessagesCount:   envIntOrDefault("DAPR_BULK_MAX_MESSAGES_COUNT", 100),
+			MaxAwaitDurationMs: envIntOrDefault("DAPR_BULK_MAX_AWAIT_DURATION_MS", 1000),
+			Concurrency:        envIntOrDefault("BULK_CONCURRENCY", defaultBulkConcurrency),
+		},
+		CloudEvents: CloudEventsConfig{
+			UnknownTypes: envUnknownTypePolicy("CLOUDEVENT_UNKNOWN_TYPE_POLICY"),
+			Fallback:     envFallbackPolicy("EVENT_FALLBACK_POLICY"),
+		},
+		Workers: WorkerPoolConfig{
+			Concurrency: envIntOrDefault("WORKER_CONCURRENCY", defaultWorkerConcurrency),
+			QueueSize:   envIntOrDefault("WORKER_QUEUE_SIZE", defaultWorkerQueueSize),
+		},
+		Health: HealthConfig{
+			Timeout:    envDurationOrDefault("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			DaprChecks: envBoolOrDefault("HEALTH_DAPR_CHECKS_ENABLED", true),
+		},
+		Shutdown: lifecycle.Config{
+			DrainPeriod: envDurationOrDefault("SHUTDOWN_DRAIN_PERIOD", 5*time.Second),
+			Timeout:     envDurationOrDefault("SHUTDOWN_TIMEOUT", 20*time.Second),
+		},
+		AccessLog:    accesslog.LoadConfigFromEnv(),
+		DaprHTTPPort: envOrDefault("DAPR_HTTP_PORT", "3500"),
 	}
 }
 
+// DaprBaseURL is the address of the Dapr sidecar HTTP API.
+func (c Config) DaprBaseURL() string {
+	return "http://localhost:" + c.DaprHTTPPort
+}
+
 func NormalizeRoute(route string) string {
 	trimmed := strings.TrimSpace(route)
 	if trimmed == "" {
@@ -39,3 +120,62 @@ func envOrDefault(key, fallback string) string {
 	}
 	return value
 }
+
+func envIntOrDefault(key string, fallback int) int {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("ignoring invalid integer environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envBoolOrDefault(key string, fallback bool) bool {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("ignoring invalid boolean environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("ignoring invalid duration environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envUnknownTypePolicy(key string) UnknownTypePolicy {
+	value := envOrDefault(key, "")
+	policy, err := ParseUnknownTypePolicy(value)
+	if err != nil {
+		logger.Warn("ignoring invalid unknown event type policy", "key", key, "value", value)
+		return UnknownTypeReject
+	}
+	return policy
+}
+
+func envFallbackPolicy(key string) FallbackPolicy {
+	value := envOrDefault(key, "")
+	policy, err := ParseFallbackPolicy(value)
+	if err != nil {
+		logger.Warn("ignoring invalid event fallback policy", "key", key, "value", value)
+		return FallbackQuarantine
+	}
+	return policy
+}
@@ -0,0 +1,389 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consumeMetrics are shared by every subscription route.
+type consumeMetrics struct {
+	requests     prometheus.Counter
+	errors       prometheus.Counter
+	consumed     prometheus.Counter
+	duplicates   prometheus.Counter
+	outcomes     *prometheus.CounterVec
+	quarantined  *prometheus.CounterVec
+	bulkSize     prometheus.Histogram
+	bulkDuration prometheus.Histogram
+	ignored      prometheus.Counter
+	latency      *prometheus.HistogramVec
+	lastEvent    *prometheus.GaugeVec
+}
+
+func newConsumeMetrics(registerer prometheus.Registerer) consumeMetrics {
+	metrics := consumeMetrics{
+		requests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_consume_requests_total",
+			Help: "Total consume requests received by consumer-gin.",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_consume_errors_total",
+			Help: "Total consume errors in consumer-gin.",
+		}),
+		consumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_consumed_total",
+			Help: "Total consumed order events in consumer-gin.",
+		}),
+		duplicates: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_consume_duplicates_total",
+			Help: "Total redelivered order events acknowledged without processing by consumer-gin.",
+		}),
+		outcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_consume_outcomes_total",
+			Help: "Total consumed messages in consumer-gin by Dapr subscription status.",
+		}, []string{"status"}),
+		quarantined: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_quarantined_total",
+			Help: "Total messages moved to the consumer-gin quarantine by reason.",
+		}, []string{"reason"}),
+		bulkSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "orders_consume_bulk_batch_size",
+			Help:    "Number of entries per bulk subscribe request received by consumer-gin.",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+		}),
+		bulkDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "orders_consume_bulk_duration_seconds",
+			Help:    "Time consumer-gin takes to process all entries of a bulk subscribe request.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		ignored: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_consume_ignored_total",
+			Help: "Total CloudEvents of unknown type acknowledged without processing by consumer-gin.",
+		}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "orders_end_to_end_latency_seconds",
+			Help:    "Time from an order event being published to consumer-gin consuming it, by producer stack.",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+		}, []string{"source_stack"}),
+		lastEvent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "orders_consume_last_event_timestamp_seconds",
+			Help: "Unix time consumer-gin last consumed an order event, by producer stack.",
+		}, []string{"source_stack"}),
+	}
+	registerer.MustRegister(metrics.requests, metrics.errors, metrics.consumed, metrics.duplicates, metrics.outcomes,
+		metrics.quarantined, metrics.bulkSize, metrics.bulkDuration, metrics.ignored, metrics.latency, metrics.lastEvent)
+	return metrics
+}
+
+// knownSourceStacks bounds the source_stack label to the producer stacks.
+var knownSourceStacks = map[string]bool{"gin": true, "ktor": true, "springboot": true}
+
+// sourceStack maps a CloudEvent source such as producer-ktor to its stack,
+// or "unknown".
+func sourceStack(source string) string {
+	stack := source[strings.LastIndex(source, "-")+1:]
+	if knownSourceStacks[stack] {
+		return stack
+	}
+	return "unknown"
+}
+
+// observeConsumed records the latency from the publishtime extension, or
+// else the CloudEvent time.
+func (m consumeMetrics) observeConsumed(metadata CloudEventMetadata, now time.Time) {
+	stack := sourceStack(metadata.Source)
+	m.lastEvent.WithLabelValues(stack).Set(float64(now.UnixNano()) / float64(time.Second))
+
+	publishedAt := metadata.Time
+	if value := metadata.Extensions["publishtime"]; value != "" {
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			publishedAt = parsed
+		}
+	}
+	if publishedAt.IsZero() {
+		return
+	}
+	// Clock skew between hosts can put the publish time in the future.
+	m.latency.WithLabelValues(stack).Observe(max(now.Sub(publishedAt).Seconds(), 0))
+}
+
+// observe counts the status answered for one message.
+func (m consumeMetrics) observe(status SubscriptionStatus) {
+	m.outcomes.WithLabelValues(string(status)).Inc()
+	if status != SubscriptionSuccess {
+		m.errors.Inc()
+	}
+}
+
+// consumeRoute runs the route's handler on a pool worker ordered by
+// partition key. Messages answered with DROP are quarantined unless
+// discarded with ErrDiscardEvent.
+type consumeRoute struct {
+	route           subscriptionRoute
+	decoder         CloudEventDecoder
+	handler         OrderEventHandler
+	resolved        options
+	tracer          trace.Tracer
+	metrics         consumeMetrics
+	pool            *WorkerPool
+	bulkConcurrency int
+}
+
+// message is one delivered message, decoded once whether it arrived alone or
+// as a bulk entry.
+type message struct {
+	req       *http.Request
+	payload   []byte
+	event     OrderEvent
+	metadata  CloudEventMetadata
+	decodeErr error
+}
+
+// key returns the partition key of the message, or "" when it has no event.
+func (m message) key() string {
+	if m.event == nil {
+		return ""
+	}
+	return partitionKey(m.metadata, m.event)
+}
+
+// messageConsumer is a subscription route. Single and bulk deliveries both
+// go through consumeMessage.
+type messageConsumer interface {
+	decode(req *http.Request, payload []byte) message
+	consume(ctx context.Context, msg message) (SubscriptionStatus, error)
+}
+
+// serveDelivery answers a single or bulk delivery on a subscription route.
+func serveDelivery(c *gin.Context, consumer messageConsumer, metrics consumeMetrics, bulkConcurrency int) {
+	requestLogger := loggerFromGinContext(c)
+	metrics.requests.Inc()
+
+	payload, err := c.GetRawData()
+	if err != nil {
+		requestLogger.WarnContext(c.Request.Context(), "failed to read event payload", "error", err)
+		metrics.observe(SubscriptionRetry)
+		respondSubscription(c, SubscriptionRetry)
+		return
+	}
+	if request, ok := parseBulkSubscribeRequest(payload); ok {
+		serveBulk(c, request, bulkConcurrency, metrics, consumer)
+		return
+	}
+	status, err := consumeMessage(c.Request.Context(), consumer, consumer.decode(c.Request, payload))
+	metrics.observe(status)
+	if errors.Is(err, ErrPoolFull) || errors.Is(err, ErrPoolDraining) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"status": status})
+		return
+	}
+	respondSubscription(c, status)
+}
+
+// consumeMessage consumes msg and answers RETRY when consuming it panics.
+func consumeMessage(ctx context.Context, consumer messageConsumer, msg message) (status SubscriptionStatus, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			loggerFromContext(ctx).ErrorContext(ctx, "message processing panicked", "messageId", msg.metadata.ID, "panic", recovered)
+			status, err = SubscriptionRetry, nil
+		}
+	}()
+	return consumer.consume(ctx, msg)
+}
+
+func (r consumeRoute) handle(c *gin.Context) {
+	loggerFromGinContext(c).DebugContext(c.Request.Context(), "received consume request", "route", r.route.path, "topic", r.route.topic)
+	serveDelivery(c, r, r.metrics, r.bulkConcurrency)
+}
+
+func (r consumeRoute) decode(req *http.Request, payload []byte) message {
+	event, metadata, err := r.decoder.Decode(req.Header, payload)
+	return message{req: req, payload: payload, event: event, metadata: metadata, decodeErr: err}
+}
+
+// consume hands msg to the worker pool. The error is set when the pool
+// turned the message away.
+func (r consumeRoute) consume(ctx context.Context, msg message) (SubscriptionStatus, error) {
+	requestLogger := loggerFromContext(ctx)
+	req, payload, event, metadata, decodeErr := msg.req, msg.payload, msg.event, msg.metadata, msg.decodeErr
+
+	spanOpts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "dapr"),
+			attribute.String("messaging.destination.name", r.route.topic),
+		),
+	}
+	parentCtx := ctx
+	if eventCtx, ok := eventTraceContext(parentCtx, r.resolved.propagator, metadata); ok {
+		spanOpts = append(spanOpts, trace.WithLinks(trace.LinkFromContext(parentCtx)))
+		parentCtx = eventCtx
+	}
+	ctx, span := r.tracer.Start(parentCtx, "orders.consume", spanOpts...)
+	defer span.End()
+	if metadata.ID != "" {
+		span.SetAttributes(
+			attribute.String("messaging.message.id", metadata.ID),
+			attribute.String("cloudevents.event_source", metadata.Source),
+			attribute.String("cloudevents.event_type", metadata.Type),
+			attribute.String("cloudevents.event_spec_version", metadata.SpecVersion),
+		)
+	}
+
+	if errors.Is(decodeErr, ErrUnknownEventType) && r.decoder.UnknownTypes == UnknownTypeIgnore {
+		r.metrics.ignored.Inc()
+		span.SetStatus(codes.Ok, "")
+		requestLogger.InfoContext(ctx, "ignored event of unknown type", "route", r.route.path, "type", metadata.Type, "messageId", metadata.ID)
+		return SubscriptionSuccess, nil
+	}
+	if decodeErr != nil {
+		// Only versions the registry does not know follow its fallback
+		// policy; anything else undecodable is dropped.
+		status := SubscriptionDrop
+		if errors.Is(decodeErr, errUnsupportedEventVersion) {
+			status = handlerOutcome(decodeErr)
+		}
+		span.RecordError(decodeErr)
+		span.SetStatus(codes.Error, "invalid event payload")
+		requestLogger.WarnContext(ctx, "rejected undecodable event payload", "route", r.route.path, "mode", metadata.Mode, "type", metadata.Type, "status", status, "payloadSize", len(payload), "error", decodeErr)
+		if status == SubscriptionDrop && !errors.Is(decodeErr, ErrDiscardEvent) {
+			r.quarantine(ctx, req.Header, payload, QuarantineUndecodable, decodeErr)
+		}
+		return status, nil
+	}
+	key := msg.key()
+	span.SetAttributes(
+		attribute.String("order.id", event.OrderID()),
+		attribute.String("order.event_version", event.Version()),
+		attribute.String("messaging.partition_key", key),
+	)
+	ctx = withCloudEventMetadata(ctx, metadata)
+
+	status, err := r.pool.Submit(ctx, key, func(ctx context.Context) SubscriptionStatus {
+		return r.handleEvent(ctx, msg)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "event not scheduled")
+		requestLogger.WarnContext(ctx, "deferred order event to a redelivery", "route", r.route.path, "id", event.OrderID(), "partitionKey", key, "error", err)
+		return status, err
+	}
+	if status == SubscriptionSuccess {
+		span.SetStatus(codes.Ok, "")
+	} else {
+		span.SetStatus(codes.Error, "event handler failed")
+	}
+	return status, nil
+}
+
+// handleEvent runs on a pool worker: it skips redeliveries recorded in the
+// inbox, calls the handler and records the event once handled.
+func (r consumeRoute) handleEvent(ctx context.Context, msg message) SubscriptionStatus {
+	requestLogger := loggerFromContext(ctx)
+	event, metadata := msg.event, msg.metadata
+	span := trace.SpanFromContext(ctx)
+	messageID, messageSource := metadata.ID, metadata.Source
+
+	inbox := r.resolved.inbox
+	messageKey := ""
+	if inbox != nil {
+		messageKey = inboxKey(messageID, messageSource)
+	}
+	if messageKey != "" {
+		seen, err := inbox.Seen(ctx, messageKey)
+		if err != nil {
+			requestLogger.WarnContext(ctx, "failed to check inbox, processing event", "id", event.OrderID(), "messageKey", messageKey, "error", err)
+		}
+		if seen {
+			r.metrics.duplicates.Inc()
+			span.SetAttributes(attribute.Bool("messaging.duplicate", true))
+			requestLogger.InfoContext(ctx, "acknowledged duplicate order event", "route", r.route.path, "id", event.OrderID(), "messageKey", messageKey)
+			return SubscriptionSuccess
+		}
+	}
+
+	var err error
+	if r.handler != nil {
+		err = r.handler.Handle(ctx, event)
+	}
+	if status := handlerOutcome(err); status != SubscriptionSuccess {
+		span.RecordError(err)
+		requestLogger.WarnContext(ctx, "event handler failed", "route", r.route.path, "id", event.OrderID(), "status", status, "error", err)
+		if status == SubscriptionDrop && !errors.Is(err, ErrDiscardEvent) {
+			r.quarantine(ctx, msg.req.Header, msg.payload, QuarantineRejected, err)
+		}
+		return status
+	}
+
+	if messageKey != "" {
+		if err := inbox.Record(ctx, messageKey, r.resolved.inboxTTL); err != nil {
+			requestLogger.WarnContext(ctx, "failed to record event in inbox", "id", event.OrderID(), "messageKey", messageKey, "error", err)
+		}
+	}
+
+	r.metrics.consumed.Inc()
+	r.metrics.observeConsumed(metadata, time.Now())
+	requestLogger.InfoContext(ctx, "consumed order event", "route", r.route.path, "topic", r.route.topic, "id", event.OrderID(), "version", event.Version(), "type", metadata.Type)
+	return SubscriptionSuccess
+}
+
+// quarantine keeps a dropped message. Failures are logged: the message is
+// dropped either way.
+func (r consumeRoute) quarantine(ctx context.Context, header http.Header, payload []byte, reason string, cause error) {
+	if r.resolved.quarantine == nil {
+		return
+	}
+	requestLogger := loggerFromContext(ctx)
+	message := newQuarantinedMessage(header, payload, reason, cause, r.route.pubsubName, r.route.topic, r.route.path)
+	if err := r.resolved.quarantine.Quarantine(ctx, message); err != nil {
+		requestLogger.ErrorContext(ctx, "failed to quarantine message", "quarantineId", message.ID, "reason", reason, "error", err)
+		return
+	}
+	r.metrics.quarantined.WithLabelValues(reason).Inc()
+	requestLogger.InfoContext(ctx, "quarantined message", "quarantineId", message.ID, "reason", reason, "topic", r.route.topic)
+}
+
+// deadLetterRoute quarantines dead-lettered messages against their source
+// topic, so a re-drive publishes them there.
+type deadLetterRoute struct {
+	route           subscriptionRoute
+	store           QuarantineStore
+	metrics         consumeMetrics
+	bulkConcurrency int
+}
+
+func (r deadLetterRoute) handle(c *gin.Context) {
+	serveDelivery(c, r, r.metrics, r.bulkConcurrency)
+}
+
+// decode leaves dead-lettered messages undecoded; they are stored as
+// delivered.
+func (r deadLetterRoute) decode(req *http.Request, payload []byte) message {
+	return message{req: req, payload: payload}
+}
+
+func (r deadLetterRoute) consume(ctx context.Context, msg message) (SubscriptionStatus, error) {
+	requestLogger := loggerFromContext(ctx)
+	req, payload := msg.req, msg.payload
+	if r.store == nil {
+		requestLogger.WarnContext(ctx, "dropped dead-lettered message; quarantine is disabled", "topic", r.route.sourceTopic, "deadLetterTopic", r.route.topic, "payloadSize", len(payload))
+		return SubscriptionDrop, nil
+	}
+	quarantined := newQuarantinedMessage(req.Header, payload, QuarantineDeadLettered, nil, r.route.pubsubName, r.route.sourceTopic, r.route.path)
+	quarantined.Error = "delivery to " + r.route.sourceTopic + " exhausted its retries"
+	if err := r.store.Quarantine(ctx, quarantined); err != nil {
+		requestLogger.ErrorContext(ctx, "failed to quarantine dead-lettered message", "quarantineId", quarantined.ID, "error", err)
+		return SubscriptionRetry, nil
+	}
+	r.metrics.quarantined.WithLabelValues(QuarantineDeadLettered).Inc()
+	requestLogger.WarnContext(ctx, "quarantined dead-lettered message", "quarantineId", quarantined.ID, "topic", r.route.sourceTopic, "deadLetterTopic", r.route.topic, "payloadSize", len(payload))
+	return SubscriptionSuccess, nil
+}
@@ -0,0 +1,16 @@
+package consumer
+
+import "context"
+
+// OrderEventHandler processes order events after ParseOrderEvent. A returned
+// error makes the sidecar redeliver the event unless it wraps ErrDropEvent.
+type OrderEventHandler interface {
+	Handle(ctx context.Context, event OrderEvent) error
+}
+
+// OrderEventHandlerFunc adapts a function to OrderEventHandler.
+type OrderEventHandlerFunc func(ctx context.Context, event OrderEvent) error
+
+func (f OrderEventHandlerFunc) Handle(ctx context.Context, event OrderEvent) error {
+	return f(ctx, event)
+}
@@ -0,0 +1,37 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+)
+
+// HealthConfig controls the dependency checks behind /health and the
+// readiness and startup probes.
+type HealthConfig struct {
+	Timeout    time.Duration
+	DaprChecks bool
+}
+
+// NewHealth builds the consumer health checks: that the Dapr sidecar is up,
+// that it loaded every pubsub component subscriptions use and that it
+// registered every subscription.
+func NewHealth(cfg Config, subscriptions []DaprSubscription, client health.HTTPDoer) *health.Health {
+	h := health.New(health.WithTimeout(cfg.Health.Timeout))
+	if !cfg.Health.DaprChecks {
+		return h
+	}
+	var components []string
+	seen := map[string]bool{}
+	expected := make([]health.Subscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		if !seen[subscription.PubSubName] {
+			seen[subscription.PubSubName] = true
+			components = append(components, subscription.PubSubName)
+		}
+		expected = append(expected, health.Subscription{PubSubName: subscription.PubSubName, Topic: subscription.Topic})
+	}
+	h.Register("daprSidecar", health.DaprSidecar(client, cfg.DaprBaseURL()))
+	h.Register("daprMetadata", health.DaprMetadata(client, cfg.DaprBaseURL(), components, expected))
+	return h
+}
@@ -0,0 +1,76 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/daprfake"
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func getHealth(t *testing.T, router http.Handler, path string) (int, health.Report) {
+	t.Helper()
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+	var report health.Report
+	if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode GET %s %q: %v", path, res.Body.String(), err)
+	}
+	return res.Code, report
+}
+
+func TestHealthEndpointsAreUpWithoutChecks(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}, registry, registry)
+	for _, path := range []string{"/health", "/health/live", "/health/ready", "/health/started"} {
+		if code, report := getHealth(t, router, path); code != http.StatusOK || report.Status != health.StatusUp {
+			t.Fatalf("GET %s = %d %+v, want 200 UP", path, code, report)
+		}
+	}
+}
+
+func TestHealthWaitsForDaprSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		PubSubName:        "order-pubsub",
+		TopicName:         "orders",
+		SubscriptionRoute: "/orders",
+		DaprHTTPPort:      "3500",
+		Health:            HealthConfig{DaprChecks: true},
+	}
+	sidecar := daprfake.New(daprfake.WithComponents(daprfake.Component{Name: "order-pubsub", Type: "pubsub.redis", Version: "v1"}))
+	registry := prometheus.NewRegistry()
+	subscriptions, err := DefaultSubscriptions(cfg)
+	if err != nil {
+		t.Fatalf("default subscriptions: %v", err)
+	}
+	router := NewRouter(cfg, registry, registry, WithHealth(NewHealth(cfg, subscriptions, sidecar)))
+
+	code, report := getHealth(t, router, "/health")
+	if code != http.StatusServiceUnavailable || report.Components["daprSidecar"].Status != health.StatusUp {
+		t.Fatalf("GET /health = %d %+v, want 503 with the sidecar UP", code, report)
+	}
+	if got := report.Components["daprMetadata"].Details["error"]; got != "dapr subscriptions not registered: order-pubsub/orders" {
+		t.Fatalf("daprMetadata error = %v, want the missing subscription", got)
+	}
+	if code, _ := getHealth(t, router, "/health/started"); code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /health/started = %d, want 503 before the sidecar subscribes", code)
+	}
+
+	if err := sidecar.Subscribe(router); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for _, path := range []string{"/health", "/health/ready", "/health/started"} {
+		if code, report := getHealth(t, router, path); code != http.StatusOK || report.Status != health.StatusUp {
+			t.Fatalf("GET %s = %d %+v, want 200 UP once subscribed", path, code, report)
+		}
+	}
+}
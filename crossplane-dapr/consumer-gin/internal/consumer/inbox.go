@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InboxConfig selects the processed-message inbox that suppresses Dapr
+// redeliveries. Store is "memory", "sqlite" or "none"; TTL bounds how long a
+// processed CloudEvent is remembered.
+type InboxConfig struct {
+	Store string
+	Path  string
+	TTL   time.Duration
+}
+
+// NewInbox builds the inbox selected by cfg.Store and a function that
+// releases it. It returns a nil Inbox when deduplication is disabled.
+func NewInbox(cfg InboxConfig) (Inbox, func() error, error) {
+	noop := func() error { return nil }
+	switch strings.ToLower(cfg.Store) {
+	case "", "memory":
+		return NewMemoryInbox(), noop, nil
+	case "sqlite":
+		inbox, err := OpenSQLiteInbox(cfg.Path)
+		if err != nil {
+			return nil, noop, err
+		}
+		return inbox, inbox.Close, nil
+	case "none":
+		return nil, noop, nil
+	default:
+		return nil, noop, fmt.Errorf("unsupported inbox store %q", cfg.Store)
+	}
+}
+
+// Inbox records processed CloudEvents by message key. It is checked before
+// the OrderEventHandler runs and written after it succeeds, so concurrent
+// redeliveries of an in-flight event may both reach the handler; handlers
+// must stay idempotent.
+type Inbox interface {
+	// Seen reports whether key was recorded and has not expired.
+	Seen(ctx context.Context, key string) (bool, error)
+	// Record remembers key for ttl.
+	Record(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// cloudEventIdentity returns the id and source of a CloudEvent envelope.
+// Raw payloads have neither; their top-level id is the order id.
+func cloudEventIdentity(payload []byte) (string, string) {
+	var envelope CloudEventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil || envelope.SpecVersion == "" {
+		return "", ""
+	}
+	return envelope.ID, envelope.Source
+}
+
+// inboxKey identifies a CloudEvent by source and id, which the CloudEvents
+// spec requires to be unique together. Events without an id have no key.
+func inboxKey(id, source string) string {
+	if id == "" {
+		return ""
+	}
+	return source + "|" + id
+}
+
+// MemoryInbox is an Inbox for single-replica deployments. Expired keys are
+// swept lazily on Record.
+type MemoryInbox struct {
+	mu        sync.Mutex
+	expiries  map[string]time.Time
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryInbox() *MemoryInbox {
+	return &MemoryInbox{expiries: map[string]time.Time{}, now: time.Now}
+}
+
+func (i *MemoryInbox) Seen(_ context.Context, key string) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	expiresAt, ok := i.expiries[key]
+	return ok && i.now().Before(expiresAt), nil
+}
+
+func (i *MemoryInbox) Record(_ context.Context, key string, ttl time.Duration) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	if now.Sub(i.lastSweep) >= ttl {
+		for existing, expiresAt := range i.expiries {
+			if !now.Before(expiresAt) {
+				delete(i.expiries, existing)
+			}
+		}
+		i.lastSweep = now
+	}
+	i.expiries[key] = now.Add(ttl)
+	return nil
+}
+
+const inboxSchema = `
+CREATE TABLE IF NOT EXISTS inbox (
+	message_key TEXT PRIMARY KEY,
+	expires_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS inbox_expires_at ON inbox (expires_at);
+`
+
+// SQLiteInbox is an Inbox backed by an embedded SQLite file, so processed
+// events survive restarts. Expired keys are deleted on Record.
+type SQLiteInbox struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func OpenSQLiteInbox(path string) (*SQLiteInbox, error) {
+	db, err := openSQLite(path, inboxSchema)
+	if err != nil {
+		return nil, fmt.Errorf("open inbox: %w", err)
+	}
+	return &SQLiteInbox{db: db, now: time.Now}, nil
+}
+
+func (i *SQLiteInbox) Close() error {
+	return i.db.Close()
+}
+
+func (i *SQLiteInbox) Seen(ctx context.Context, key string) (bool, error) {
+	var found int
+	err := i.db.QueryRowContext(ctx, `SELECT 1 FROM inbox WHERE message_key = ? AND expires_at > ?`, key, i.now().UnixNano()).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check inbox: %w", err)
+	}
+	return true, nil
+}
+
+func (i *SQLiteInbox) Record(ctx context.Context, key string, ttl time.Duration) error {
+	now := i.now()
+	if _, err := i.db.ExecContext(ctx, `DELETE FROM inbox WHERE expires_at <= ?`, now.UnixNano()); err != nil {
+		return fmt.Errorf("expire inbox: %w", err)
+	}
+	_, err := i.db.ExecContext(ctx, `
+INSERT INTO inbox (message_key, expires_at) VALUES (?, ?)
+ON CONFLICT (message_key) DO UPDATE SET expires_at = excluded.expires_at`,
+		key, now.Add(ttl).UnixNano())
+	if err != nil {
+		return fmt.Errorf("record inbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestInboxBackends(t *testing.T) {
+	t.Parallel()
+
+	backends := map[string]func(t *testing.T, now func() time.Time) Inbox{
+		"memory": func(_ *testing.T, now func() time.Time) Inbox {
+			inbox := NewMemoryInbox()
+			inbox.now = now
+			return inbox
+		},
+		"sqlite": func(t *testing.T, now func() time.Time) Inbox {
+			inbox, err := OpenSQLiteInbox(filepath.Join(t.TempDir(), "inbox.db"))
+			if err != nil {
+				t.Fatalf("open sqlite inbox: %v", err)
+			}
+			t.Cleanup(func() { _ = inbox.Close() })
+			inbox.now = now
+			return inbox
+		},
+	}
+
+	for name, newInbox := range backends {
+		newInbox := newInbox
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			inbox := newInbox(t, func() time.Time { return now })
+
+			if seen, err := inbox.Seen(ctx, "producer-gin|evt-1"); err != nil || seen {
+				t.Fatalf("expected unseen key, got %v %v", seen, err)
+			}
+			if err := inbox.Record(ctx, "producer-gin|evt-1", time.Hour); err != nil {
+				t.Fatalf("record: %v", err)
+			}
+			if seen, err := inbox.Seen(ctx, "producer-gin|evt-1"); err != nil || !seen {
+				t.Fatalf("expected recorded key, got %v %v", seen, err)
+			}
+			if seen, _ := inbox.Seen(ctx, "producer-ktor|evt-1"); seen {
+				t.Fatal("expected keys to be scoped by source")
+			}
+
+			now = now.Add(time.Hour)
+			if seen, err := inbox.Seen(ctx, "producer-gin|evt-1"); err != nil || seen {
+				t.Fatalf("expected expired key, got %v %v", seen, err)
+			}
+			if err := inbox.Record(ctx, "producer-gin|evt-2", time.Hour); err != nil {
+				t.Fatalf("record after expiry: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewInbox(t *testing.T) {
+	t.Parallel()
+
+	if inbox, _, err := NewInbox(InboxConfig{Store: "none"}); inbox != nil || err != nil {
+		t.Fatalf("expected no inbox, got %v %v", inbox, err)
+	}
+	if _, _, err := NewInbox(InboxConfig{Store: "redis"}); err == nil {
+		t.Fatal("expected error for unsupported store")
+	}
+	inbox, closeInbox, err := NewInbox(InboxConfig{Store: "sqlite", Path: filepath.Join(t.TempDir(), "inbox.db")})
+	if err != nil {
+		t.Fatalf("open sqlite inbox: %v", err)
+	}
+	if _, ok := inbox.(*SQLiteInbox); !ok {
+		t.Fatalf("expected sqlite inbox, got %T", inbox)
+	}
+	if err := closeInbox(); err != nil {
+		t.Fatalf("close inbox: %v", err)
+	}
+}
+
+func TestConsumeSkipsRedeliveredCloudEvents(t *testing.T) {
+	t.Parallel()
+
+	handled := map[string]int{}
+	failNext := false
+	handler := OrderEventHandlerFunc(func(_ context.Context, event OrderEvent) error {
+		handled[event.OrderID()]++
+		if failNext {
+			failNext = false
+			return errors.New("database is locked")
+		}
+		return nil
+	})
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderEventHandler(handler), WithInbox(NewMemoryInbox(), time.Hour))
+
+	deliver := func(body string) SubscriptionStatus {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		for _, status := range []SubscriptionStatus{SubscriptionSuccess, SubscriptionRetry, SubscriptionDrop} {
+			if bytes.Contains(res.Body.Bytes(), []byte(`"`+status+`"`)) {
+				return status
+			}
+		}
+		t.Fatalf("unexpected subscription response %s", res.Body.String())
+		return ""
+	}
+
+	first := `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":{"id":"ORD-1","amount":10}}`
+	if deliver(first) != SubscriptionSuccess || deliver(first) != SubscriptionSuccess {
+		t.Fatal("expected both deliveries to be acknowledged")
+	}
+	if handled["ORD-1"] != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", handled["ORD-1"])
+	}
+
+	deliver(`{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-ktor","data":{"id":"ORD-2","amount":10}}`)
+	if handled["ORD-2"] != 1 {
+		t.Fatal("expected the same id from another source to be processed")
+	}
+
+	failNext = true
+	retried := `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-3","source":"producer-gin","data":{"id":"ORD-3","amount":10}}`
+	if deliver(retried) != SubscriptionRetry || deliver(retried) != SubscriptionSuccess || handled["ORD-3"] != 2 {
+		t.Fatalf("expected a failed event to be processed again on redelivery, ran %d times", handled["ORD-3"])
+	}
+
+	raw := `{"id":"ORD-4","amount":10}`
+	deliver(raw)
+	deliver(raw)
+	if handled["ORD-4"] != 2 {
+		t.Fatalf("expected raw payloads without a CloudEvent id to bypass the inbox, ran %d times", handled["ORD-4"])
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "orders_consume_duplicates_total" {
+			if got := family.GetMetric()[0].GetCounter().GetValue(); got != 1 {
+				t.Fatalf("duplicates = %v, want 1", got)
+			}
+			return
+		}
+	}
+	t.Fatal("orders_consume_duplicates_total not registered")
+}
@@ -4,10 +4,16 @@ package consumer
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/daprfake"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -34,3 +40,90 @@ func TestIntegrationConsumeEndpoint(t *testing.T) {
 		t.Fatalf("expected consume metric in payload")
 	}
 }
+
+func TestIntegrationConsumeThroughDaprSidecar(t *testing.T) {
+	t.Parallel()
+
+	orders, err := OpenSQLiteOrderStore(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("open order store: %v", err)
+	}
+	defer orders.Close()
+	quarantine, err := OpenSQLiteQuarantineStore(filepath.Join(t.TempDir(), "quarantine.db"))
+	if err != nil {
+		t.Fatalf("open quarantine store: %v", err)
+	}
+	defer quarantine.Close()
+
+	// The first delivery of ORD-2 fails, so Dapr has to redeliver it.
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	handler := OrderEventHandlerFunc(func(ctx context.Context, event OrderEvent) error {
+		mu.Lock()
+		attempts[event.OrderID()]++
+		attempt := attempts[event.OrderID()]
+		mu.Unlock()
+		if event.OrderID() == "ORD-2" && attempt == 1 {
+			return errors.New("order store unavailable")
+		}
+		return orders.Handle(ctx, event)
+	})
+
+	cfg := Config{
+		PubSubName:        "order-pubsub",
+		TopicName:         "orders",
+		SubscriptionRoute: "/orders",
+		Quarantine:        QuarantineConfig{Enabled: true, DeadLetterTopic: "orders-dead-letter", DeadLetterRoute: "/orders/dead-letter"},
+	}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderStore(orders), WithOrderEventHandler(handler), WithQuarantine(quarantine, nil))
+
+	sidecar := daprfake.New(daprfake.WithAppID("producer-ktor"), daprfake.WithRetryInterval(time.Millisecond))
+	if err := sidecar.Subscribe(router); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for _, publish := range []struct{ contentType, body string }{
+		{"application/json", `{"id":"ORD-1","amount":10,"eventVersion":"v1"}`},
+		{"application/cloudevents+json", `{"specversion":"1.0","id":"evt-2","source":"producer-gin","type":"com.agnostic.order.created.v2","data":{"id":"ORD-2","amount":20,"currency":"EUR"}}`},
+		{"application/json", `{"id":"ORD-3","eventVersion":"v9"}`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:3500/v1.0/publish/order-pubsub/orders", bytes.NewBufferString(publish.body))
+		req.Header.Set("Content-Type", publish.contentType)
+		res, err := sidecar.Do(req)
+		if err != nil || res.StatusCode != http.StatusNoContent {
+			t.Fatalf("publish %s: %v %v", publish.body, res.StatusCode, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sidecar.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"ORD-1", "ORD-2"} {
+		if _, err := orders.Get(context.Background(), id); err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+	}
+	if attempts["ORD-2"] != 2 {
+		t.Fatalf("ORD-2 handled %d times, want a failure and a redelivery", attempts["ORD-2"])
+	}
+	page, err := quarantine.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("list quarantine: %v", err)
+	}
+	// The dropped ORD-3 is quarantined once, although Dapr dead-letters it too.
+	if page.Total != 1 || page.Messages[0].Topic != "orders" || page.Messages[0].Reason != QuarantineUndecodable {
+		t.Fatalf("quarantine = %+v, want ORD-3 quarantined once from orders", page)
+	}
+
+	var deadLettered bool
+	for _, delivery := range sidecar.Deliveries() {
+		if delivery.Topic == "orders-dead-letter" {
+			deadLettered = delivery.Route == "/orders/dead-letter" && delivery.Status == daprfake.StatusSuccess
+		}
+	}
+	if !deadLettered {
+		t.Fatalf("ORD-3 was not dead-lettered: %+v", sidecar.Deliveries())
+	}
+}
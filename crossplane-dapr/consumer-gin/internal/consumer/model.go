@@ -1,19 +1,89 @@
 package consumer
 
-import "encoding/json"
+import (
+	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+)
+
+// DaprSubscription is one entry of the programmatic subscription list served
+// on /dapr/subscribe. A subscription either names a single Route or, in the
+// v2 format, Routes with CEL rules and a default path.
 type DaprSubscription struct {
-	PubSubName string `json:"pubsubname"`
-	Topic      string `json:"topic"`
-	Route      string `json:"route"`
+	PubSubName      string             `json:"pubsubname" yaml:"pubsubname"`
+	Topic           string             `json:"topic" yaml:"topic"`
+	Route           string             `json:"route,omitempty" yaml:"route,omitempty"`
+	Routes          *DaprRoutes        `json:"routes,omitempty" yaml:"routes,omitempty"`
+	Metadata        map[string]string  `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	DeadLetterTopic string             `json:"deadLetterTopic,omitempty" yaml:"deadLetterTopic,omitempty"`
+	BulkSubscribe   *DaprBulkSubscribe `json:"bulkSubscribe,omitempty" yaml:"bulkSubscribe,omitempty"`
+}
+
+// DaprBulkSubscribe asks Dapr to deliver messages in batches. Zero limits
+// leave the Dapr defaults.
+type DaprBulkSubscribe struct {
+	Enabled            bool `json:"enabled" yaml:"enabled"`
+	MaxMessagesCount   int  `json:"maxMessagesCount,omitempty" yaml:"maxMessagesCount,omitempty"`
+	MaxAwaitDurationMs int  `json:"maxAwaitDurationMs,omitempty" yaml:"maxAwaitDurationMs,omitempty"`
+}
+
+type DaprRoutes struct {
+	Rules   []DaprRoutingRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	Default string            `json:"default,omitempty" yaml:"default,omitempty"`
 }
 
+// DaprRoutingRule sends events for which the CEL expression Match is true,
+// for example event.type == "com.agnostic.order.created.v2", to Path.
+type DaprRoutingRule struct {
+	Match string `json:"match" yaml:"match"`
+	Path  string `json:"path" yaml:"path"`
+}
+
+// CloudEventEnvelope holds the identity attributes of a structured-mode
+// CloudEvent. CloudEventDecoder decodes complete events.
 type CloudEventEnvelope struct {
-	Data json.RawMessage `json:"data"`
+	SpecVersion string `json:"specversion,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Source      string `json:"source,omitempty"`
 }
 
 type OrderCreatedV1 struct {
-	ID           string  `json:"id"`
-	Amount       float64 `json:"amount"`
-	EventVersion string  `json:"eventVersion"`
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	EventVersion string      `json:"eventVersion"`
+}
+
+// OrderEvent is a decoded order event of any supported version.
+type OrderEvent interface {
+	OrderID() string
+	Version() string
 }
+
+// LineItem is one ordered product in an OrderCreatedV2 event.
+type LineItem struct {
+	SKU       string      `json:"sku"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unitPrice"`
+}
+
+// OrderCreatedV2 extends OrderCreatedV1 with the order currency, line items,
+// customer and creation time. Amount is the order total.
+type OrderCreatedV2 struct {
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	Currency     string      `json:"currency"`
+	CustomerID   string      `json:"customerId"`
+	LineItems    []LineItem  `json:"lineItems"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	EventVersion string      `json:"eventVersion"`
+}
+
+func (e OrderCreatedV1) OrderID() string { return e.ID }
+func (e OrderCreatedV1) Version() string { return "v1" }
+
+func (e *OrderCreatedV1) stampVersion(version string) { e.EventVersion = version }
+
+func (e OrderCreatedV2) OrderID() string { return e.ID }
+func (e OrderCreatedV2) Version() string { return "v2" }
+
+func (e *OrderCreatedV2) stampVersion(version string) { e.EventVersion = version }
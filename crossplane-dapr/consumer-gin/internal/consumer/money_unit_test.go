@@ -0,0 +1,78 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	t.Parallel()
+
+	var event OrderCreatedV1
+	if err := json.Unmarshal([]byte(`{"id":"ORD-1","amount":"42.50"}`), &event); err != nil {
+		t.Fatalf("decode string amount: %v", err)
+	}
+	if event.Amount.String() != "42.50" {
+		t.Fatalf("amount = %s, want 42.50", event.Amount)
+	}
+	if err := json.Unmarshal([]byte(`{"id":"ORD-1","amount":true}`), &event); err == nil {
+		t.Fatal("expected error for a non-numeric amount")
+	}
+
+	encoded, err := json.Marshal(OrderCreatedV1{ID: "ORD-1", EventVersion: "v1"})
+	if err != nil {
+		t.Fatalf("encode zero amount: %v", err)
+	}
+	if string(encoded) != `{"id":"ORD-1","amount":0,"eventVersion":"v1"}` {
+		t.Fatalf("unexpected zero amount encoding: %s", encoded)
+	}
+}
+
+type moneyGoldenCase struct {
+	Name      string `json:"name"`
+	Input     string `json:"input"`
+	Canonical string `json:"canonical"`
+}
+
+// loadMoneyGoldenCases reads the fixture the Ktor BigDecimalAsNumberSerializer
+// golden test asserts, so both stacks agree on the OrderCreatedV1 bytes.
+func loadMoneyGoldenCases(t *testing.T) []moneyGoldenCase {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("failed to resolve test file path")
+	}
+	path := filepath.Join(filepath.Dir(file), "..", "..", "..", "common-ktor", "src", "test", "resources", "golden", "order-created-v1-amounts.json")
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden fixture: %v", err)
+	}
+	var cases []moneyGoldenCase
+	if err := json.Unmarshal(fixture, &cases); err != nil {
+		t.Fatalf("decode golden fixture: %v", err)
+	}
+	return cases
+}
+
+func TestOrderCreatedV1MatchesKtorSerializer(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range loadMoneyGoldenCases(t) {
+		var event OrderCreatedV1
+		if err := json.Unmarshal([]byte(tc.Input), &event); err != nil {
+			t.Fatalf("%s: decode: %v", tc.Name, err)
+		}
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tc.Name, err)
+		}
+		if string(encoded) != tc.Canonical {
+			t.Fatalf("%s: encoded %s, want %s", tc.Name, encoded, tc.Canonical)
+		}
+	}
+}
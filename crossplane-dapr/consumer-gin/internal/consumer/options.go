@@ -0,0 +1,174 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option customises optional collaborators of NewRouter. Defaults come from
+// the global OpenTelemetry providers installed by SetupTelemetry.
+type Option func(*options)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	eventHandler   OrderEventHandler
+	orderStore     OrderStore
+	orders         OrderReader
+	inbox          Inbox
+	inboxTTL       time.Duration
+	subscriptions  []DaprSubscription
+	routeHandlers  map[string]OrderEventHandler
+	quarantine     QuarantineStore
+	republisher    Republisher
+	registry       *EventRegistry
+	pool           *WorkerPool
+	health         *health.Health
+}
+
+func newOptions(opts []Option) options {
+	resolved := options{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&resolved)
+		}
+	}
+	return resolved
+}
+
+// WithTracerProvider sets the tracer provider used for server and messaging
+// spans instead of the global one.
+func WithTracerProvider(tracerProvider trace.TracerProvider) Option {
+	return func(o *options) {
+		if tracerProvider != nil {
+			o.tracerProvider = tracerProvider
+		}
+	}
+}
+
+// WithPropagator sets the propagator used to extract and inject trace
+// context instead of the global one.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		if propagator != nil {
+			o.propagator = propagator
+		}
+	}
+}
+
+// handler returns the event registry, else the OrderEventHandler, else the
+// order store, whatever order the options were applied in.
+func (o options) handler() OrderEventHandler {
+	switch {
+	case o.registry != nil:
+		return o.registry
+	case o.eventHandler != nil:
+		return o.eventHandler
+	case o.orderStore != nil:
+		return o.orderStore
+	default:
+		return nil
+	}
+}
+
+// WithOrderEventHandler sets the handler called for every parsed order
+// event. Without one, events are only logged.
+func WithOrderEventHandler(handler OrderEventHandler) Option {
+	return func(o *options) {
+		o.eventHandler = handler
+	}
+}
+
+// WithOrderStore serves the orders in store on GET /orders and
+// GET /orders/:id. It also persists consumed orders, unless an
+// OrderEventHandler or an EventRegistry is set; their handlers then decide
+// what to store.
+func WithOrderStore(store OrderStore) Option {
+	return func(o *options) {
+		if store != nil {
+			o.orderStore = store
+			o.orders = store
+		}
+	}
+}
+
+// WithInbox acknowledges redelivered CloudEvents recorded in inbox without
+// calling the OrderEventHandler. Processed events are remembered for ttl.
+func WithInbox(inbox Inbox, ttl time.Duration) Option {
+	return func(o *options) {
+		o.inbox = inbox
+		o.inboxTTL = ttl
+	}
+}
+
+// WithSubscriptions serves subscriptions on /dapr/subscribe and registers a
+// delivery route for every path they declare, instead of the single
+// subscription from Config. Use ValidateSubscriptions or LoadSubscriptionFile
+// first.
+func WithSubscriptions(subscriptions []DaprSubscription) Option {
+	return func(o *options) {
+		o.subscriptions = subscriptions
+	}
+}
+
+// WithRouteHandler handles events delivered to path with handler instead of
+// the OrderEventHandler shared by all routes. On a dead-letter route it is
+// only used without a quarantine.
+func WithRouteHandler(path string, handler OrderEventHandler) Option {
+	return func(o *options) {
+		if handler == nil {
+			return
+		}
+		if o.routeHandlers == nil {
+			o.routeHandlers = map[string]OrderEventHandler{}
+		}
+		o.routeHandlers[NormalizeRoute(path)] = handler
+	}
+}
+
+// WithQuarantine keeps undecodable, rejected and dead-lettered messages in
+// store and serves the /admin/quarantine endpoints. republisher re-drives
+// messages to their topic; without one, re-drive is unavailable.
+func WithQuarantine(store QuarantineStore, republisher Republisher) Option {
+	return func(o *options) {
+		o.quarantine = store
+		o.republisher = republisher
+	}
+}
+
+// WithEventRegistry decodes deliveries with registry and dispatches them to
+// the handlers registered on it instead of any OrderEventHandler.
+func WithEventRegistry(registry *EventRegistry) Option {
+	return func(o *options) {
+		if registry != nil {
+			o.registry = registry
+		}
+	}
+}
+
+// WithWorkerPool processes events on pool instead of one built from
+// Config.Workers, so the caller can drain it on shutdown.
+func WithWorkerPool(pool *WorkerPool) Option {
+	return func(o *options) {
+		if pool != nil {
+			o.pool = pool
+		}
+	}
+}
+
+// WithHealth serves the checks registered on h on /health and the readiness
+// and startup probes. Without it the probes report UP unconditionally.
+func WithHealth(h *health.Health) Option {
+	return func(o *options) {
+		if h != nil {
+			o.health = h
+		}
+	}
+}
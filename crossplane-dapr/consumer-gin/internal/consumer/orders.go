@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrOrderNotFound is returned by OrderReader.Get for unknown order ids.
+var ErrOrderNotFound = errors.New("order not found")
+
+const (
+	defaultOrderPageSize = 50
+	maxOrderPageSize     = 500
+)
+
+// StoredOrder is a consumed order as served by GET /orders. V2 fields are
+// empty for orders consumed from OrderCreatedV1 events, and ReceivedAt is
+// the time consumer-gin first handled the order.
+type StoredOrder struct {
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	Currency     string      `json:"currency,omitempty"`
+	CustomerID   string      `json:"customerId,omitempty"`
+	LineItems    []LineItem  `json:"lineItems,omitempty"`
+	CreatedAt    *time.Time  `json:"createdAt,omitempty"`
+	EventVersion string      `json:"eventVersion"`
+	ReceivedAt   time.Time   `json:"receivedAt"`
+}
+
+// OrderQuery filters and pages GET /orders. Zero values leave a filter
+// unset; amount bounds are inclusive and time bounds apply to ReceivedAt.
+type OrderQuery struct {
+	MinAmount      *money.Money
+	MaxAmount      *money.Money
+	ReceivedAfter  time.Time
+	ReceivedBefore time.Time
+	Limit          int
+	Offset         int
+}
+
+// OrderPage is one page of orders, newest first, with the total number of
+// orders matching the query.
+type OrderPage struct {
+	Orders []StoredOrder `json:"orders"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// OrderReader serves consumed orders.
+type OrderReader interface {
+	Get(ctx context.Context, id string) (StoredOrder, error)
+	List(ctx context.Context, query OrderQuery) (OrderPage, error)
+}
+
+// OrderStore persists consumed orders and serves them back.
+type OrderStore interface {
+	OrderEventHandler
+	OrderReader
+}
+
+type orderQueryHandlers struct {
+	reader OrderReader
+}
+
+func (h orderQueryHandlers) get(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	order, err := h.reader.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to load order", "id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load order"})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h orderQueryHandlers) list(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := h.reader.List(c.Request.Context(), query)
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to list orders", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list orders"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// parseOrderQuery reads limit, offset, minAmount, maxAmount, receivedAfter
+// and receivedBefore (RFC 3339) query parameters.
+func parseOrderQuery(c *gin.Context) (OrderQuery, error) {
+	limit, offset, err := parsePage(c)
+	if err != nil {
+		return OrderQuery{}, err
+	}
+	query := OrderQuery{Limit: limit, Offset: offset}
+
+	amountBounds := []struct {
+		param  string
+		target **money.Money
+	}{{"minAmount", &query.MinAmount}, {"maxAmount", &query.MaxAmount}}
+	for _, bound := range amountBounds {
+		param, target := bound.param, bound.target
+		if value := strings.TrimSpace(c.Query(param)); value != "" {
+			amount, err := money.Parse(value)
+			if err != nil {
+				return OrderQuery{}, fmt.Errorf("%s must be a decimal number", param)
+			}
+			*target = &amount
+		}
+	}
+	timeBounds := []struct {
+		param  string
+		target *time.Time
+	}{{"receivedAfter", &query.ReceivedAfter}, {"receivedBefore", &query.ReceivedBefore}}
+	for _, bound := range timeBounds {
+		param, target := bound.param, bound.target
+		if value := strings.TrimSpace(c.Query(param)); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return OrderQuery{}, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+			}
+			*target = parsed
+		}
+	}
+	return query, nil
+}
+
+// parsePage reads the limit and offset query parameters shared by the list
+// endpoints.
+func parsePage(c *gin.Context) (limit, offset int, err error) {
+	limit = defaultOrderPageSize
+	if value := strings.TrimSpace(c.Query("limit")); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxOrderPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxOrderPageSize)
+		}
+	}
+	if value := strings.TrimSpace(c.Query("offset")); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must not be negative")
+		}
+	}
+	return limit, offset, nil
+}
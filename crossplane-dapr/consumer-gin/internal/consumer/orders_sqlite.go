@@ -0,0 +1,192 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Registers the pure Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+)
+
+const orderStoreSchema = `
+CREATE TABLE IF NOT EXISTS orders (
+	id            TEXT PRIMARY KEY,
+	event_version INTEGER NOT NULL,
+	amount        TEXT NOT NULL,
+	amount_key    TEXT NOT NULL,
+	payload       TEXT NOT NULL,
+	received_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS orders_received_at ON orders (received_at);
+CREATE INDEX IF NOT EXISTS orders_amount_key ON orders (amount_key);
+`
+
+// SQLiteOrderStore is an OrderStore backed by an embedded SQLite file. Amounts
+// are stored exactly and filtered by their money.Money.SortKey, and event
+// versions are stored as numbers so v10 is newer than v9. Redelivered events
+// keep the first ReceivedAt, and an order already stored from a newer event
+// version is not overwritten by an older one.
+type SQLiteOrderStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func OpenSQLiteOrderStore(path string) (*SQLiteOrderStore, error) {
+	db, err := openSQLite(path, orderStoreSchema)
+	if err != nil {
+		return nil, fmt.Errorf("open order store: %w", err)
+	}
+	return &SQLiteOrderStore{db: db, now: time.Now}, nil
+}
+
+// openSQLite opens the database file at path and applies schema.
+func openSQLite(path, schema string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	return db, nil
+}
+
+func (s *SQLiteOrderStore) Close() error {
+	return s.db.Close()
+}
+
+// Handle stores the order carried by event.
+func (s *SQLiteOrderStore) Handle(ctx context.Context, event OrderEvent) error {
+	var amount money.Money
+	switch typed := event.(type) {
+	case OrderCreatedV1:
+		amount = typed.Amount
+	case OrderCreatedV2:
+		amount = typed.Amount
+	default:
+		return fmt.Errorf("store order %s: unsupported event %T: %w", event.OrderID(), event, ErrDropEvent)
+	}
+	version, err := eventVersionNumber(event.Version())
+	if err != nil {
+		return fmt.Errorf("store order %s: %w: %w", event.OrderID(), err, ErrDropEvent)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode order %s: %w", event.OrderID(), err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+INSERT INTO orders (id, event_version, amount, amount_key, payload, received_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	event_version = excluded.event_version,
+	amount        = excluded.amount,
+	amount_key    = excluded.amount_key,
+	payload       = excluded.payload
+WHERE excluded.event_version >= orders.event_version`,
+		event.OrderID(), version, amount.String(), amount.SortKey(), string(payload), s.now().UTC().UnixNano())
+	if err != nil {
+		return fmt.Errorf("store order %s: %w", event.OrderID(), err)
+	}
+	return nil
+}
+
+// eventVersionNumber returns N for the event version "vN".
+func eventVersionNumber(version string) (int, error) {
+	digits, ok := strings.CutPrefix(version, "v")
+	number, err := strconv.Atoi(digits)
+	if !ok || err != nil || number < 1 {
+		return 0, fmt.Errorf("invalid event version %q", version)
+	}
+	return number, nil
+}
+
+func (s *SQLiteOrderStore) Get(ctx context.Context, id string) (StoredOrder, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT payload, received_at FROM orders WHERE id = ?`, id)
+	order, err := scanStoredOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StoredOrder{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return StoredOrder{}, fmt.Errorf("load order %s: %w", id, err)
+	}
+	return order, nil
+}
+
+// List returns the orders matching query, most recently received first.
+func (s *SQLiteOrderStore) List(ctx context.Context, query OrderQuery) (OrderPage, error) {
+	var conditions []string
+	var args []any
+	if query.MinAmount != nil {
+		conditions = append(conditions, "amount_key >= ?")
+		args = append(args, query.MinAmount.SortKey())
+	}
+	if query.MaxAmount != nil {
+		conditions = append(conditions, "amount_key <= ?")
+		args = append(args, query.MaxAmount.SortKey())
+	}
+	if !query.ReceivedAfter.IsZero() {
+		conditions = append(conditions, "received_at > ?")
+		args = append(args, query.ReceivedAfter.UnixNano())
+	}
+	if !query.ReceivedBefore.IsZero() {
+		conditions = append(conditions, "received_at < ?")
+		args = append(args, query.ReceivedBefore.UnixNano())
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultOrderPageSize
+	}
+
+	page := OrderPage{Orders: []StoredOrder{}, Limit: query.Limit, Offset: query.Offset}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders"+where, args...).Scan(&page.Total); err != nil {
+		return OrderPage{}, fmt.Errorf("count orders: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT payload, received_at FROM orders"+where+" ORDER BY received_at DESC, id LIMIT ? OFFSET ?",
+		append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return OrderPage{}, fmt.Errorf("list orders: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		order, err := scanStoredOrder(rows)
+		if err != nil {
+			return OrderPage{}, fmt.Errorf("list orders: %w", err)
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return OrderPage{}, fmt.Errorf("list orders: %w", err)
+	}
+	return page, nil
+}
+
+func scanStoredOrder(row interface{ Scan(...any) error }) (StoredOrder, error) {
+	var payload string
+	var receivedAt int64
+	if err := row.Scan(&payload, &receivedAt); err != nil {
+		return StoredOrder{}, err
+	}
+	var order StoredOrder
+	if err := json.Unmarshal([]byte(payload), &order); err != nil {
+		return StoredOrder{}, fmt.Errorf("decode stored order: %w", err)
+	}
+	order.ReceivedAt = time.Unix(0, receivedAt).UTC()
+	return order, nil
+}
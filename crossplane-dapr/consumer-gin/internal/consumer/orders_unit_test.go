@@ -0,0 +1,227 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func openTestOrderStore(t *testing.T) *SQLiteOrderStore {
+	t.Helper()
+	store, err := OpenSQLiteOrderStore(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("open order store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// stepClock returns a clock that advances by one minute per call.
+func stepClock(start time.Time) func() time.Time {
+	next := start
+	return func() time.Time {
+		now := next
+		next = next.Add(time.Minute)
+		return now
+	}
+}
+
+func TestSQLiteOrderStoreKeepsNewestVersionAndFirstDelivery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestOrderStore(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.now = stepClock(start)
+
+	createdAt := time.Date(2026, 3, 1, 11, 59, 0, 0, time.UTC)
+	v2 := OrderCreatedV2{
+		ID:           "ORD-1",
+		Amount:       money.MustParse("55.10"),
+		Currency:     "EUR",
+		CustomerID:   "CUST-1",
+		LineItems:    []LineItem{{SKU: "SKU-1", Quantity: 1, UnitPrice: money.MustParse("55.10")}},
+		CreatedAt:    createdAt,
+		EventVersion: "v2",
+	}
+	for _, event := range []OrderEvent{v2, OrderCreatedV1{ID: "ORD-1", Amount: money.MustParse("55.10"), EventVersion: "v1"}, v2} {
+		if err := store.Handle(ctx, event); err != nil {
+			t.Fatalf("handle %s: %v", event.Version(), err)
+		}
+	}
+
+	order, err := store.Get(ctx, "ORD-1")
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	if order.EventVersion != "v2" || order.Currency != "EUR" || order.Amount.String() != "55.10" || len(order.LineItems) != 1 {
+		t.Fatalf("expected the v2 order to survive the v1 redelivery, got %+v", order)
+	}
+	if !order.ReceivedAt.Equal(start) || order.CreatedAt == nil || !order.CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected timestamps: received %v created %v", order.ReceivedAt, order.CreatedAt)
+	}
+	if _, err := store.Get(ctx, "ORD-404"); err != ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteOrderStoreComparesExactly(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestOrderStore(t)
+	store.now = stepClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	for id, amount := range map[string]money.Money{
+		"ORD-1": money.MustParse("0.1"),
+		"ORD-2": money.MustParse("0.10"),
+		"ORD-3": money.MustParse("0.1").Add(money.MustParse("0.2")),
+		"ORD-4": money.MustParse("0.30000000000000001"),
+		"ORD-5": money.MustParse("-0.1"),
+		"ORD-6": money.MustParse("1E+2"),
+	} {
+		if err := store.Handle(ctx, OrderCreatedV1{ID: id, Amount: amount}); err != nil {
+			t.Fatalf("handle %s: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		min, max string
+		wantIDs  []string
+	}{
+		{min: "0.1", max: "0.1", wantIDs: []string{"ORD-1", "ORD-2"}},
+		{min: "0.3", max: "0.3", wantIDs: []string{"ORD-3"}},
+		{min: "0.300", max: "0.30000000000000001", wantIDs: []string{"ORD-3", "ORD-4"}},
+		{min: "-0.1", max: "0.09999999999999999", wantIDs: []string{"ORD-5"}},
+		{min: "99.99", max: "100.00", wantIDs: []string{"ORD-6"}},
+	}
+	for _, tc := range tests {
+		minAmount, maxAmount := money.MustParse(tc.min), money.MustParse(tc.max)
+		page, err := store.List(ctx, OrderQuery{MinAmount: &minAmount, MaxAmount: &maxAmount})
+		if err != nil {
+			t.Fatalf("list %s..%s: %v", tc.min, tc.max, err)
+		}
+		var got []string
+		for _, order := range page.Orders {
+			got = append(got, order.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tc.wantIDs) {
+			t.Fatalf("list %s..%s = %v, want %v", tc.min, tc.max, got, tc.wantIDs)
+		}
+	}
+
+	// A stored v10 event must not be overwritten by a v2 redelivery; as text
+	// "10" sorts before "2".
+	if _, err := store.db.ExecContext(ctx, `UPDATE orders SET event_version = 10, payload = '{"id":"ORD-1","amount":0.1,"eventVersion":"v10"}' WHERE id = 'ORD-1'`); err != nil {
+		t.Fatalf("store v10 order: %v", err)
+	}
+	if err := store.Handle(ctx, OrderCreatedV2{ID: "ORD-1", Amount: money.MustParse("0.2"), EventVersion: "v2"}); err != nil {
+		t.Fatalf("handle v2: %v", err)
+	}
+	if order, err := store.Get(ctx, "ORD-1"); err != nil || order.EventVersion != "v10" {
+		t.Fatalf("get ORD-1 = %+v, %v, want the v10 order", order, err)
+	}
+}
+
+func TestEventVersionNumber(t *testing.T) {
+	t.Parallel()
+
+	for version, want := range map[string]int{"v1": 1, "v9": 9, "v10": 10} {
+		if got, err := eventVersionNumber(version); err != nil || got != want {
+			t.Fatalf("eventVersionNumber(%q) = %d, %v, want %d", version, got, err, want)
+		}
+	}
+	for _, version := range []string{"", "v", "10", "v0", "v-1", "vx"} {
+		if _, err := eventVersionNumber(version); err == nil {
+			t.Fatalf("eventVersionNumber(%q) error = nil", version)
+		}
+	}
+}
+
+func getJSON(t *testing.T, router http.Handler, target string, into any) int {
+	t.Helper()
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+	if into != nil && res.Code == http.StatusOK {
+		if err := json.Unmarshal(res.Body.Bytes(), into); err != nil {
+			t.Fatalf("decode %s response: %v", target, err)
+		}
+	}
+	return res.Code
+}
+
+func TestOrderQueryAPI(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOrderStore(t)
+	store.now = stepClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithOrderStore(store))
+
+	for _, body := range []string{
+		`{"data":{"id":"ORD-1","amount":10.00,"eventVersion":"v1"}}`,
+		`{"data":{"id":"ORD-2","amount":25.50,"eventVersion":"v1"}}`,
+		`{"data":{"id":"ORD-3","amount":99.99,"eventVersion":"v1"}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code != http.StatusOK || !bytes.Contains(res.Body.Bytes(), []byte(`"SUCCESS"`)) {
+			t.Fatalf("consume %s: %d %s", body, res.Code, res.Body.String())
+		}
+	}
+
+	var order StoredOrder
+	if code := getJSON(t, router, "/orders/ORD-2", &order); code != http.StatusOK || order.Amount.String() != "25.50" {
+		t.Fatalf("GET /orders/ORD-2 = %d %+v", code, order)
+	}
+	if code := getJSON(t, router, "/orders/ORD-404", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown order, got %d", code)
+	}
+
+	tests := []struct {
+		query   string
+		wantIDs []string
+		total   int
+	}{
+		{query: "", wantIDs: []string{"ORD-3", "ORD-2", "ORD-1"}, total: 3},
+		{query: "?limit=2", wantIDs: []string{"ORD-3", "ORD-2"}, total: 3},
+		{query: "?limit=2&offset=2", wantIDs: []string{"ORD-1"}, total: 3},
+		{query: "?minAmount=25.5&maxAmount=99.98", wantIDs: []string{"ORD-2"}, total: 1},
+		{query: "?receivedAfter=2026-03-01T12:00:00Z", wantIDs: []string{"ORD-3", "ORD-2"}, total: 2},
+		{query: "?receivedBefore=2026-03-01T12:01:00Z", wantIDs: []string{"ORD-1"}, total: 1},
+	}
+	for _, tc := range tests {
+		var page OrderPage
+		if code := getJSON(t, router, "/orders"+tc.query, &page); code != http.StatusOK {
+			t.Fatalf("GET /orders%s = %d", tc.query, code)
+		}
+		if page.Total != tc.total || len(page.Orders) != len(tc.wantIDs) {
+			t.Fatalf("GET /orders%s = %+v, want %v of %d", tc.query, page, tc.wantIDs, tc.total)
+		}
+		for i, id := range tc.wantIDs {
+			if page.Orders[i].ID != id {
+				t.Fatalf("GET /orders%s order %d = %s, want %s", tc.query, i, page.Orders[i].ID, id)
+			}
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=501", "?offset=-1", "?minAmount=ten", "?receivedAfter=yesterday"} {
+		if code := getJSON(t, router, "/orders"+query, nil); code != http.StatusBadRequest {
+			t.Fatalf("GET /orders%s = %d, want 400", query, code)
+		}
+	}
+}
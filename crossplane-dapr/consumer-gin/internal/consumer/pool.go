@@ -0,0 +1,198 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultWorkerConcurrency = 16
+	defaultWorkerQueueSize   = 64
+)
+
+var (
+	// ErrPoolFull is returned by WorkerPool.Submit when every worker is busy
+	// and the queue is full. Deliveries are answered with 429 and RETRY so
+	// Dapr backs off.
+	ErrPoolFull = errors.New("worker pool is full")
+	// ErrPoolDraining is returned by WorkerPool.Submit once Drain was called.
+	ErrPoolDraining = errors.New("worker pool is draining")
+)
+
+// WorkerPoolConfig bounds event processing: at most Concurrency events are
+// handled at a time and at most QueueSize more wait for a worker. A zero
+// QueueSize rejects every submission that cannot start right away.
+type WorkerPoolConfig struct {
+	Concurrency int
+	QueueSize   int
+}
+
+func (c WorkerPoolConfig) concurrency() int {
+	if c.Concurrency <= 0 {
+		return defaultWorkerConcurrency
+	}
+	return c.Concurrency
+}
+
+func (c WorkerPoolConfig) queueSize() int {
+	if c.QueueSize < 0 {
+		return defaultWorkerQueueSize
+	}
+	return c.QueueSize
+}
+
+// WorkerPool runs event processing with bounded concurrency. Work submitted
+// with the same partition key runs one at a time, in submission order; work
+// with different keys runs in parallel.
+type WorkerPool struct {
+	workers   chan struct{}
+	capacity  int
+	mu        sync.Mutex
+	tails     map[string]chan struct{}
+	queued    int
+	inFlight  int
+	draining  bool
+	pending   sync.WaitGroup
+	drainedCh chan struct{}
+}
+
+func NewWorkerPool(cfg WorkerPoolConfig) *WorkerPool {
+	return &WorkerPool{
+		workers:   make(chan struct{}, cfg.concurrency()),
+		capacity:  cfg.concurrency() + cfg.queueSize(),
+		tails:     map[string]chan struct{}{},
+		drainedCh: make(chan struct{}),
+	}
+}
+
+// Submit runs work once a worker is free and every earlier submission with
+// the same key has finished, and returns its status. It fails fast with
+// ErrPoolFull or ErrPoolDraining, and returns ctx.Err() when ctx is done
+// before work starts.
+func (p *WorkerPool) Submit(ctx context.Context, key string, work func(context.Context) SubscriptionStatus) (SubscriptionStatus, error) {
+	p.mu.Lock()
+	if p.draining {
+		p.mu.Unlock()
+		return SubscriptionRetry, ErrPoolDraining
+	}
+	if p.queued+p.inFlight >= p.capacity {
+		p.mu.Unlock()
+		return SubscriptionRetry, ErrPoolFull
+	}
+	p.queued++
+	p.pending.Add(1)
+	done := make(chan struct{})
+	previous := p.tails[key]
+	p.tails[key] = done
+	p.mu.Unlock()
+
+	// done is closed only after previous is, so later work on the key keeps
+	// waiting for earlier work even when this submission gives up.
+	release := func() {
+		if previous != nil {
+			<-previous
+		}
+		p.mu.Lock()
+		if p.tails[key] == done {
+			delete(p.tails, key)
+		}
+		p.mu.Unlock()
+		close(done)
+		p.pending.Done()
+	}
+	abandon := func(err error) (SubscriptionStatus, error) {
+		p.mu.Lock()
+		p.queued--
+		p.mu.Unlock()
+		go release()
+		return SubscriptionRetry, fmt.Errorf("wait for worker: %w", err)
+	}
+
+	if previous != nil {
+		select {
+		case <-previous:
+		case <-ctx.Done():
+			return abandon(ctx.Err())
+		}
+	}
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		return abandon(ctx.Err())
+	}
+
+	p.mu.Lock()
+	p.queued--
+	p.inFlight++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+		<-p.workers
+		release()
+	}()
+	return work(ctx), nil
+}
+
+// QueueDepth is the number of submissions waiting for a worker or for
+// earlier work on their key.
+func (p *WorkerPool) QueueDepth() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queued
+}
+
+// InFlight is the number of submissions being processed.
+func (p *WorkerPool) InFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight
+}
+
+// Drain stops accepting work and waits until submitted work has finished
+// or ctx is done. It is safe to call more than once.
+func (p *WorkerPool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.draining {
+		p.draining = true
+		go func() {
+			p.pending.Wait()
+			close(p.drainedCh)
+		}()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.drainedCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("drain worker pool: %w", ctx.Err())
+	}
+}
+
+func registerWorkerPoolMetrics(registerer prometheus.Registerer, pool *WorkerPool) {
+	registerer.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "orders_consume_queue_depth",
+			Help: "Order events waiting for a consumer-gin worker or for earlier events with the same partition key.",
+		}, func() float64 { return float64(pool.QueueDepth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "orders_consume_in_flight",
+			Help: "Order events being processed by consumer-gin workers.",
+		}, func() float64 { return float64(pool.InFlight()) }),
+	)
+}
+
+// partitionKey orders processing of an event: the CloudEvent partitionkey
+// extension when set, otherwise the order id.
+func partitionKey(metadata CloudEventMetadata, event OrderEvent) string {
+	if key := metadata.Extensions["partitionkey"]; key != "" {
+		return key
+	}
+	return event.OrderID()
+}
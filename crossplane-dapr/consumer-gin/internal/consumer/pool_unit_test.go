@@ -0,0 +1,239 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// eventually fails the test when condition does not hold within a second.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func gaugeValue(t *testing.T, gatherer prometheus.Gatherer, name string) float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) > 0 {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("gauge %s not registered", name)
+	return 0
+}
+
+func TestWorkerPoolSerializesWorkPerKey(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(WorkerPoolConfig{Concurrency: 4, QueueSize: 4})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Submit(context.Background(), "ORD-1", func(context.Context) SubscriptionStatus {
+				if i == 0 {
+					<-release
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				return SubscriptionSuccess
+			})
+			if err != nil {
+				t.Errorf("submit %d: %v", i, err)
+			}
+		}()
+		// Submit the next one only once this one holds its place in line.
+		eventually(t, func() bool { return pool.InFlight()+pool.QueueDepth() == i+1 })
+	}
+	if got := pool.InFlight(); got != 1 {
+		t.Fatalf("in flight = %d, want 1 while the first event of the key blocks", got)
+	}
+	close(release)
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("processing order = %v, want [0 1 2]", order)
+	}
+}
+
+func TestWorkerPoolRunsKeysInParallel(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(WorkerPoolConfig{Concurrency: 2})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, key := range []string{"ORD-1", "ORD-2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = pool.Submit(context.Background(), key, func(context.Context) SubscriptionStatus {
+				<-release
+				return SubscriptionSuccess
+			})
+		}()
+	}
+	eventually(t, func() bool { return pool.InFlight() == 2 })
+
+	if _, err := pool.Submit(context.Background(), "ORD-3", func(context.Context) SubscriptionStatus { return SubscriptionSuccess }); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("submit to a full pool error = %v, want ErrPoolFull", err)
+	}
+	close(release)
+	wg.Wait()
+	if got, want := pool.InFlight()+pool.QueueDepth(), 0; got != want {
+		t.Fatalf("pending work = %d, want %d", got, want)
+	}
+}
+
+func TestWorkerPoolKeepsKeyOrderWhenAWaiterGivesUp(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(WorkerPoolConfig{Concurrency: 2, QueueSize: 2})
+	release := make(chan struct{})
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		_, _ = pool.Submit(context.Background(), "ORD-1", func(context.Context) SubscriptionStatus {
+			<-release
+			return SubscriptionSuccess
+		})
+	}()
+	eventually(t, func() bool { return pool.InFlight() == 1 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	status, err := pool.Submit(ctx, "ORD-1", func(context.Context) SubscriptionStatus {
+		t.Error("work ran after its context was cancelled")
+		return SubscriptionSuccess
+	})
+	if status != SubscriptionRetry || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled submit = %s, %v, want RETRY and context.Canceled", status, err)
+	}
+
+	third := make(chan struct{})
+	go func() {
+		defer close(third)
+		_, _ = pool.Submit(context.Background(), "ORD-1", func(context.Context) SubscriptionStatus { return SubscriptionSuccess })
+	}()
+	select {
+	case <-third:
+		t.Fatal("later work on the key ran before earlier work finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-firstDone
+	<-third
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(WorkerPoolConfig{Concurrency: 1})
+	release := make(chan struct{})
+	finished := make(chan SubscriptionStatus, 1)
+	go func() {
+		status, _ := pool.Submit(context.Background(), "ORD-1", func(context.Context) SubscriptionStatus {
+			<-release
+			return SubscriptionSuccess
+		})
+		finished <- status
+	}()
+	eventually(t, func() bool { return pool.InFlight() == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drain with busy worker error = %v, want deadline exceeded", err)
+	}
+	if _, err := pool.Submit(context.Background(), "ORD-2", func(context.Context) SubscriptionStatus { return SubscriptionSuccess }); !errors.Is(err, ErrPoolDraining) {
+		t.Fatalf("submit while draining error = %v, want ErrPoolDraining", err)
+	}
+
+	close(release)
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if status := <-finished; status != SubscriptionSuccess {
+		t.Fatalf("in-flight work status = %s, want SUCCESS", status)
+	}
+}
+
+func TestConsumeAnswers429WhenWorkerPoolIsFull(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	handler := OrderEventHandlerFunc(func(context.Context, OrderEvent) error {
+		<-release
+		return nil
+	})
+	pool := NewWorkerPool(WorkerPoolConfig{Concurrency: 1})
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}, registry, registry, WithOrderEventHandler(handler), WithWorkerPool(pool))
+
+	first := make(chan string, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		first <- res.Body.String()
+	}()
+	eventually(t, func() bool { return gaugeValue(t, registry, "orders_consume_in_flight") == 1 })
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"data":{"id":"ORD-2","amount":10,"eventVersion":"v1"}}`))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusTooManyRequests || res.Body.String() != `{"status":"RETRY"}` {
+		t.Fatalf("POST /orders on a full pool = %d %s, want 429 RETRY", res.Code, res.Body.String())
+	}
+
+	close(release)
+	if body := <-first; body != `{"status":"SUCCESS"}` {
+		t.Fatalf("first delivery = %s, want SUCCESS", body)
+	}
+	if got := gaugeValue(t, registry, "orders_consume_in_flight"); got != 0 {
+		t.Fatalf("in flight after delivery = %v, want 0", got)
+	}
+	if got := gaugeValue(t, registry, "orders_consume_queue_depth"); got != 0 {
+		t.Fatalf("queue depth after delivery = %v, want 0", got)
+	}
+	if got := outcomeCount(t, registry, SubscriptionRetry); got != 1 {
+		t.Fatalf("RETRY outcomes = %v, want 1", got)
+	}
+}
+
+func TestPartitionKeyPrefersCloudEventExtension(t *testing.T) {
+	t.Parallel()
+
+	event := OrderCreatedV1{ID: "ORD-1"}
+	if got := partitionKey(CloudEventMetadata{}, event); got != "ORD-1" {
+		t.Fatalf("partition key = %q, want order id", got)
+	}
+	metadata := CloudEventMetadata{Extensions: map[string]string{"partitionkey": "customer-7"}}
+	if got := partitionKey(metadata, event); got != "customer-7" {
+		t.Fatalf("partition key = %q, want partitionkey extension", got)
+	}
+}
@@ -0,0 +1,315 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrQuarantinedMessageNotFound is returned for unknown quarantine ids.
+var ErrQuarantinedMessageNotFound = errors.New("quarantined message not found")
+
+// QuarantineConfig selects the SQLite store that keeps poison messages and
+// the dead-letter topic declared on the default subscription. Dapr moves
+// messages to DeadLetterTopic once its retries are exhausted and delivers
+// them to DeadLetterRoute. The /admin/quarantine endpoints require
+// AdminToken as a bearer token and are refused while it is empty.
+type QuarantineConfig struct {
+	Enabled         bool
+	Path            string
+	DeadLetterTopic string
+	DeadLetterRoute string
+	AdminToken      string
+}
+
+// Quarantine reasons recorded with each message.
+const (
+	QuarantineUndecodable  = "undecodable"
+	QuarantineRejected     = "rejected"
+	QuarantineDeadLettered = "dead-lettered"
+)
+
+// QuarantinedMessage is a message consumer-gin could not process, kept with
+// its raw payload and request headers so it can be inspected and re-driven.
+// PubSubName and Topic name the topic a re-drive publishes to.
+type QuarantinedMessage struct {
+	ID            string      `json:"id"`
+	Reason        string      `json:"reason"`
+	Error         string      `json:"error,omitempty"`
+	PubSubName    string      `json:"pubsubname"`
+	Topic         string      `json:"topic"`
+	Route         string      `json:"route"`
+	MessageID     string      `json:"messageId,omitempty"`
+	MessageSource string      `json:"messageSource,omitempty"`
+	Headers       http.Header `json:"headers,omitempty"`
+	PayloadSize   int         `json:"payloadSize"`
+	// Payload is omitted from list responses.
+	Payload       string    `json:"payload,omitempty"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// QuarantinePage is one page of quarantined messages, newest first.
+type QuarantinePage struct {
+	Messages []QuarantinedMessage `json:"messages"`
+	Total    int                  `json:"total"`
+	Limit    int                  `json:"limit"`
+	Offset   int                  `json:"offset"`
+}
+
+// QuarantineStore keeps poison messages. Quarantine keeps the first copy of
+// a message whose id is already stored.
+type QuarantineStore interface {
+	Quarantine(ctx context.Context, message QuarantinedMessage) error
+	Get(ctx context.Context, id string) (QuarantinedMessage, error)
+	List(ctx context.Context, limit, offset int) (QuarantinePage, error)
+	Delete(ctx context.Context, id string) error
+	Purge(ctx context.Context) (int, error)
+}
+
+// Republisher publishes a raw payload to a topic for a re-drive.
+type Republisher interface {
+	Republish(ctx context.Context, pubsubName, topic string, payload []byte, contentType string) error
+}
+
+// HTTPDoer is the subset of *http.Client used to call the Dapr sidecar.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DaprRepublisher publishes through the Dapr HTTP publish API. CloudEvent
+// payloads are sent as application/cloudevents+json so Dapr forwards them
+// unchanged.
+type DaprRepublisher struct {
+	httpClient HTTPDoer
+	baseURL    string
+}
+
+func NewDaprRepublisher(httpClient HTTPDoer, baseURL string) *DaprRepublisher {
+	return &DaprRepublisher{httpClient: httpClient, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (p *DaprRepublisher) Republish(ctx context.Context, pubsubName, topic string, payload []byte, contentType string) error {
+	publishURL := fmt.Sprintf("%s/v1.0/publish/%s/%s", p.baseURL, url.PathEscape(pubsubName), url.PathEscape(topic))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, publishURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create publish request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("publish request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("publish endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redactedHeaders are not stored with quarantined messages.
+var redactedHeaders = []string{"Authorization", "Cookie", "Dapr-Api-Token"}
+
+// newQuarantinedMessage captures a delivery. Redeliveries of the same
+// CloudEvent, or of the same raw payload, get the same id.
+func newQuarantinedMessage(header http.Header, payload []byte, reason string, cause error, pubsubName, topic, route string) QuarantinedMessage {
+	headers := header.Clone()
+	for _, name := range redactedHeaders {
+		headers.Del(name)
+	}
+	messageID, messageSource := cloudEventIdentity(payload)
+	identity := inboxKey(messageID, messageSource)
+	if identity == "" {
+		identity = string(payload)
+	}
+	digest := sha256.Sum256([]byte(topic + "\n" + identity))
+
+	message := QuarantinedMessage{
+		ID:            hex.EncodeToString(digest[:16]),
+		Reason:        reason,
+		PubSubName:    pubsubName,
+		Topic:         topic,
+		Route:         route,
+		MessageID:     messageID,
+		MessageSource: messageSource,
+		Headers:       headers,
+		PayloadSize:   len(payload),
+		Payload:       string(payload),
+	}
+	if cause != nil {
+		message.Error = cause.Error()
+	}
+	return message
+}
+
+// redrivePayload rebuilds binary-mode CloudEvents in structured mode from the
+// stored ce-* headers, so Dapr does not wrap the bare data in a new event.
+func redrivePayload(message QuarantinedMessage) ([]byte, string, error) {
+	payload := []byte(message.Payload)
+	var envelope CloudEventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err == nil && envelope.SpecVersion != "" {
+		return payload, "application/cloudevents+json", nil
+	}
+	if message.Headers.Get(binaryHeaderPrefix+"specversion") == "" {
+		return payload, "application/json", nil
+	}
+
+	event := map[string]any{}
+	for name, values := range message.Headers {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, binaryHeaderPrefix) && len(values) > 0 {
+			event[strings.TrimPrefix(name, binaryHeaderPrefix)] = values[0]
+		}
+	}
+	contentType := message.Headers.Get("Content-Type")
+	if contentType != "" {
+		event["datacontenttype"] = contentType
+	}
+	switch {
+	case len(payload) == 0:
+	case json.Valid(payload) && (contentType == "" || strings.Contains(contentType, "json")):
+		event["data"] = json.RawMessage(payload)
+	default:
+		event["data_base64"] = base64.StdEncoding.EncodeToString(payload)
+	}
+	structured, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode binary cloud event: %w", err)
+	}
+	return structured, "application/cloudevents+json", nil
+}
+
+// requireAdminToken refuses every request when token is empty.
+func requireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API is disabled; set ADMIN_API_TOKEN to enable it"})
+			return
+		}
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			loggerFromGinContext(c).WarnContext(c.Request.Context(), "rejected unauthorized admin request", "path", c.FullPath())
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+type quarantineAdminHandlers struct {
+	store       QuarantineStore
+	republisher Republisher
+}
+
+func (h quarantineAdminHandlers) list(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	limit, offset, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := h.store.List(c.Request.Context(), limit, offset)
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to list quarantined messages", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list quarantined messages"})
+		return
+	}
+	for i := range page.Messages {
+		page.Messages[i].Payload = ""
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h quarantineAdminHandlers) get(c *gin.Context) {
+	message, ok := h.load(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// redrive publishes the message back to its topic and removes it from the
+// quarantine.
+func (h quarantineAdminHandlers) redrive(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	message, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if h.republisher == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "re-drive is not configured"})
+		return
+	}
+	payload, contentType, err := redrivePayload(message)
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to build re-drive payload", "quarantineId", message.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build re-drive payload"})
+		return
+	}
+	if err := h.republisher.Republish(c.Request.Context(), message.PubSubName, message.Topic, payload, contentType); err != nil {
+		requestLogger.WarnContext(c.Request.Context(), "failed to re-drive quarantined message", "quarantineId", message.ID, "topic", message.Topic, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to re-publish message"})
+		return
+	}
+	if err := h.store.Delete(c.Request.Context(), message.ID); err != nil && !errors.Is(err, ErrQuarantinedMessageNotFound) {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to remove re-driven message from quarantine", "quarantineId", message.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "message re-published but not removed from quarantine"})
+		return
+	}
+	requestLogger.InfoContext(c.Request.Context(), "re-drove quarantined message", "quarantineId", message.ID, "pubsub", message.PubSubName, "topic", message.Topic)
+	c.JSON(http.StatusOK, gin.H{"id": message.ID, "pubsubname": message.PubSubName, "topic": message.Topic, "status": "redriven"})
+}
+
+func (h quarantineAdminHandlers) delete(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	err := h.store.Delete(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrQuarantinedMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "quarantined message not found"})
+		return
+	}
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to delete quarantined message", "quarantineId", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete quarantined message"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h quarantineAdminHandlers) purge(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+	purged, err := h.store.Purge(c.Request.Context())
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to purge quarantine", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to purge quarantine"})
+		return
+	}
+	requestLogger.InfoContext(c.Request.Context(), "purged quarantine", "purged", purged)
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+func (h quarantineAdminHandlers) load(c *gin.Context) (QuarantinedMessage, bool) {
+	requestLogger := loggerFromGinContext(c)
+	message, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrQuarantinedMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "quarantined message not found"})
+		return QuarantinedMessage{}, false
+	}
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "failed to load quarantined message", "quarantineId", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quarantined message"})
+		return QuarantinedMessage{}, false
+	}
+	return message, true
+}
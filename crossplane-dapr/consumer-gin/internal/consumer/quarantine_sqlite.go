@@ -0,0 +1,153 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const quarantineStoreSchema = `
+CREATE TABLE IF NOT EXISTS quarantine (
+	id             TEXT PRIMARY KEY,
+	reason         TEXT NOT NULL,
+	error          TEXT NOT NULL,
+	pubsub_name    TEXT NOT NULL,
+	topic          TEXT NOT NULL,
+	route          TEXT NOT NULL,
+	message_id     TEXT NOT NULL,
+	message_source TEXT NOT NULL,
+	headers        TEXT NOT NULL,
+	payload        BLOB NOT NULL,
+	quarantined_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS quarantine_quarantined_at ON quarantine (quarantined_at);
+`
+
+// SQLiteQuarantineStore is a QuarantineStore backed by an embedded SQLite
+// file.
+type SQLiteQuarantineStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func OpenSQLiteQuarantineStore(path string) (*SQLiteQuarantineStore, error) {
+	db, err := openSQLite(path, quarantineStoreSchema)
+	if err != nil {
+		return nil, fmt.Errorf("open quarantine store: %w", err)
+	}
+	return &SQLiteQuarantineStore{db: db, now: time.Now}, nil
+}
+
+func (s *SQLiteQuarantineStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteQuarantineStore) Quarantine(ctx context.Context, message QuarantinedMessage) error {
+	headers, err := json.Marshal(message.Headers)
+	if err != nil {
+		return fmt.Errorf("encode headers of quarantined message %s: %w", message.ID, err)
+	}
+	quarantinedAt := message.QuarantinedAt
+	if quarantinedAt.IsZero() {
+		quarantinedAt = s.now()
+	}
+	_, err = s.db.ExecContext(ctx, `
+INSERT INTO quarantine (id, reason, error, pubsub_name, topic, route, message_id, message_source, headers, payload, quarantined_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO NOTHING`,
+		message.ID, message.Reason, message.Error, message.PubSubName, message.Topic, message.Route,
+		message.MessageID, message.MessageSource, string(headers), []byte(message.Payload), quarantinedAt.UTC().UnixNano())
+	if err != nil {
+		return fmt.Errorf("quarantine message %s: %w", message.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteQuarantineStore) Get(ctx context.Context, id string) (QuarantinedMessage, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+quarantineColumns+` FROM quarantine WHERE id = ?`, id)
+	message, err := scanQuarantinedMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return QuarantinedMessage{}, ErrQuarantinedMessageNotFound
+	}
+	if err != nil {
+		return QuarantinedMessage{}, fmt.Errorf("load quarantined message %s: %w", id, err)
+	}
+	return message, nil
+}
+
+// List returns quarantined messages, most recently quarantined first.
+func (s *SQLiteQuarantineStore) List(ctx context.Context, limit, offset int) (QuarantinePage, error) {
+	if limit <= 0 {
+		limit = defaultOrderPageSize
+	}
+	page := QuarantinePage{Messages: []QuarantinedMessage{}, Limit: limit, Offset: offset}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quarantine`).Scan(&page.Total); err != nil {
+		return QuarantinePage{}, fmt.Errorf("count quarantined messages: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+quarantineColumns+` FROM quarantine ORDER BY quarantined_at DESC, id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return QuarantinePage{}, fmt.Errorf("list quarantined messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		message, err := scanQuarantinedMessage(rows)
+		if err != nil {
+			return QuarantinePage{}, fmt.Errorf("list quarantined messages: %w", err)
+		}
+		page.Messages = append(page.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return QuarantinePage{}, fmt.Errorf("list quarantined messages: %w", err)
+	}
+	return page, nil
+}
+
+func (s *SQLiteQuarantineStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM quarantine WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete quarantined message %s: %w", id, err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrQuarantinedMessageNotFound
+	}
+	return nil
+}
+
+// Purge deletes every quarantined message and returns how many there were.
+func (s *SQLiteQuarantineStore) Purge(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM quarantine`)
+	if err != nil {
+		return 0, fmt.Errorf("purge quarantine: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purge quarantine: %w", err)
+	}
+	return int(purged), nil
+}
+
+const quarantineColumns = `id, reason, error, pubsub_name, topic, route, message_id, message_source, headers, payload, quarantined_at`
+
+func scanQuarantinedMessage(row interface{ Scan(...any) error }) (QuarantinedMessage, error) {
+	var message QuarantinedMessage
+	var headers string
+	var payload []byte
+	var quarantinedAt int64
+	if err := row.Scan(&message.ID, &message.Reason, &message.Error, &message.PubSubName, &message.Topic, &message.Route,
+		&message.MessageID, &message.MessageSource, &headers, &payload, &quarantinedAt); err != nil {
+		return QuarantinedMessage{}, err
+	}
+	message.Headers = http.Header{}
+	if err := json.Unmarshal([]byte(headers), &message.Headers); err != nil {
+		return QuarantinedMessage{}, fmt.Errorf("decode quarantined message headers: %w", err)
+	}
+	message.Payload = string(payload)
+	message.PayloadSize = len(payload)
+	message.QuarantinedAt = time.Unix(0, quarantinedAt).UTC()
+	return message, nil
+}
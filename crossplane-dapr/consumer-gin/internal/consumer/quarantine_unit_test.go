@@ -0,0 +1,445 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func openTestQuarantineStore(t *testing.T) *SQLiteQuarantineStore {
+	t.Helper()
+	store, err := OpenSQLiteQuarantineStore(filepath.Join(t.TempDir(), "quarantine.db"))
+	if err != nil {
+		t.Fatalf("open quarantine store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+type republishCall struct {
+	pubsubName, topic, payload, contentType string
+}
+
+type fakeRepublisher struct {
+	calls []republishCall
+	err   error
+}
+
+func (p *fakeRepublisher) Republish(_ context.Context, pubsubName, topic string, payload []byte, contentType string) error {
+	p.calls = append(p.calls, republishCall{pubsubName, topic, string(payload), contentType})
+	return p.err
+}
+
+func quarantineConfig() Config {
+	return Config{
+		PubSubName:        "order-pubsub",
+		TopicName:         "orders",
+		SubscriptionRoute: "/orders",
+		Quarantine: QuarantineConfig{
+			Enabled:         true,
+			DeadLetterTopic: "orders-dead-letter",
+			DeadLetterRoute: "/orders/dead-letter",
+			AdminToken:      testAdminToken,
+		},
+	}
+}
+
+const testAdminToken = "test-admin-token"
+
+// asAdmin sends every request to router with the test admin token.
+func asAdmin(router http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+testAdminToken)
+		router.ServeHTTP(w, r)
+	})
+}
+
+func deliver(t *testing.T, router http.Handler, path, body string, header http.Header) SubscriptionStatus {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("POST %s = %d %s", path, res.Code, res.Body.String())
+	}
+	for _, status := range []SubscriptionStatus{SubscriptionSuccess, SubscriptionRetry, SubscriptionDrop} {
+		if strings.Contains(res.Body.String(), string(status)) {
+			return status
+		}
+	}
+	t.Fatalf("POST %s answered %s", path, res.Body.String())
+	return ""
+}
+
+func TestDefaultSubscriptionsDeclareDeadLetterTopic(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	router := NewRouter(quarantineConfig(), registry, registry)
+
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	want := `[{"pubsubname":"order-pubsub","topic":"orders","route":"/orders","deadLetterTopic":"orders-dead-letter"},` +
+		`{"pubsubname":"order-pubsub","topic":"orders-dead-letter","route":"/orders/dead-letter"}]`
+	if res.Code != http.StatusOK || res.Body.String() != want {
+		t.Fatalf("got %d %s, want 200 %s", res.Code, res.Body.String(), want)
+	}
+}
+
+func TestSQLiteQuarantineStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestQuarantineStore(t)
+	store.now = stepClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	first := QuarantinedMessage{ID: "q-1", Reason: QuarantineUndecodable, Error: "bad json", Topic: "orders", Payload: "{", Headers: http.Header{"Traceparent": {"00-abc"}}}
+	for _, message := range []QuarantinedMessage{first, {ID: "q-1", Reason: QuarantineDeadLettered, Payload: "{"}, {ID: "q-2", Topic: "orders", Payload: "{}"}} {
+		if err := store.Quarantine(ctx, message); err != nil {
+			t.Fatalf("quarantine %s: %v", message.ID, err)
+		}
+	}
+
+	got, err := store.Get(ctx, "q-1")
+	if err != nil {
+		t.Fatalf("get q-1: %v", err)
+	}
+	if got.Reason != QuarantineUndecodable || got.Error != "bad json" || got.Payload != "{" || got.PayloadSize != 1 || got.Headers.Get("traceparent") != "00-abc" {
+		t.Fatalf("q-1 = %+v, want the first copy", got)
+	}
+
+	page, err := store.List(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if page.Total != 2 || len(page.Messages) != 1 || page.Messages[0].ID != "q-2" {
+		t.Fatalf("list page = %+v, want newest of 2", page)
+	}
+
+	if err := store.Delete(ctx, "q-2"); err != nil {
+		t.Fatalf("delete q-2: %v", err)
+	}
+	if err := store.Delete(ctx, "q-2"); !errors.Is(err, ErrQuarantinedMessageNotFound) {
+		t.Fatalf("second delete error = %v, want ErrQuarantinedMessageNotFound", err)
+	}
+	if purged, err := store.Purge(ctx); err != nil || purged != 1 {
+		t.Fatalf("purge = %d, %v, want 1", purged, err)
+	}
+	if _, err := store.Get(ctx, "q-1"); !errors.Is(err, ErrQuarantinedMessageNotFound) {
+		t.Fatalf("get after purge error = %v, want ErrQuarantinedMessageNotFound", err)
+	}
+}
+
+func TestPoisonMessagesAreQuarantined(t *testing.T) {
+	t.Parallel()
+
+	store := openTestQuarantineStore(t)
+	registry := prometheus.NewRegistry()
+	router := NewRouter(quarantineConfig(), registry, registry,
+		WithQuarantine(store, &fakeRepublisher{}),
+		WithOrderEventHandler(OrderEventHandlerFunc(func(_ context.Context, event OrderEvent) error {
+			if event.OrderID() == "ORD-REJECTED" {
+				return fmt.Errorf("unknown customer: %w", ErrDropEvent)
+			}
+			return errors.New("database is locked")
+		})),
+	)
+	header := http.Header{"Authorization": {"Bearer secret"}, "Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+
+	deliveries := []struct {
+		path, body string
+		want       SubscriptionStatus
+	}{
+		{path: "/orders", body: `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":`, want: SubscriptionDrop},
+		{path: "/orders", body: `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-2","source":"producer-gin","data":{"id":"ORD-REJECTED","amount":1}}`, want: SubscriptionDrop},
+		{path: "/orders", body: `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-3","source":"producer-gin","data":{"id":"ORD-3","amount":1}}`, want: SubscriptionRetry},
+		{path: "/orders/dead-letter", body: `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-3","source":"producer-gin","data":{"id":"ORD-3","amount":1}}`, want: SubscriptionSuccess},
+		{path: "/orders/dead-letter", body: `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-3","source":"producer-gin","data":{"id":"ORD-3","amount":1}}`, want: SubscriptionSuccess},
+	}
+	for _, delivery := range deliveries {
+		if got := deliver(t, router, delivery.path, delivery.body, header); got != delivery.want {
+			t.Fatalf("POST %s %s = %s, want %s", delivery.path, delivery.body, got, delivery.want)
+		}
+	}
+
+	admin := asAdmin(router)
+	var page QuarantinePage
+	if code := getJSON(t, admin, "/admin/quarantine", &page); code != http.StatusOK {
+		t.Fatalf("GET /admin/quarantine = %d", code)
+	}
+	if page.Total != 3 {
+		t.Fatalf("quarantined %d messages, want 3: %+v", page.Total, page.Messages)
+	}
+	reasons := map[string]QuarantinedMessage{}
+	for _, message := range page.Messages {
+		if message.Payload != "" {
+			t.Fatalf("list returned payload of %s", message.ID)
+		}
+		if message.Headers.Get("Authorization") != "" || message.Headers.Get("Traceparent") == "" {
+			t.Fatalf("headers of %s = %v, want traceparent without authorization", message.ID, message.Headers)
+		}
+		if message.Topic != "orders" || message.PubSubName != "order-pubsub" {
+			t.Fatalf("%s recorded for %s/%s, want order-pubsub/orders", message.ID, message.PubSubName, message.Topic)
+		}
+		reasons[message.Reason] = message
+	}
+	if reasons[QuarantineUndecodable].Error == "" || reasons[QuarantineUndecodable].PayloadSize != len(deliveries[0].body) {
+		t.Fatalf("undecodable message = %+v", reasons[QuarantineUndecodable])
+	}
+	if !strings.Contains(reasons[QuarantineRejected].Error, "unknown customer") || reasons[QuarantineRejected].MessageID != "evt-2" {
+		t.Fatalf("rejected message = %+v", reasons[QuarantineRejected])
+	}
+	if reasons[QuarantineDeadLettered].Route != "/orders/dead-letter" {
+		t.Fatalf("dead-lettered message = %+v", reasons[QuarantineDeadLettered])
+	}
+
+	var inspected QuarantinedMessage
+	if code := getJSON(t, admin, "/admin/quarantine/"+reasons[QuarantineUndecodable].ID, &inspected); code != http.StatusOK {
+		t.Fatalf("GET quarantined message = %d", code)
+	}
+	if inspected.Payload != deliveries[0].body {
+		t.Fatalf("inspected payload = %q, want %q", inspected.Payload, deliveries[0].body)
+	}
+	if code := getJSON(t, admin, "/admin/quarantine/unknown", nil); code != http.StatusNotFound {
+		t.Fatalf("GET unknown quarantined message = %d, want 404", code)
+	}
+	for reason, want := range map[string]float64{QuarantineUndecodable: 1, QuarantineRejected: 1, QuarantineDeadLettered: 2} {
+		if got := counterValue(t, registry, "orders_quarantined_total", "reason", reason); got != want {
+			t.Fatalf("quarantined %s = %v, want %v", reason, got, want)
+		}
+	}
+}
+
+func TestDeadLetterRouteDropsWithoutQuarantine(t *testing.T) {
+	t.Parallel()
+
+	handled := 0
+	registry := prometheus.NewRegistry()
+	router := NewRouter(quarantineConfig(), registry, registry,
+		WithOrderEventHandler(OrderEventHandlerFunc(func(context.Context, OrderEvent) error {
+			handled++
+			return nil
+		})),
+	)
+
+	body := `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":{"id":"ORD-1","amount":1}}`
+	if got := deliver(t, router, "/orders/dead-letter", body, http.Header{}); got != SubscriptionDrop {
+		t.Fatalf("dead-lettered delivery = %s, want DROP", got)
+	}
+	if handled != 0 {
+		t.Fatalf("dead-lettered message reached the handler %d times", handled)
+	}
+}
+
+func TestBulkEntriesAreQuarantinedWithTheirOwnHeaders(t *testing.T) {
+	t.Parallel()
+
+	store := openTestQuarantineStore(t)
+	registry := prometheus.NewRegistry()
+	router := NewRouter(quarantineConfig(), registry, registry,
+		WithQuarantine(store, nil),
+		WithOrderEventHandler(OrderEventHandlerFunc(func(context.Context, OrderEvent) error {
+			return fmt.Errorf("unknown customer: %w", ErrDropEvent)
+		})),
+	)
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	response := postBulk(t, router,
+		`{"entryId":"1","contentType":"application/cloudevents+json","metadata":{"traceparent":"`+traceparent+`"},"event":{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":{"id":"ORD-1","amount":1}}}`,
+	)
+	if len(response.Statuses) != 1 || response.Statuses[0].Status != SubscriptionDrop {
+		t.Fatalf("statuses = %+v, want one DROP", response.Statuses)
+	}
+
+	page, err := store.List(context.Background(), 10, 0)
+	if err != nil || page.Total != 1 {
+		t.Fatalf("quarantine = %+v, %v; want one message", page, err)
+	}
+	headers := page.Messages[0].Headers
+	if headers.Get("Content-Type") != "application/cloudevents+json" || headers.Get("Traceparent") != traceparent {
+		t.Fatalf("quarantined headers = %v, want the entry content type and metadata", headers)
+	}
+}
+
+func TestQuarantineAdminRedriveAndPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestQuarantineStore(t)
+	republisher := &fakeRepublisher{}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(quarantineConfig(), registry, registry, WithQuarantine(store, republisher))
+
+	cloudEvent := `{"specversion":"1.0","type":"com.agnostic.order.created.v1","id":"evt-1","source":"producer-gin","data":{"id":"ORD-1","amount":1}}`
+	binaryHeaders := http.Header{
+		"Content-Type":   {"application/json"},
+		"Ce-Specversion": {"1.0"},
+		"Ce-Id":          {"evt-3"},
+		"Ce-Source":      {"producer-gin"},
+		"Ce-Type":        {"com.agnostic.order.created.v1"},
+		"Ce-Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+	for _, message := range []QuarantinedMessage{
+		{ID: "cloud-event", PubSubName: "order-pubsub", Topic: "orders", Payload: cloudEvent},
+		{ID: "binary", PubSubName: "order-pubsub", Topic: "orders", Headers: binaryHeaders, Payload: `{"id":"ORD-3","amount":1}`},
+		{ID: "raw", PubSubName: "order-pubsub", Topic: "orders", Payload: `{"id":"ORD-2"}`},
+		{ID: "stuck", PubSubName: "order-pubsub", Topic: "orders", Payload: `{}`},
+	} {
+		if err := store.Quarantine(ctx, message); err != nil {
+			t.Fatalf("quarantine %s: %v", message.ID, err)
+		}
+	}
+
+	admin := asAdmin(router)
+	send := func(method, target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		admin.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+		return res
+	}
+
+	if res := send(http.MethodPost, "/admin/quarantine/cloud-event/redrive"); res.Code != http.StatusOK {
+		t.Fatalf("redrive cloud-event = %d %s", res.Code, res.Body.String())
+	}
+	if res := send(http.MethodPost, "/admin/quarantine/raw/redrive"); res.Code != http.StatusOK {
+		t.Fatalf("redrive raw = %d %s", res.Code, res.Body.String())
+	}
+	if res := send(http.MethodPost, "/admin/quarantine/binary/redrive"); res.Code != http.StatusOK {
+		t.Fatalf("redrive binary = %d %s", res.Code, res.Body.String())
+	}
+	binaryEvent := `{"data":{"id":"ORD-3","amount":1},"datacontenttype":"application/json","id":"evt-3","source":"producer-gin","specversion":"1.0","traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01","type":"com.agnostic.order.created.v1"}`
+	want := []republishCall{
+		{"order-pubsub", "orders", cloudEvent, "application/cloudevents+json"},
+		{"order-pubsub", "orders", `{"id":"ORD-2"}`, "application/json"},
+		{"order-pubsub", "orders", binaryEvent, "application/cloudevents+json"},
+	}
+	if !slices.Equal(republisher.calls, want) {
+		t.Fatalf("republished %+v, want %+v", republisher.calls, want)
+	}
+
+	// The re-driven binary event decodes with its original attributes.
+	event, metadata, err := CloudEventDecoder{}.Decode(nil, []byte(republisher.calls[2].payload))
+	if err != nil || event.OrderID() != "ORD-3" || metadata.ID != "evt-3" || metadata.Extensions["traceparent"] == "" {
+		t.Fatalf("decode re-driven binary event = %+v, %+v, %v", event, metadata, err)
+	}
+	if _, err := store.Get(ctx, "cloud-event"); !errors.Is(err, ErrQuarantinedMessageNotFound) {
+		t.Fatalf("re-driven message still quarantined: %v", err)
+	}
+
+	republisher.err = errors.New("sidecar unavailable")
+	if res := send(http.MethodPost, "/admin/quarantine/stuck/redrive"); res.Code != http.StatusBadGateway {
+		t.Fatalf("failed redrive = %d, want 502", res.Code)
+	}
+	if _, err := store.Get(ctx, "stuck"); err != nil {
+		t.Fatalf("failed re-drive removed message: %v", err)
+	}
+	if res := send(http.MethodPost, "/admin/quarantine/unknown/redrive"); res.Code != http.StatusNotFound {
+		t.Fatalf("redrive unknown = %d, want 404", res.Code)
+	}
+
+	if res := send(http.MethodDelete, "/admin/quarantine/stuck"); res.Code != http.StatusNoContent {
+		t.Fatalf("delete stuck = %d, want 204", res.Code)
+	}
+	if res := send(http.MethodDelete, "/admin/quarantine/stuck"); res.Code != http.StatusNotFound {
+		t.Fatalf("delete stuck again = %d, want 404", res.Code)
+	}
+	for _, id := range []string{"later", "latest"} {
+		if err := store.Quarantine(ctx, QuarantinedMessage{ID: id, Payload: "{}"}); err != nil {
+			t.Fatalf("quarantine %s: %v", id, err)
+		}
+	}
+	if res := send(http.MethodDelete, "/admin/quarantine"); res.Code != http.StatusOK || res.Body.String() != `{"purged":2}` {
+		t.Fatalf("purge = %d %s, want 200 {\"purged\":2}", res.Code, res.Body.String())
+	}
+}
+
+func TestQuarantineAdminRequiresToken(t *testing.T) {
+	t.Parallel()
+
+	store := openTestQuarantineStore(t)
+	if err := store.Quarantine(context.Background(), QuarantinedMessage{ID: "stuck", Payload: "{}"}); err != nil {
+		t.Fatalf("quarantine: %v", err)
+	}
+
+	tests := []struct {
+		name, configured, authorization string
+		want                            int
+	}{
+		{name: "no token configured", authorization: "Bearer ", want: http.StatusForbidden},
+		{name: "missing header", configured: testAdminToken, want: http.StatusUnauthorized},
+		{name: "wrong token", configured: testAdminToken, authorization: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not a bearer token", configured: testAdminToken, authorization: testAdminToken, want: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		cfg := quarantineConfig()
+		cfg.Quarantine.AdminToken = tc.configured
+		registry := prometheus.NewRegistry()
+		router := NewRouter(cfg, registry, registry, WithQuarantine(store, &fakeRepublisher{}))
+		for _, request := range []struct{ method, target string }{
+			{http.MethodGet, "/admin/quarantine"},
+			{http.MethodGet, "/admin/quarantine/stuck"},
+			{http.MethodPost, "/admin/quarantine/stuck/redrive"},
+			{http.MethodDelete, "/admin/quarantine/stuck"},
+			{http.MethodDelete, "/admin/quarantine"},
+		} {
+			req := httptest.NewRequest(request.method, request.target, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+			if res.Code != tc.want {
+				t.Fatalf("%s: %s %s = %d, want %d", tc.name, request.method, request.target, res.Code, tc.want)
+			}
+		}
+	}
+	if _, err := store.Get(context.Background(), "stuck"); err != nil {
+		t.Fatalf("unauthorized requests changed the quarantine: %v", err)
+	}
+}
+
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestDaprRepublisher(t *testing.T) {
+	t.Parallel()
+
+	var got *http.Request
+	var body string
+	status := http.StatusNoContent
+	republisher := NewDaprRepublisher(doerFunc(func(req *http.Request) (*http.Response, error) {
+		got = req
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}), "http://localhost:3500/")
+
+	if err := republisher.Republish(context.Background(), "order-pubsub", "orders", []byte(`{"id":"ORD-1"}`), "application/json"); err != nil {
+		t.Fatalf("republish: %v", err)
+	}
+	if got.URL.String() != "http://localhost:3500/v1.0/publish/order-pubsub/orders" || got.Header.Get("Content-Type") != "application/json" || body != `{"id":"ORD-1"}` {
+		t.Fatalf("republish request = %s %s %q", got.URL, got.Header.Get("Content-Type"), body)
+	}
+
+	status = http.StatusInternalServerError
+	if err := republisher.Republish(context.Background(), "order-pubsub", "orders", []byte(`{}`), "application/json"); err == nil {
+		t.Fatal("republish error = nil, want status error")
+	}
+}
@@ -0,0 +1,206 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FallbackPolicy decides what happens to events the EventRegistry cannot
+// dispatch: an eventVersion without a registered model, or a model without
+// a handler even after upcasting.
+type FallbackPolicy string
+
+const (
+	// FallbackDrop drops the event without quarantining it.
+	FallbackDrop FallbackPolicy = "drop"
+	// FallbackRetry asks Dapr to redeliver the event, for example while a
+	// rollout adds the missing handler.
+	FallbackRetry FallbackPolicy = "retry"
+	// FallbackQuarantine drops the event into the quarantine, when one is
+	// configured.
+	FallbackQuarantine FallbackPolicy = "quarantine"
+)
+
+func ParseFallbackPolicy(value string) (FallbackPolicy, error) {
+	switch policy := FallbackPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case FallbackDrop, FallbackRetry, FallbackQuarantine:
+		return policy, nil
+	case "":
+		return FallbackQuarantine, nil
+	default:
+		return "", fmt.Errorf("unsupported event fallback policy %q", value)
+	}
+}
+
+// ErrDiscardEvent drops an event like ErrDropEvent but keeps it out of the
+// quarantine.
+var ErrDiscardEvent = fmt.Errorf("%w, discarded", ErrDropEvent)
+
+var errNoEventHandler = errors.New("no handler for event")
+
+// EventRegistry maps CloudEvent types and eventVersions to Go models,
+// upcasts older models to newer ones and dispatches each event to the
+// handler of the first model in its upcast chain that has one. Register
+// everything before the registry is used; registration is not safe for
+// concurrent use.
+type EventRegistry struct {
+	defaultVersion string
+	types          map[string]string
+	decoders       map[string]func([]byte) (OrderEvent, error)
+	upcasters      map[reflect.Type]func(OrderEvent) (OrderEvent, error)
+	handlers       map[reflect.Type]func(context.Context, OrderEvent) error
+	fallback       FallbackPolicy
+}
+
+func NewEventRegistry(fallback FallbackPolicy) *EventRegistry {
+	if fallback == "" {
+		fallback = FallbackQuarantine
+	}
+	return &EventRegistry{
+		types:     map[string]string{},
+		decoders:  map[string]func([]byte) (OrderEvent, error){},
+		upcasters: map[reflect.Type]func(OrderEvent) (OrderEvent, error){},
+		handlers:  map[reflect.Type]func(context.Context, OrderEvent) error{},
+		fallback:  fallback,
+	}
+}
+
+// DefaultEventRegistry knows OrderCreatedV1 and OrderCreatedV2, decodes
+// events without eventVersion as v1 and upcasts v1 to v2.
+func DefaultEventRegistry(fallback FallbackPolicy) *EventRegistry {
+	registry := NewEventRegistry(fallback)
+	RegisterEvent[OrderCreatedV1](registry, OrderCreatedV1Type, "v1")
+	RegisterEvent[OrderCreatedV2](registry, OrderCreatedV2Type, "v2")
+	registry.SetDefaultVersion("v1")
+	RegisterUpcaster(registry, UpcastOrderCreatedV1)
+	return registry
+}
+
+// defaultEventRegistry decodes for ParseOrderEvent and decoders without a
+// registry.
+var defaultEventRegistry = DefaultEventRegistry(FallbackQuarantine)
+
+// RegisterEvent decodes data of CloudEvent type eventType, and data whose
+// eventVersion is version, into T.
+func RegisterEvent[T OrderEvent](r *EventRegistry, eventType, version string) {
+	if eventType != "" {
+		r.types[eventType] = version
+	}
+	r.decoders[version] = func(data []byte) (OrderEvent, error) {
+		var event T
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		if stamper, ok := any(&event).(versionStamper); ok {
+			stamper.stampVersion(version)
+		}
+		return event, nil
+	}
+}
+
+// SetDefaultVersion sets the eventVersion assumed for data without one.
+func (r *EventRegistry) SetDefaultVersion(version string) {
+	r.defaultVersion = version
+}
+
+// RegisterUpcaster converts From events to To before dispatch when From has
+// no handler of its own.
+func RegisterUpcaster[From, To OrderEvent](r *EventRegistry, upcast func(From) (To, error)) {
+	r.upcasters[reflect.TypeFor[From]()] = func(event OrderEvent) (OrderEvent, error) {
+		return upcast(event.(From))
+	}
+}
+
+// HandleEvent dispatches T events, and events upcast to T, to handler.
+func HandleEvent[T OrderEvent](r *EventRegistry, handler func(context.Context, T) error) {
+	r.handlers[reflect.TypeFor[T]()] = func(ctx context.Context, event OrderEvent) error {
+		return handler(ctx, event.(T))
+	}
+}
+
+// versionStamper is implemented by models that record their eventVersion.
+type versionStamper interface {
+	stampVersion(version string)
+}
+
+// eventVersion returns the eventVersion of a CloudEvent type, if registered.
+func (r *EventRegistry) eventVersion(eventType string) (string, bool) {
+	version, ok := r.types[eventType]
+	return version, ok
+}
+
+// decode decodes data as version, or by its eventVersion field when version
+// is empty. A data eventVersion that contradicts version is an error.
+func (r *EventRegistry) decode(data []byte, version string) (OrderEvent, error) {
+	var header struct {
+		EventVersion string `json:"eventVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if version != "" && header.EventVersion != "" && header.EventVersion != version {
+		return nil, fmt.Errorf("%w: %s event carries eventVersion %q", errEventTypeVersionClash, version, header.EventVersion)
+	}
+	if version == "" {
+		version = header.EventVersion
+	}
+	if version == "" {
+		version = r.defaultVersion
+	}
+
+	decode, ok := r.decoders[version]
+	if !ok {
+		return nil, r.unhandled(fmt.Errorf("%w %q", errUnsupportedEventVersion, version))
+	}
+	event, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(event.OrderID()) == "" {
+		return nil, errBlankEventID
+	}
+	return event, nil
+}
+
+// Handle upcasts event until a model with a handler is reached and calls
+// that handler. Events no handler accepts follow the fallback policy.
+func (r *EventRegistry) Handle(ctx context.Context, event OrderEvent) error {
+	for range len(r.upcasters) + 1 {
+		eventType := reflect.TypeOf(event)
+		if handler, ok := r.handlers[eventType]; ok {
+			return handler(ctx, event)
+		}
+		upcast, ok := r.upcasters[eventType]
+		if !ok {
+			break
+		}
+		upcasted, err := upcast(event)
+		if err != nil {
+			return fmt.Errorf("upcast %s event %s: %w: %w", event.Version(), event.OrderID(), err, ErrDropEvent)
+		}
+		event = upcasted
+	}
+	return r.unhandled(fmt.Errorf("%w %T", errNoEventHandler, event))
+}
+
+// unhandled applies the fallback policy to err.
+func (r *EventRegistry) unhandled(err error) error {
+	switch r.fallback {
+	case FallbackRetry:
+		return err
+	case FallbackDrop:
+		return fmt.Errorf("%w: %w", err, ErrDiscardEvent)
+	default:
+		return fmt.Errorf("%w: %w", err, ErrDropEvent)
+	}
+}
+
+// UpcastOrderCreatedV1 converts a v1 order to the v2 model. V1 carries no
+// currency, customer or line items, so those stay empty.
+func UpcastOrderCreatedV1(event OrderCreatedV1) (OrderCreatedV2, error) {
+	return OrderCreatedV2{ID: event.ID, Amount: event.Amount, EventVersion: "v2"}, nil
+}
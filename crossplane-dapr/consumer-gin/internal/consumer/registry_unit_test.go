@@ -0,0 +1,222 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// orderCreatedV3 is a model newer than any the service ships with.
+type orderCreatedV3 struct {
+	ID       string      `json:"id"`
+	Amount   money.Money `json:"amount"`
+	Currency string      `json:"currency"`
+	Channel  string      `json:"channel"`
+}
+
+func (e orderCreatedV3) OrderID() string { return e.ID }
+func (e orderCreatedV3) Version() string { return "v3" }
+
+func TestEventRegistryUpcastsToTheHandledModel(t *testing.T) {
+	t.Parallel()
+
+	registry := DefaultEventRegistry(FallbackQuarantine)
+	RegisterEvent[orderCreatedV3](registry, "com.agnostic.order.created.v3", "v3")
+	RegisterUpcaster(registry, func(event OrderCreatedV2) (orderCreatedV3, error) {
+		currency := event.Currency
+		if currency == "" {
+			currency = "EUR"
+		}
+		return orderCreatedV3{ID: event.ID, Amount: event.Amount, Currency: currency, Channel: "unknown"}, nil
+	})
+	var handled []orderCreatedV3
+	HandleEvent(registry, func(_ context.Context, event orderCreatedV3) error {
+		handled = append(handled, event)
+		return nil
+	})
+
+	decoder := CloudEventDecoder{Registry: registry}
+	for _, payload := range []string{
+		`{"id":"ORD-1","amount":10}`,
+		`{"specversion":"1.0","id":"evt-2","source":"producer-gin","type":"com.agnostic.order.created.v2","data":{"id":"ORD-2","amount":20,"currency":"USD"}}`,
+		`{"id":"ORD-3","amount":30,"currency":"GBP","channel":"web","eventVersion":"v3"}`,
+	} {
+		event, _, err := decoder.Decode(nil, []byte(payload))
+		if err != nil {
+			t.Fatalf("decode %s: %v", payload, err)
+		}
+		if err := registry.Handle(context.Background(), event); err != nil {
+			t.Fatalf("handle %s: %v", payload, err)
+		}
+	}
+
+	want := []orderCreatedV3{
+		{ID: "ORD-1", Amount: money.MustParse("10"), Currency: "EUR", Channel: "unknown"},
+		{ID: "ORD-2", Amount: money.MustParse("20"), Currency: "USD", Channel: "unknown"},
+		{ID: "ORD-3", Amount: money.MustParse("30"), Currency: "GBP", Channel: "web"},
+	}
+	if len(handled) != len(want) {
+		t.Fatalf("handled = %+v, want %+v", handled, want)
+	}
+	for i := range want {
+		got := handled[i]
+		if got.ID != want[i].ID || !got.Amount.Equal(want[i].Amount) || got.Currency != want[i].Currency || got.Channel != want[i].Channel {
+			t.Fatalf("handled[%d] = %+v, want %+v", i, handled[i], want[i])
+		}
+	}
+}
+
+func TestEventRegistryPrefersTheClosestHandler(t *testing.T) {
+	t.Parallel()
+
+	registry := DefaultEventRegistry(FallbackQuarantine)
+	var got []string
+	HandleEvent(registry, func(_ context.Context, event OrderCreatedV1) error {
+		got = append(got, "v1:"+event.ID)
+		return nil
+	})
+	HandleEvent(registry, func(_ context.Context, event OrderCreatedV2) error {
+		got = append(got, "v2:"+event.ID+":"+event.EventVersion)
+		return nil
+	})
+
+	for _, event := range []OrderEvent{OrderCreatedV1{ID: "ORD-1"}, OrderCreatedV2{ID: "ORD-2", EventVersion: "v2"}} {
+		if err := registry.Handle(context.Background(), event); err != nil {
+			t.Fatalf("handle %s: %v", event.OrderID(), err)
+		}
+	}
+	if len(got) != 2 || got[0] != "v1:ORD-1" || got[1] != "v2:ORD-2:v2" {
+		t.Fatalf("handled = %v", got)
+	}
+}
+
+func TestEventRegistryFallbackPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy        FallbackPolicy
+		want          SubscriptionStatus
+		wantDiscarded bool
+	}{
+		{policy: FallbackDrop, want: SubscriptionDrop, wantDiscarded: true},
+		{policy: FallbackRetry, want: SubscriptionRetry},
+		{policy: FallbackQuarantine, want: SubscriptionDrop},
+	}
+	for _, tc := range tests {
+		registry := DefaultEventRegistry(tc.policy)
+
+		_, err := registry.decode([]byte(`{"id":"ORD-1","eventVersion":"v9"}`), "")
+		if !errors.Is(err, errUnsupportedEventVersion) || handlerOutcome(err) != tc.want || errors.Is(err, ErrDiscardEvent) != tc.wantDiscarded {
+			t.Fatalf("%s: decode unknown version error = %v, want %s", tc.policy, err, tc.want)
+		}
+
+		err = registry.Handle(context.Background(), OrderCreatedV1{ID: "ORD-1"})
+		if !errors.Is(err, errNoEventHandler) || handlerOutcome(err) != tc.want || errors.Is(err, ErrDiscardEvent) != tc.wantDiscarded {
+			t.Fatalf("%s: unhandled event error = %v, want %s", tc.policy, err, tc.want)
+		}
+	}
+
+	registry := DefaultEventRegistry(FallbackRetry)
+	RegisterUpcaster(registry, func(OrderCreatedV2) (orderCreatedV3, error) { return orderCreatedV3{}, errors.New("no currency") })
+	HandleEvent(registry, func(context.Context, orderCreatedV3) error { return nil })
+	if err := registry.Handle(context.Background(), OrderCreatedV2{ID: "ORD-2"}); handlerOutcome(err) != SubscriptionDrop {
+		t.Fatalf("failed upcast error = %v, want DROP", err)
+	}
+}
+
+func TestParseFallbackPolicy(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]FallbackPolicy{"": FallbackQuarantine, "drop": FallbackDrop, "RETRY": FallbackRetry, " quarantine ": FallbackQuarantine} {
+		if got, err := ParseFallbackPolicy(value); err != nil || got != want {
+			t.Fatalf("ParseFallbackPolicy(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseFallbackPolicy("ignore"); err == nil {
+		t.Fatal("ParseFallbackPolicy(ignore) error = nil")
+	}
+}
+
+func TestConsumeDispatchesThroughEventRegistry(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		policy          FallbackPolicy
+		want            SubscriptionStatus
+		wantQuarantined int
+	}{
+		{policy: FallbackDrop, want: SubscriptionDrop},
+		{policy: FallbackRetry, want: SubscriptionRetry},
+		{policy: FallbackQuarantine, want: SubscriptionDrop, wantQuarantined: 1},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			t.Parallel()
+
+			store := openTestQuarantineStore(t)
+			registry := DefaultEventRegistry(tc.policy)
+			var handled []OrderCreatedV2
+			HandleEvent(registry, func(_ context.Context, event OrderCreatedV2) error {
+				handled = append(handled, event)
+				return nil
+			})
+			cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+			promRegistry := prometheus.NewRegistry()
+			router := NewRouter(cfg, promRegistry, promRegistry, WithQuarantine(store, nil), WithEventRegistry(registry))
+
+			if got := deliver(t, router, "/orders", `{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`, http.Header{}); got != SubscriptionSuccess {
+				t.Fatalf("v1 delivery = %s, want SUCCESS", got)
+			}
+			if len(handled) != 1 || handled[0].ID != "ORD-1" || handled[0].EventVersion != "v2" {
+				t.Fatalf("handled = %+v, want ORD-1 upcast to v2", handled)
+			}
+			if got := deliver(t, router, "/orders", `{"data":{"id":"ORD-2","eventVersion":"v9"}}`, http.Header{}); got != tc.want {
+				t.Fatalf("unknown version delivery = %s, want %s", got, tc.want)
+			}
+			page, err := store.List(context.Background(), 10, 0)
+			if err != nil {
+				t.Fatalf("list quarantine: %v", err)
+			}
+			if page.Total != tc.wantQuarantined {
+				t.Fatalf("quarantined %d messages, want %d", page.Total, tc.wantQuarantined)
+			}
+		})
+	}
+}
+
+func TestEventRegistryTakesPrecedenceOverTheOrderStore(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOrderStore(t)
+	registry := DefaultEventRegistry(FallbackQuarantine)
+	var handled []string
+	HandleEvent(registry, func(_ context.Context, event OrderCreatedV2) error {
+		handled = append(handled, event.ID)
+		return nil
+	})
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+
+	for i, opts := range [][]Option{
+		{WithOrderStore(store), WithEventRegistry(registry)},
+		{WithEventRegistry(registry), WithOrderStore(store)},
+	} {
+		promRegistry := prometheus.NewRegistry()
+		router := NewRouter(cfg, promRegistry, promRegistry, opts...)
+		id := fmt.Sprintf("ORD-%d", i+1)
+		if got := deliver(t, router, "/orders", `{"data":{"id":"`+id+`","amount":10}}`, http.Header{}); got != SubscriptionSuccess {
+			t.Fatalf("delivery %d = %s, want SUCCESS", i+1, got)
+		}
+		if _, err := store.Get(context.Background(), id); !errors.Is(err, ErrOrderNotFound) {
+			t.Fatalf("options %d stored %s outside the registry handlers: %v", i+1, id, err)
+		}
+	}
+	if len(handled) != 2 {
+		t.Fatalf("registry handled %v, want both deliveries", handled)
+	}
+}
@@ -5,35 +5,38 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"github.com/agnostic/crossplane-dapr/common-gin/telemetry"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func NewRouter(cfg Config, registerer prometheus.Registerer, gatherer prometheus.Gatherer) *gin.Engine {
+func NewRouter(cfg Config, registerer prometheus.Registerer, gatherer prometheus.Gatherer, opts ...Option) *gin.Engine {
 	if registerer == nil {
 		registerer = prometheus.DefaultRegisterer
 	}
 	if gatherer == nil {
 		gatherer = prometheus.DefaultGatherer
 	}
+	resolved := newOptions(opts)
+	tracer := resolved.tracerProvider.Tracer(instrumentationName)
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(otelgin.Middleware(serviceName,
+		otelgin.WithTracerProvider(resolved.tracerProvider),
+		otelgin.WithPropagators(resolved.propagator),
+		otelgin.WithFilter(telemetry.IsTracedRequest),
+	))
+	router.Use(accessLogMiddleware(accesslog.New(cfg.AccessLog)), gin.Recovery())
 
-	consumedRequests := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "orders_consume_requests_total",
-		Help: "Total consume requests received by consumer-gin.",
-	})
-	consumeErrors := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "orders_consume_errors_total",
-		Help: "Total consume errors in consumer-gin.",
-	})
-	consumedEvents := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "orders_consumed_total",
-		Help: "Total consumed order events in consumer-gin.",
-	})
 	httpRequestDuration := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "http_server_requests_seconds",
@@ -42,7 +45,8 @@ func NewRouter(cfg Config, registerer prometheus.Registerer, gatherer prometheus
 		},
 		[]string{"method", "uri", "status"},
 	)
-	registerer.MustRegister(consumedRequests, consumeErrors, consumedEvents, httpRequestDuration)
+	registerer.MustRegister(httpRequestDuration)
+	metrics := newConsumeMetrics(registerer)
 
 	router.Use(func(c *gin.Context) {
 		start := time.Now()
@@ -58,66 +62,92 @@ func NewRouter(cfg Config, registerer prometheus.Registerer, gatherer prometheus
 		).Observe(time.Since(start).Seconds())
 	})
 	router.Use(func(c *gin.Context) {
-		traceID, spanID := requestTraceContext(c.Request)
-		requestLogger := logger.With(
-			"trace_id", traceID,
-			"span_id", spanID,
-			"http_method", c.Request.Method,
-			"http_path", c.Request.URL.Path,
-		)
+		requestLogger := newRequestLogger(c.Request)
 		c.Set(requestLoggerKey, requestLogger)
 		c.Request = c.Request.WithContext(withRequestLogger(c.Request.Context(), requestLogger))
 		c.Next()
 	})
 
-	router.GET("/health/live", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "UP"})
-	})
-	router.GET("/health/ready", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "UP"})
-	})
+	checks := resolved.health
+	if checks == nil {
+		checks = health.New()
+	}
+	router.GET("/health", gin.WrapH(checks.DetailHandler()))
+	router.GET("/health/live", gin.WrapH(checks.LiveHandler()))
+	router.GET("/health/ready", gin.WrapH(checks.ReadyHandler()))
+	router.GET("/health/started", gin.WrapH(checks.StartupHandler()))
 	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
 
+	subscriptions := resolved.subscriptions
+	if len(subscriptions) == 0 {
+		// Like an invalid route in gin itself, an invalid default
+		// subscription is a programming error here; main validates it first.
+		var err error
+		if subscriptions, err = DefaultSubscriptions(cfg); err != nil {
+			panic(err)
+		}
+	}
 	router.GET("/dapr/subscribe", func(c *gin.Context) {
 		requestLogger := loggerFromGinContext(c)
-		subscriptions := []DaprSubscription{{
-			PubSubName: cfg.PubSubName,
-			Topic:      cfg.TopicName,
-			Route:      cfg.SubscriptionRoute,
-		}}
-		requestLogger.Debug("returning dapr subscriptions",
-			"route", cfg.SubscriptionRoute,
-			"pubsub", cfg.PubSubName,
-			"topic", cfg.TopicName,
-		)
+		requestLogger.DebugContext(c.Request.Context(), "returning dapr subscriptions", "subscriptions", len(subscriptions))
 		c.JSON(http.StatusOK, subscriptions)
 	})
 
-	router.POST(cfg.SubscriptionRoute, func(c *gin.Context) {
-		requestLogger := loggerFromGinContext(c)
-		consumedRequests.Inc()
-		requestLogger.Debug("received consume request", "route", cfg.SubscriptionRoute)
+	if resolved.orders != nil {
+		orders := orderQueryHandlers{reader: resolved.orders}
+		router.GET("/orders", orders.list)
+		router.GET("/orders/:id", orders.get)
+	}
 
-		payload, err := c.GetRawData()
-		if err != nil {
-			consumeErrors.Inc()
-			requestLogger.Warn("failed to read event payload", "error", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read event payload"})
-			return
-		}
+	if resolved.quarantine != nil {
+		quarantine := quarantineAdminHandlers{store: resolved.quarantine, republisher: resolved.republisher}
+		admin := router.Group("/admin", requireAdminToken(cfg.Quarantine.AdminToken))
+		admin.GET("/quarantine", quarantine.list)
+		admin.DELETE("/quarantine", quarantine.purge)
+		admin.GET("/quarantine/:id", quarantine.get)
+		admin.DELETE("/quarantine/:id", quarantine.delete)
+		admin.POST("/quarantine/:id/redrive", quarantine.redrive)
+	}
 
-		event, err := ParseOrderEvent(c.Request.Context(), payload)
-		if err != nil {
-			consumeErrors.Inc()
-			requestLogger.Warn("failed to parse event payload", "route", cfg.SubscriptionRoute, "payloadSize", len(payload), "error", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event payload"})
-			return
-		}
+	pool := resolved.pool
+	if pool == nil {
+		pool = NewWorkerPool(cfg.Workers)
+	}
+	registerWorkerPoolMetrics(registerer, pool)
 
-		consumedEvents.Inc()
-		requestLogger.Info("consumed order event", "route", cfg.SubscriptionRoute, "id", event.ID, "version", event.EventVersion)
-		c.Status(http.StatusOK)
-	})
+	registry := resolved.registry
+	if registry == nil {
+		registry = DefaultEventRegistry(cfg.CloudEvents.Fallback)
+	}
+	eventHandler := resolved.handler()
+	for _, route := range subscriptionRoutes(subscriptions) {
+		routeHandler, hasRouteHandler := resolved.routeHandlers[route.path]
+		// Without a quarantine, dead-lettered messages are dropped unless a
+		// handler was registered for the route itself.
+		if route.deadLetter && (resolved.quarantine != nil || !hasRouteHandler) {
+			router.POST(route.path, deadLetterRoute{
+				route:           route,
+				store:           resolved.quarantine,
+				metrics:         metrics,
+				bulkConcurrency: cfg.Bulk.concurrency(),
+			}.handle)
+			continue
+		}
+		handler := eventHandler
+		if hasRouteHandler {
+			handler = routeHandler
+		}
+		router.POST(route.path, consumeRoute{
+			route:           route,
+			decoder:         CloudEventDecoder{UnknownTypes: cfg.CloudEvents.UnknownTypes, Registry: registry},
+			handler:         handler,
+			resolved:        resolved,
+			tracer:          tracer,
+			metrics:         metrics,
+			pool:            pool,
+			bulkConcurrency: cfg.Bulk.concurrency(),
+		}.handle)
+	}
 
 	return router
 }
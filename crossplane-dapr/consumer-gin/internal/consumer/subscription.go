@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionStatus is the outcome reported to the Dapr sidecar in the body
+// of a subscription response.
+type SubscriptionStatus string
+
+const (
+	// SubscriptionSuccess acknowledges the message.
+	SubscriptionSuccess SubscriptionStatus = "SUCCESS"
+	// SubscriptionRetry asks the sidecar to redeliver the message.
+	SubscriptionRetry SubscriptionStatus = "RETRY"
+	// SubscriptionDrop discards the message, or dead-letters it when the
+	// subscription has a dead letter topic.
+	SubscriptionDrop SubscriptionStatus = "DROP"
+)
+
+// ErrDropEvent marks an event handler error as permanent: the message is
+// dropped instead of redelivered. Wrap it with fmt.Errorf("...: %w").
+var ErrDropEvent = errors.New("event cannot be processed")
+
+// handlerOutcome classifies an event handler error. Handler errors are
+// transient unless they wrap ErrDropEvent.
+func handlerOutcome(err error) SubscriptionStatus {
+	switch {
+	case err == nil:
+		return SubscriptionSuccess
+	case errors.Is(err, ErrDropEvent):
+		return SubscriptionDrop
+	default:
+		return SubscriptionRetry
+	}
+}
+
+// respondSubscription answers the sidecar with 200 and the status body; Dapr
+// reads RETRY and DROP only from 2xx responses.
+func respondSubscription(c *gin.Context, status SubscriptionStatus) {
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
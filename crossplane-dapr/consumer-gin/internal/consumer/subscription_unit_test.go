@@ -0,0 +1,185 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// outcomeCount returns orders_consume_outcomes_total for status.
+func outcomeCount(t *testing.T, gatherer prometheus.Gatherer, status SubscriptionStatus) float64 {
+	t.Helper()
+	return counterValue(t, gatherer, "orders_consume_outcomes_total", "status", string(status))
+}
+
+// counterValue returns the counter name with label set to value, or the
+// unlabelled counter name when label is empty.
+func counterValue(t *testing.T, gatherer prometheus.Gatherer, name, label, value string) float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if label == "" {
+				return metric.GetCounter().GetValue()
+			}
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == label && pair.GetValue() == value {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestSubscriptionResponseStatus(t *testing.T) {
+	t.Parallel()
+
+	validEvent := `{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`
+	tests := []struct {
+		name    string
+		body    io.Reader
+		handler OrderEventHandler
+		want    SubscriptionStatus
+	}{
+		{name: "valid event", body: bytes.NewBufferString(validEvent), want: SubscriptionSuccess},
+		{name: "handler succeeds", body: bytes.NewBufferString(validEvent), handler: OrderEventHandlerFunc(func(context.Context, OrderEvent) error { return nil }), want: SubscriptionSuccess},
+		{name: "malformed json", body: bytes.NewBufferString(`{"data":`), want: SubscriptionDrop},
+		{name: "blank id", body: bytes.NewBufferString(`{"data":{"amount":10}}`), want: SubscriptionDrop},
+		{name: "unsupported version", body: bytes.NewBufferString(`{"data":{"id":"ORD-1","eventVersion":"v9"}}`), want: SubscriptionDrop},
+		{name: "unreadable body", body: iotest.ErrReader(errors.New("connection reset")), want: SubscriptionRetry},
+		{
+			name:    "transient handler error",
+			body:    bytes.NewBufferString(validEvent),
+			handler: OrderEventHandlerFunc(func(context.Context, OrderEvent) error { return errors.New("database is locked") }),
+			want:    SubscriptionRetry,
+		},
+		{
+			name: "permanent handler error",
+			body: bytes.NewBufferString(validEvent),
+			handler: OrderEventHandlerFunc(func(context.Context, OrderEvent) error {
+				return fmt.Errorf("order references unknown customer: %w", ErrDropEvent)
+			}),
+			want: SubscriptionDrop,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+			registry := prometheus.NewRegistry()
+			router := NewRouter(cfg, registry, registry, WithOrderEventHandler(tc.handler))
+
+			req := httptest.NewRequest(http.MethodPost, "/orders", tc.body)
+			req.Header.Set("Content-Type", "application/cloudevents+json")
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+
+			var response struct {
+				Status SubscriptionStatus `json:"status"`
+			}
+			if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode subscription response %q: %v", res.Body.String(), err)
+			}
+			if res.Code != http.StatusOK || response.Status != tc.want {
+				t.Fatalf("got %d %s, want 200 %s", res.Code, response.Status, tc.want)
+			}
+			for _, status := range []SubscriptionStatus{SubscriptionSuccess, SubscriptionRetry, SubscriptionDrop} {
+				want := 0.0
+				if status == tc.want {
+					want = 1
+				}
+				if got := outcomeCount(t, registry, status); got != want {
+					t.Fatalf("%s outcomes = %v, want %v", status, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeRecordsEndToEndLatencyBySourceStack(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}, registry, registry)
+	publishedAt := time.Now().Add(-2 * time.Second).UTC()
+	events := []string{
+		// producer-gin stamps publishtime; the CloudEvent time is older.
+		`{"specversion":"1.0","id":"evt-1","source":"producer-gin","type":"com.agnostic.order.created.v1","time":"2026-01-01T00:00:00Z","publishtime":"` + publishedAt.Format(time.RFC3339Nano) + `","data":{"id":"ORD-1","amount":10}}`,
+		`{"specversion":"1.0","id":"evt-2","source":"producer-ktor","type":"com.agnostic.order.created.v1","time":"` + publishedAt.Format(time.RFC3339Nano) + `","data":{"id":"ORD-2","amount":10}}`,
+		`{"specversion":"1.0","id":"evt-3","source":"checkout","type":"com.agnostic.order.created.v1","data":{"id":"ORD-3","amount":10}}`,
+	}
+	before := time.Now()
+	for _, event := range events {
+		if got := deliver(t, router, "/orders", event, http.Header{}); got != SubscriptionSuccess {
+			t.Fatalf("delivery = %s, want SUCCESS", got)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	latency := map[string][2]float64{}
+	lastSeen := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "orders_end_to_end_latency_seconds":
+				latency[metric.GetLabel()[0].GetValue()] = [2]float64{float64(metric.GetHistogram().GetSampleCount()), metric.GetHistogram().GetSampleSum()}
+			case "orders_consume_last_event_timestamp_seconds":
+				lastSeen[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	for _, stack := range []string{"gin", "ktor"} {
+		if got := latency[stack]; got[0] != 1 || got[1] < 2 || got[1] > 60 {
+			t.Fatalf("%s latency (count, sum) = %v, want one sample of about 2s", stack, got)
+		}
+	}
+	if _, ok := latency["unknown"]; ok {
+		t.Fatal("recorded latency for an event without a publish time")
+	}
+	for _, stack := range []string{"gin", "ktor", "unknown"} {
+		if got := lastSeen[stack]; got < float64(before.Unix()) {
+			t.Fatalf("%s last event seen = %v, want at least %d", stack, got, before.Unix())
+		}
+	}
+}
+
+func TestSourceStack(t *testing.T) {
+	t.Parallel()
+
+	for source, want := range map[string]string{
+		"producer-gin":        "gin",
+		"producer-ktor":       "ktor",
+		"producer-springboot": "springboot",
+		"producer-rust":       "unknown",
+		"":                    "unknown",
+	} {
+		if got := sourceStack(source); got != want {
+			t.Fatalf("sourceStack(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
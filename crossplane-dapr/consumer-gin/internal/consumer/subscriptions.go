@@ -0,0 +1,233 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSubscriptions is the single subscription configured through
+// DAPR_PUBSUB_NAME, DAPR_TOPIC_NAME and DAPR_SUBSCRIPTION_ROUTE, optionally
+// with bulk subscribe. With the quarantine enabled it declares a dead-letter
+// topic and subscribes to it. The result is checked with
+// ValidateSubscriptions.
+func DefaultSubscriptions(cfg Config) ([]DaprSubscription, error) {
+	subscription := DaprSubscription{
+		PubSubName: cfg.PubSubName,
+		Topic:      cfg.TopicName,
+		Route:      NormalizeRoute(cfg.SubscriptionRoute),
+	}
+	if cfg.Bulk.Enabled {
+		subscription.BulkSubscribe = &DaprBulkSubscribe{
+			Enabled:            true,
+			MaxMessagesCount:   cfg.Bulk.MaxMessagesCount,
+			MaxAwaitDurationMs: cfg.Bulk.MaxAwaitDurationMs,
+		}
+	}
+	subscriptions := []DaprSubscription{subscription}
+	if cfg.Quarantine.Enabled && cfg.Quarantine.DeadLetterTopic != "" {
+		subscriptions[0].DeadLetterTopic = cfg.Quarantine.DeadLetterTopic
+		subscriptions = append(subscriptions, DaprSubscription{
+			PubSubName: cfg.PubSubName,
+			Topic:      cfg.Quarantine.DeadLetterTopic,
+			Route:      NormalizeRoute(cfg.Quarantine.DeadLetterRoute),
+		})
+	}
+	if err := ValidateSubscriptions(subscriptions); err != nil {
+		return nil, fmt.Errorf("invalid default subscription: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// LoadSubscriptionFile reads a list of Dapr subscriptions from a YAML or JSON
+// file and validates it.
+func LoadSubscriptionFile(path string) ([]DaprSubscription, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read subscription file: %w", err)
+	}
+	var subscriptions []DaprSubscription
+	if err := yaml.Unmarshal(data, &subscriptions); err != nil {
+		return nil, fmt.Errorf("parse subscription file %s: %w", path, err)
+	}
+	if err := ValidateSubscriptions(subscriptions); err != nil {
+		return nil, fmt.Errorf("invalid subscription file %s: %w", path, err)
+	}
+	return subscriptions, nil
+}
+
+// ValidateSubscriptions checks that every subscription names a pubsub, a
+// topic and at least one route, that bulk subscribe limits are not negative,
+// that every rule match is a boolean CEL
+// expression, and that no pubsub/topic pair or route path is declared twice.
+// Route paths must be literal and must not collide with the endpoints
+// consumer-gin serves itself. Route paths are normalised in place.
+func ValidateSubscriptions(subscriptions []DaprSubscription) error {
+	if len(subscriptions) == 0 {
+		return errors.New("no subscriptions declared")
+	}
+	env, err := cel.NewEnv(cel.Variable("event", cel.DynType))
+	if err != nil {
+		return fmt.Errorf("create CEL environment: %w", err)
+	}
+
+	var errs []error
+	topics := map[string]bool{}
+	pathOwners := map[string]string{}
+	claimPath := func(index int, field, path string) string {
+		path = NormalizeRoute(path)
+		owner := fmt.Sprintf("subscriptions[%d]", index)
+		if err := checkRoutePath(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: path %s %w", owner, field, path, err))
+		}
+		if previous, ok := pathOwners[path]; ok && previous != owner {
+			errs = append(errs, fmt.Errorf("%s.%s: path %s is already used by %s", owner, field, path, previous))
+		}
+		pathOwners[path] = owner
+		return path
+	}
+
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		subscription.PubSubName = strings.TrimSpace(subscription.PubSubName)
+		subscription.Topic = strings.TrimSpace(subscription.Topic)
+		if subscription.PubSubName == "" {
+			errs = append(errs, fmt.Errorf("subscriptions[%d].pubsubname must not be blank", i))
+		}
+		if subscription.Topic == "" {
+			errs = append(errs, fmt.Errorf("subscriptions[%d].topic must not be blank", i))
+		}
+		key := subscription.PubSubName + "/" + subscription.Topic
+		if topics[key] {
+			errs = append(errs, fmt.Errorf("subscriptions[%d]: topic %s is subscribed more than once", i, key))
+		}
+		topics[key] = true
+		if bulk := subscription.BulkSubscribe; bulk != nil && (bulk.MaxMessagesCount < 0 || bulk.MaxAwaitDurationMs < 0) {
+			errs = append(errs, fmt.Errorf("subscriptions[%d].bulkSubscribe limits must not be negative", i))
+		}
+
+		if subscription.Routes == nil {
+			if strings.TrimSpace(subscription.Route) == "" {
+				errs = append(errs, fmt.Errorf("subscriptions[%d] must declare a route or routes", i))
+				continue
+			}
+			subscription.Route = claimPath(i, "route", subscription.Route)
+			continue
+		}
+		if strings.TrimSpace(subscription.Route) != "" {
+			errs = append(errs, fmt.Errorf("subscriptions[%d] must not declare both route and routes", i))
+		}
+		routes := subscription.Routes
+		if strings.TrimSpace(routes.Default) == "" && len(routes.Rules) == 0 {
+			errs = append(errs, fmt.Errorf("subscriptions[%d].routes must declare a default or at least one rule", i))
+		}
+		if strings.TrimSpace(routes.Default) != "" {
+			routes.Default = claimPath(i, "routes.default", routes.Default)
+		}
+		for j := range routes.Rules {
+			rule := &routes.Rules[j]
+			field := fmt.Sprintf("routes.rules[%d]", j)
+			if strings.TrimSpace(rule.Path) == "" {
+				errs = append(errs, fmt.Errorf("subscriptions[%d].%s.path must not be blank", i, field))
+			} else {
+				rule.Path = claimPath(i, field+".path", rule.Path)
+			}
+			if err := checkMatch(env, rule.Match); err != nil {
+				errs = append(errs, fmt.Errorf("subscriptions[%d].%s.match: %w", i, field, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reservedRoutes are served by consumer-gin itself; reservedRoutePrefixes
+// also reserve every path below them.
+var (
+	reservedRoutes        = []string{"/metrics"}
+	reservedRoutePrefixes = []string{"/health", "/dapr", "/admin"}
+)
+
+// checkRoutePath rejects gin wildcards and paths the router already serves.
+func checkRoutePath(path string) error {
+	if strings.ContainsAny(path, ":*") {
+		return errors.New("must not contain ':' or '*'")
+	}
+	for _, reserved := range reservedRoutes {
+		if path == reserved {
+			return errors.New("is reserved")
+		}
+	}
+	for _, prefix := range reservedRoutePrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return fmt.Errorf("is reserved: paths under %s are served by consumer-gin", prefix)
+		}
+	}
+	return nil
+}
+
+func checkMatch(env *cel.Env, match string) error {
+	if strings.TrimSpace(match) == "" {
+		return errors.New("must not be blank")
+	}
+	ast, issues := env.Compile(match)
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+	if outputType := ast.OutputType(); outputType != cel.BoolType && outputType != cel.DynType {
+		return fmt.Errorf("must evaluate to bool, not %s", outputType)
+	}
+	return nil
+}
+
+// subscriptionRoute is one HTTP path Dapr delivers to. sourceTopic is set on
+// dead-letter routes.
+type subscriptionRoute struct {
+	path        string
+	pubsubName  string
+	topic       string
+	deadLetter  bool
+	sourceTopic string
+}
+
+// subscriptionRoutes lists the distinct delivery paths of subscriptions in
+// declaration order.
+func subscriptionRoutes(subscriptions []DaprSubscription) []subscriptionRoute {
+	sourceTopics := map[string]string{}
+	for _, subscription := range subscriptions {
+		key := subscription.PubSubName + "/" + subscription.DeadLetterTopic
+		if _, ok := sourceTopics[key]; subscription.DeadLetterTopic != "" && !ok {
+			sourceTopics[key] = subscription.Topic
+		}
+	}
+
+	var routes []subscriptionRoute
+	seen := map[string]bool{}
+	for _, subscription := range subscriptions {
+		sourceTopic, deadLetter := sourceTopics[subscription.PubSubName+"/"+subscription.Topic]
+		add := func(path string) {
+			if path == "" || seen[path] {
+				return
+			}
+			seen[path] = true
+			routes = append(routes, subscriptionRoute{
+				path:        path,
+				pubsubName:  subscription.PubSubName,
+				topic:       subscription.Topic,
+				deadLetter:  deadLetter,
+				sourceTopic: sourceTopic,
+			})
+		}
+		add(subscription.Route)
+		if subscription.Routes != nil {
+			for _, rule := range subscription.Routes.Rules {
+				add(rule.Path)
+			}
+			add(subscription.Routes.Default)
+		}
+	}
+	return routes
+}
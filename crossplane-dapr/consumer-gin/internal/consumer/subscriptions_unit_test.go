@@ -0,0 +1,213 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func wantFileSubscriptions() []DaprSubscription {
+	return []DaprSubscription{
+		{
+			PubSubName: "order-pubsub",
+			Topic:      "orders",
+			Routes: &DaprRoutes{
+				Rules: []DaprRoutingRule{
+					{Match: `event.type == "com.agnostic.order.created.v2"`, Path: "/orders/v2"},
+					{Match: "event.data.amount > 1000", Path: "/orders/large"},
+				},
+				Default: "/orders",
+			},
+			Metadata:        map[string]string{"rawPayload": "false"},
+			DeadLetterTopic: "orders-dead-letter",
+		},
+		{PubSubName: "order-pubsub", Topic: "orders-dead-letter", Route: "/orders/dead-letter"},
+	}
+}
+
+func TestLoadSubscriptionFile(t *testing.T) {
+	t.Parallel()
+
+	want := wantFileSubscriptions()
+	yamlSubscriptions, err := LoadSubscriptionFile(filepath.Join("testdata", "subscriptions.yaml"))
+	if err != nil {
+		t.Fatalf("load YAML subscriptions: %v", err)
+	}
+	if !reflect.DeepEqual(yamlSubscriptions, want) {
+		t.Fatalf("YAML subscriptions = %+v, want %+v", yamlSubscriptions, want)
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("encode subscriptions: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write JSON subscriptions: %v", err)
+	}
+	jsonSubscriptions, err := LoadSubscriptionFile(path)
+	if err != nil {
+		t.Fatalf("load JSON subscriptions: %v", err)
+	}
+	if !reflect.DeepEqual(jsonSubscriptions, want) {
+		t.Fatalf("JSON subscriptions = %+v, want %+v", jsonSubscriptions, want)
+	}
+}
+
+func TestValidateSubscriptionsRejectsInvalidFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "empty", file: `[]`, wantErr: "no subscriptions declared"},
+		{name: "blank pubsub", file: `[{topic: orders, route: /orders}]`, wantErr: "subscriptions[0].pubsubname must not be blank"},
+		{name: "blank topic", file: `[{pubsubname: p, route: /orders}]`, wantErr: "subscriptions[0].topic must not be blank"},
+		{name: "no route", file: `[{pubsubname: p, topic: orders}]`, wantErr: "must declare a route or routes"},
+		{name: "route and routes", file: `[{pubsubname: p, topic: orders, route: /a, routes: {default: /b}}]`, wantErr: "must not declare both route and routes"},
+		{name: "empty routes", file: `[{pubsubname: p, topic: orders, routes: {}}]`, wantErr: "must declare a default or at least one rule"},
+		{name: "blank rule path", file: `[{pubsubname: p, topic: orders, routes: {rules: [{match: "true"}]}}]`, wantErr: "routes.rules[0].path must not be blank"},
+		{name: "blank match", file: `[{pubsubname: p, topic: orders, routes: {rules: [{path: /a}]}}]`, wantErr: "routes.rules[0].match: must not be blank"},
+		{name: "invalid CEL", file: `[{pubsubname: p, topic: orders, routes: {rules: [{match: "event.type ==", path: /a}]}}]`, wantErr: "routes.rules[0].match: ERROR"},
+		{name: "non-boolean CEL", file: `[{pubsubname: p, topic: orders, routes: {rules: [{match: "size(event.type)", path: /a}]}}]`, wantErr: "must evaluate to bool, not int"},
+		{name: "string CEL", file: `[{pubsubname: p, topic: orders, routes: {rules: [{match: "'order'", path: /a}]}}]`, wantErr: "must evaluate to bool, not string"},
+		{name: "duplicate topic", file: `[{pubsubname: p, topic: orders, route: /a}, {pubsubname: p, topic: orders, route: /b}]`, wantErr: "topic p/orders is subscribed more than once"},
+		{name: "shared path", file: `[{pubsubname: p, topic: a, route: /orders}, {pubsubname: p, topic: b, route: orders}]`, wantErr: "path /orders is already used by subscriptions[0]"},
+		{name: "negative bulk limit", file: `[{pubsubname: p, topic: orders, route: /a, bulkSubscribe: {enabled: true, maxMessagesCount: -1}}]`, wantErr: "bulkSubscribe limits must not be negative"},
+		{name: "not a list", file: `pubsubname: p`, wantErr: "parse subscription file"},
+		{name: "path parameter", file: `[{pubsubname: p, topic: orders, route: /orders/:id}]`, wantErr: "path /orders/:id must not contain ':' or '*'"},
+		{name: "catch-all", file: `[{pubsubname: p, topic: orders, route: "/orders/*rest"}]`, wantErr: "path /orders/*rest must not contain ':' or '*'"},
+		{name: "rule wildcard", file: `[{pubsubname: p, topic: orders, routes: {rules: [{match: "true", path: "/a/:b"}]}}]`, wantErr: "routes.rules[0].path: path /a/:b must not contain"},
+		{name: "health", file: `[{pubsubname: p, topic: orders, route: /health}]`, wantErr: "path /health is reserved"},
+		{name: "health probe", file: `[{pubsubname: p, topic: orders, route: /health/ready}]`, wantErr: "path /health/ready is reserved"},
+		{name: "metrics", file: `[{pubsubname: p, topic: orders, route: metrics}]`, wantErr: "path /metrics is reserved"},
+		{name: "dapr subscribe", file: `[{pubsubname: p, topic: orders, route: /dapr/subscribe}]`, wantErr: "path /dapr/subscribe is reserved"},
+		{name: "admin", file: `[{pubsubname: p, topic: orders, routes: {default: /admin/quarantine}}]`, wantErr: "routes.default: path /admin/quarantine is reserved"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "subscriptions.yaml")
+			if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+				t.Fatalf("write subscriptions: %v", err)
+			}
+			_, err := LoadSubscriptionFile(path)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("LoadSubscriptionFile() error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDaprSubscribeServesDefaultSubscription(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry)
+
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	want := `[{"pubsubname":"order-pubsub","topic":"orders","route":"/orders"}]`
+	if res.Code != http.StatusOK || res.Body.String() != want {
+		t.Fatalf("got %d %s, want 200 %s", res.Code, res.Body.String(), want)
+	}
+}
+
+func TestDefaultSubscriptionsRejectsInvalidRoutes(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []Config{
+		{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/health"},
+		{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/*x"},
+		{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders",
+			Quarantine: QuarantineConfig{Enabled: true, DeadLetterTopic: "orders-dead-letter", DeadLetterRoute: "/orders"}},
+		{TopicName: "orders", SubscriptionRoute: "/orders"},
+	} {
+		if _, err := DefaultSubscriptions(cfg); err == nil {
+			t.Fatalf("DefaultSubscriptions(%+v) error = nil", cfg)
+		}
+	}
+}
+
+func TestSubscriptionFileRoutesDeliveries(t *testing.T) {
+	t.Parallel()
+
+	subscriptions, err := LoadSubscriptionFile(filepath.Join("testdata", "subscriptions.yaml"))
+	if err != nil {
+		t.Fatalf("load subscriptions: %v", err)
+	}
+	handled := map[string][]string{}
+	recordTo := func(name string) OrderEventHandler {
+		return OrderEventHandlerFunc(func(_ context.Context, event OrderEvent) error {
+			handled[name] = append(handled[name], event.OrderID())
+			return nil
+		})
+	}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, registry, registry,
+		WithSubscriptions(subscriptions),
+		WithOrderEventHandler(recordTo("shared")),
+		WithRouteHandler("orders/v2", recordTo("v2")),
+		WithRouteHandler("/orders/dead-letter", recordTo("dead-letter")),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	var served []DaprSubscription
+	if err := json.Unmarshal(res.Body.Bytes(), &served); err != nil {
+		t.Fatalf("decode /dapr/subscribe %q: %v", res.Body.String(), err)
+	}
+	if !reflect.DeepEqual(served, wantFileSubscriptions()) {
+		t.Fatalf("/dapr/subscribe = %s", res.Body.String())
+	}
+	if !strings.Contains(res.Body.String(), `"deadLetterTopic":"orders-dead-letter"`) || strings.Contains(res.Body.String(), `"route":""`) {
+		t.Fatalf("/dapr/subscribe = %s", res.Body.String())
+	}
+
+	for path, id := range map[string]string{
+		"/orders":             "ORD-DEFAULT",
+		"/orders/v2":          "ORD-V2",
+		"/orders/large":       "ORD-LARGE",
+		"/orders/dead-letter": "ORD-DEAD",
+	} {
+		body := `{"data":{"id":"` + id + `","amount":10,"eventVersion":"v1"}}`
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), string(SubscriptionSuccess)) {
+			t.Fatalf("POST %s = %d %s", path, res.Code, res.Body.String())
+		}
+	}
+
+	want := map[string][]string{
+		"shared":      {"ORD-DEFAULT", "ORD-LARGE"},
+		"v2":          {"ORD-V2"},
+		"dead-letter": {"ORD-DEAD"},
+	}
+	sort.Strings(handled["shared"])
+	if !reflect.DeepEqual(handled, want) {
+		t.Fatalf("handled = %v, want %v", handled, want)
+	}
+}
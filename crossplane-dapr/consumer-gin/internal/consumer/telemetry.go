@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/telemetry"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/agnostic/crossplane-dapr/consumer-gin/internal/consumer"
+	defaultServiceName  = "consumer-gin"
+)
+
+// SetupTelemetry installs the global OpenTelemetry tracer provider and
+// propagator, see telemetry.Setup; a blank serviceName selects consumer-gin.
+// The returned function flushes pending spans and must be called on shutdown.
+func SetupTelemetry(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if strings.TrimSpace(serviceName) == "" {
+		serviceName = defaultServiceName
+	}
+	return telemetry.Setup(ctx, serviceName, logger)
+}
+
+// NewPropagator returns the W3C and B3 propagator shared by the Gin services,
+// see telemetry.NewPropagator.
+func NewPropagator() propagation.TextMapPropagator {
+	return telemetry.NewPropagator()
+}
+
+// eventTraceContext extracts the CloudEvent traceparent/tracestate
+// extensions.
+func eventTraceContext(ctx context.Context, propagator propagation.TextMapPropagator, metadata CloudEventMetadata) (context.Context, bool) {
+	traceParent := metadata.Extensions["traceparent"]
+	if traceParent == "" {
+		return ctx, false
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": traceParent}
+	if traceState := metadata.Extensions["tracestate"]; traceState != "" {
+		carrier["tracestate"] = traceState
+	}
+	eventCtx := propagator.Extract(ctx, carrier)
+	if spanContext := trace.SpanContextFromContext(eventCtx); !spanContext.IsValid() {
+		return ctx, false
+	}
+	return eventCtx, true
+}
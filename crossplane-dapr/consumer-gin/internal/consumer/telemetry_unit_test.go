@@ -0,0 +1,184 @@
+//go:build !integration && !contract && !e2e
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedRouter(t *testing.T) (http.Handler, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tracerProvider.Shutdown(context.Background()) })
+
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", SubscriptionRoute: "/orders"}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(cfg, registry, registry, WithTracerProvider(tracerProvider), WithPropagator(NewPropagator()))
+	return router, exporter
+}
+
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not recorded, got %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestConsumeRecordsServerAndConsumerSpans(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	spans := exporter.GetSpans()
+	server := spanByName(t, spans, "POST /orders")
+	consume := spanByName(t, spans, "orders.consume")
+
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("server span did not continue incoming trace, got %s", got)
+	}
+	if consume.SpanKind != trace.SpanKindConsumer {
+		t.Fatalf("expected consumer span kind, got %v", consume.SpanKind)
+	}
+	if consume.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("expected consumer span to be a child of the server span")
+	}
+	if consume.Status.Code != codes.Ok {
+		t.Fatalf("expected ok status, got %v", consume.Status)
+	}
+}
+
+func TestConsumeContinuesCloudEventTrace(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t)
+
+	body := `{"traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01","data":{"id":"ORD-2","amount":10,"eventVersion":"v1"}}`
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	spans := exporter.GetSpans()
+	server := spanByName(t, spans, "POST /orders")
+	consume := spanByName(t, spans, "orders.consume")
+
+	if got := consume.SpanContext.TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatalf("consumer span did not continue cloudevent trace, got %s", got)
+	}
+	if got := consume.Parent.SpanID().String(); got != "b7ad6b7169203331" {
+		t.Fatalf("expected consumer span parent from cloudevent, got %s", got)
+	}
+	if len(consume.Links) != 1 || consume.Links[0].SpanContext.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("expected consumer span to link the delivery request span, got %+v", consume.Links)
+	}
+}
+
+func TestConsumeSpanRecordsParseFailures(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"data":{"amount":10}}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || !bytes.Contains(res.Body.Bytes(), []byte(`"DROP"`)) {
+		t.Fatalf("expected 200 DROP, got %d %s", res.Code, res.Body.String())
+	}
+
+	consume := spanByName(t, exporter.GetSpans(), "orders.consume")
+	if consume.Status.Code != codes.Error {
+		t.Fatalf("expected error status, got %v", consume.Status)
+	}
+}
+
+// captureLogs points the package logger at a JSON buffer for the rest of the
+// test and returns a function that decodes the records written so far.
+// Tests using it must not run in parallel.
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	previous := logger
+	logger = logging.New(&out, logging.Config{Level: slog.LevelInfo, Format: logging.FormatJSON})
+	t.Cleanup(func() { logger = previous })
+	return func() []map[string]any {
+		t.Helper()
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("decode log line %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func recordsWithMessage(records []map[string]any, msg string) []map[string]any {
+	var matching []map[string]any
+	for _, record := range records {
+		if record["msg"] == msg {
+			matching = append(matching, record)
+		}
+	}
+	return matching
+}
+
+func TestConsumeLogsCarryTheConsumerSpanContext(t *testing.T) {
+	records := captureLogs(t)
+	router, exporter := newTracedRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewBufferString(`{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	spans := exporter.GetSpans()
+	server := spanByName(t, spans, "POST /orders")
+	consume := spanByName(t, spans, "orders.consume")
+	consumed := recordsWithMessage(records(), "consumed order event")
+	if len(consumed) != 1 || consumed[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || consumed[0]["span_id"] != consume.SpanContext.SpanID().String() {
+		t.Fatalf("consumed records = %v, want the orders.consume span context", consumed)
+	}
+	access := recordsWithMessage(records(), "http request")
+	if len(access) != 1 || access[0]["route"] != "/orders" || access[0]["status"] != 200.0 ||
+		access[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || access[0]["span_id"] != server.SpanContext.SpanID().String() {
+		t.Fatalf("access records = %v, want the delivery with the server span context and no probe", access)
+	}
+}
@@ -33,11 +33,48 @@ func TestNormalizeRoute(t *testing.T) {
 func TestParseOrderEvent(t *testing.T) {
 	t.Parallel()
 
-	event, err := ParseOrderEvent(context.Background(), []byte(`{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`))
+	tests := []struct {
+		name        string
+		payload     string
+		wantID      string
+		wantVersion string
+		wantErr     bool
+	}{
+		{name: "v1 cloudevent", payload: `{"data":{"id":"ORD-1","amount":10,"eventVersion":"v1"}}`, wantID: "ORD-1", wantVersion: "v1"},
+		{name: "v1 without version", payload: `{"id":"ORD-2","amount":10}`, wantID: "ORD-2", wantVersion: "v1"},
+		{name: "v2 cloudevent", payload: `{"data":{"id":"ORD-3","amount":12.5,"currency":"EUR","customerId":"CUST-1","lineItems":[{"sku":"SKU-1","quantity":1,"unitPrice":12.5}],"createdAt":"2026-03-01T12:00:00Z","eventVersion":"v2"}}`, wantID: "ORD-3", wantVersion: "v2"},
+		{name: "v2 raw payload", payload: `{"id":"ORD-4","amount":1,"currency":"USD","customerId":"CUST-2","eventVersion":"v2"}`, wantID: "ORD-4", wantVersion: "v2"},
+		{name: "unsupported version", payload: `{"data":{"id":"ORD-5","eventVersion":"v9"}}`, wantErr: true},
+		{name: "blank id", payload: `{"data":{"id":" ","eventVersion":"v2"}}`, wantErr: true},
+		{name: "malformed", payload: `not-json`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			event, err := ParseOrderEvent(context.Background(), []byte(tc.payload))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event.OrderID() != tc.wantID || event.Version() != tc.wantVersion {
+				t.Fatalf("unexpected event: %+v", event)
+			}
+		})
+	}
+
+	event, err := ParseOrderEvent(context.Background(), []byte(`{"data":{"id":"ORD-6","amount":5,"currency":"EUR","customerId":"CUST-1","lineItems":[{"sku":"SKU-1","quantity":2,"unitPrice":2.5}],"createdAt":"2026-03-01T12:00:00Z","eventVersion":"v2"}}`))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if event.ID != "ORD-1" || event.EventVersion != "v1" {
-		t.Fatalf("unexpected event: %+v", event)
+	v2, ok := event.(OrderCreatedV2)
+	if !ok || v2.Currency != "EUR" || v2.CustomerID != "CUST-1" || len(v2.LineItems) != 1 || v2.LineItems[0].Quantity != 2 {
+		t.Fatalf("unexpected v2 event: %+v", event)
 	}
 }
@@ -1,30 +1,106 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/lifecycle"
 	"github.com/agnostic/crossplane-dapr/producer-gin/internal/producer"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run serves until SIGTERM or SIGINT and returns the process exit code. It
+// is separate from main so deferred cleanup runs before the process exits.
+func run() int {
 	cfg := producer.LoadConfigFromEnv()
 	slog.SetDefault(producer.Logger())
+
+	shutdownTelemetry, err := producer.SetupTelemetry(context.Background(), cfg.ServiceName)
+	if err != nil {
+		slog.Error("failed to configure telemetry", "error", err)
+		return lifecycle.ExitError
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			slog.Warn("failed to flush telemetry", "error", err)
+		}
+	}()
+
 	client := &http.Client{Timeout: 5 * time.Second}
-	service := producer.NewService(client, cfg.PublishURL())
-	router := producer.NewRouter(cfg, service, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+	publisher, closePublisher, err := producer.NewPublisher(cfg, client, prometheus.DefaultRegisterer)
+	if err != nil {
+		slog.Error("failed to configure dapr publisher", "transport", cfg.DaprTransport, "error", err)
+		return lifecycle.ExitError
+	}
+	defer closePublisher()
+
+	checks := producer.NewHealth(cfg, client)
+	routerOpts := []producer.Option{producer.WithHealth(checks)}
+	lifecycleOpts := []lifecycle.Option{lifecycle.WithConfig(cfg.Shutdown), lifecycle.WithHealth(checks)}
+	idempotencyStore, err := producer.NewIdempotencyStore(cfg, client)
+	if err != nil {
+		slog.Error("failed to configure idempotency store", "error", err)
+		return lifecycle.ExitError
+	}
+	if idempotencyStore != nil {
+		routerOpts = append(routerOpts, producer.WithIdempotency(idempotencyStore, cfg.Idempotency.Window))
+	}
+	if cfg.Outbox.Enabled {
+		store, err := producer.OpenBoltOutboxStore(cfg.Outbox.Path)
+		if err != nil {
+			slog.Error("failed to open outbox store", "path", cfg.Outbox.Path, "error", err)
+			return lifecycle.ExitError
+		}
+		defer store.Close()
+
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		defer stopRelay()
+		relay := producer.NewOutboxRelay(store, publisher, cfg.Outbox, prometheus.DefaultRegisterer)
+		relayDone := make(chan struct{})
+		go func() {
+			defer close(relayDone)
+			relay.Run(relayCtx)
+		}()
+		lifecycleOpts = append(lifecycleOpts, lifecycle.WithStopHook("outbox relay", func(ctx context.Context) error {
+			stopRelay()
+			select {
+			case <-relayDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}))
+
+		routerOpts = append(routerOpts, producer.WithOutbox(store))
+		slog.Info("outbox mode enabled", "path", cfg.Outbox.Path)
+	}
+	router := producer.NewRouter(cfg, publisher, prometheus.DefaultRegisterer, prometheus.DefaultGatherer, routerOpts...)
 
 	slog.Info("starting producer-gin",
 		"port", cfg.Port,
 		"pubsub", cfg.PubSubName,
 		"topic", cfg.TopicName,
 		"daprHttpPort", cfg.DaprHTTPPort,
+		"daprTransport", cfg.DaprTransport,
 	)
-	if err := router.Run(":" + cfg.Port); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router, ReadHeaderTimeout: 10 * time.Second}
+	if err := lifecycle.Run(ctx, server, lifecycleOpts...); err != nil {
 		slog.Error("producer-gin stopped with error", "error", err)
-		return
+		return lifecycle.ExitCode(err)
 	}
 	slog.Info("producer-gin stopped")
+	return lifecycle.ExitOK
 }
@@ -0,0 +1,399 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrBatchTooLarge is returned when a batch holds more entries than
+	// BatchConfig.MaxEntries.
+	ErrBatchTooLarge = errors.New("batch exceeds the maximum number of entries")
+	// ErrEmptyBatch is returned when a batch holds no entries.
+	ErrEmptyBatch = errors.New("batch must contain at least one entry")
+)
+
+// BatchConfig bounds POST /publish/batch. ChunkSize is the number of entries
+// sent per Dapr bulk publish call. MaxBodyBytes bounds the request body; zero
+// or less selects defaultBatchMaxBodyBytes.
+type BatchConfig struct {
+	ChunkSize    int
+	MaxEntries   int
+	MaxBodyBytes int64
+}
+
+const defaultBatchMaxBodyBytes = 16 << 20
+
+func (c BatchConfig) maxBodyBytes() int64 {
+	if c.MaxBodyBytes <= 0 {
+		return defaultBatchMaxBodyBytes
+	}
+	return c.MaxBodyBytes
+}
+
+type BatchEntryStatus string
+
+const (
+	BatchEntryAccepted BatchEntryStatus = "accepted"
+	BatchEntryRejected BatchEntryStatus = "rejected"
+)
+
+// BatchEntryResult is the outcome of one batch entry, reported at the index
+// the entry had in the request.
+type BatchEntryResult struct {
+	Index   int              `json:"index"`
+	OrderID string           `json:"orderId,omitempty"`
+	Status  BatchEntryStatus `json:"status"`
+	Reason  string           `json:"reason,omitempty"`
+}
+
+// batchEntry is one decoded batch line. err is set when the entry is not a
+// valid PublishOrderRequest document.
+type batchEntry struct {
+	request PublishOrderRequest
+	err     error
+}
+
+// decodeBatch reads a JSON array or NDJSON stream. Malformed entries keep
+// their error so they can be rejected individually.
+func decodeBatch(body io.Reader, maxEntries int) ([]batchEntry, error) {
+	reader := bufio.NewReader(body)
+	first, err := peekNonSpace(reader)
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyBatch
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read batch: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if first == '[' {
+		// Stream the array so an oversized batch is rejected as soon as
+		// entry maxEntries+1 is read.
+		decoder := json.NewDecoder(reader)
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("decode batch array: %w", err)
+		}
+		for decoder.More() {
+			var document json.RawMessage
+			if err := decoder.Decode(&document); err != nil {
+				return nil, fmt.Errorf("decode batch array: %w", err)
+			}
+			raw = append(raw, document)
+			if maxEntries > 0 && len(raw) > maxEntries {
+				return nil, ErrBatchTooLarge
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("decode batch array: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			raw = append(raw, json.RawMessage(bytes.Clone(line)))
+			if maxEntries > 0 && len(raw) > maxEntries {
+				return nil, ErrBatchTooLarge
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read batch stream: %w", err)
+		}
+	}
+
+	if len(raw) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	entries := make([]batchEntry, len(raw))
+	for i, document := range raw {
+		if err := json.Unmarshal(document, &entries[i].request); err != nil {
+			entries[i].err = errors.New("invalid entry payload")
+		}
+	}
+	return entries, nil
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = reader.ReadByte()
+		default:
+			return next[0], nil
+		}
+	}
+}
+
+type bulkPublishEntry struct {
+	EntryID     string          `json:"entryId"`
+	Event       json.RawMessage `json:"event"`
+	ContentType string          `json:"contentType"`
+}
+
+type bulkPublishResponse struct {
+	FailedEntries []struct {
+		EntryID string `json:"entryId"`
+		Error   string `json:"error"`
+	} `json:"failedEntries"`
+	ErrorCode string `json:"errorCode"`
+}
+
+// isBulkPartialFailure reports whether resp lists failed entries. Dapr
+// answers 500, but a retry would republish the accepted entries.
+func isBulkPartialFailure(resp *http.Response) bool {
+	payload, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	if err != nil {
+		return false
+	}
+	var body bulkPublishResponse
+	return json.Unmarshal(payload, &body) == nil && len(body.FailedEntries) > 0
+}
+
+// PublishBulk publishes requests in one Dapr bulk publish call. A retried
+// call republishes the entries Dapr had already accepted, so bulk publishing
+// is at-least-once like the single-event path.
+func (s *Service) PublishBulk(ctx context.Context, requests []PublishOrderRequest) ([]error, error) {
+	return tracePublishBulk(ctx, s.tracer, len(requests), func(ctx context.Context) ([]error, error) {
+		return s.publishBulk(ctx, requests)
+	})
+}
+
+func (s *Service) publishBulk(ctx context.Context, requests []PublishOrderRequest) ([]error, error) {
+	requestLogger := loggerFromContext(ctx)
+	if s.bulkPublishURL == "" {
+		return nil, errors.New("bulk publish URL is not configured")
+	}
+
+	entries := make([]bulkPublishEntry, len(requests))
+	for i, request := range requests {
+		event, contentType, err := s.encoder.encode(ctx, request.Event())
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = bulkPublishEntry{EntryID: strconv.Itoa(i), Event: event, ContentType: contentType}
+	}
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("encode bulk events: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.bulkPublishURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create bulk publish request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	s.injectTraceContext(ctx, httpReq)
+	setPublishMetadata(httpReq, s.encoder.metadata())
+	requestLogger.DebugContext(ctx, "publishing order events in bulk", "entries", len(requests), "url", s.bulkPublishURL)
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		requestLogger.ErrorContext(ctx, "bulk publish request failed", "entries", len(requests), "error", err)
+		return nil, fmt.Errorf("bulk publish request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	entryErrs := make([]error, len(requests))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return entryErrs, nil
+	}
+
+	// Dapr reports partial failures as a non-2xx status listing the failed
+	// entries; any other non-2xx answer failed the whole call.
+	var body bulkPublishResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || len(body.FailedEntries) == 0 {
+		requestLogger.WarnContext(ctx, "bulk publish endpoint returned non-2xx status", "entries", len(requests), "statusCode", resp.StatusCode)
+		return nil, fmt.Errorf("bulk publish endpoint returned status %d", resp.StatusCode)
+	}
+	for _, failed := range body.FailedEntries {
+		index, err := strconv.Atoi(failed.EntryID)
+		if err != nil || index < 0 || index >= len(requests) {
+			continue
+		}
+		reason := failed.Error
+		if reason == "" {
+			reason = body.ErrorCode
+		}
+		entryErrs[index] = errors.New(reason)
+	}
+	requestLogger.WarnContext(ctx, "bulk publish partially failed", "entries", len(requests), "failed", len(body.FailedEntries), "statusCode", resp.StatusCode)
+	return entryErrs, nil
+}
+
+// batchPublisher serves POST /publish/batch.
+type batchPublisher struct {
+	config     BatchConfig
+	maxScale   int
+	publisher  Publisher
+	outbox     OutboxStore
+	propagator propagation.TextMapPropagator
+	published  prometheus.Counter
+	entries    *prometheus.CounterVec
+}
+
+func newBatchPublisher(config BatchConfig, maxScale int, publisher Publisher, resolved options, published prometheus.Counter, registerer prometheus.Registerer) *batchPublisher {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = 100
+	}
+	batch := &batchPublisher{
+		config:     config,
+		maxScale:   maxScale,
+		publisher:  publisher,
+		outbox:     resolved.outbox,
+		propagator: resolved.propagator,
+		published:  published,
+		entries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_publish_batch_entries_total",
+			Help: "Total batch publish entries handled by producer-gin by result.",
+		}, []string{"result"}),
+	}
+	registerer.MustRegister(batch.entries)
+	return batch
+}
+
+func (b *batchPublisher) handle(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, b.config.maxBodyBytes())
+	entries, err := decodeBatch(body, b.config.MaxEntries)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		requestLogger.WarnContext(c.Request.Context(), "batch publish request body too large", "limit", tooLarge.Limit)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit)})
+		return
+	}
+	if errors.Is(err, ErrBatchTooLarge) {
+		requestLogger.WarnContext(c.Request.Context(), "batch publish request too large", "maxEntries", b.config.MaxEntries)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("batch must not contain more than %d entries", b.config.MaxEntries)})
+		return
+	}
+	if errors.Is(err, ErrEmptyBatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLogger.WarnContext(c.Request.Context(), "invalid batch publish payload", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	results := make([]BatchEntryResult, len(entries))
+	var valid []int
+	for i, entry := range entries {
+		results[i] = BatchEntryResult{Index: i, OrderID: entry.request.ID, Status: BatchEntryRejected}
+		if entry.err == nil {
+			entry.err = entry.request.Validate(b.maxScale)
+		}
+		if entry.err != nil {
+			results[i].Reason = entry.err.Error()
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	if b.outbox != nil {
+		b.acceptIntoOutbox(c, entries, valid, results)
+	} else {
+		b.publish(c, entries, valid, results)
+	}
+
+	accepted := 0
+	for _, result := range results {
+		if result.Status == BatchEntryAccepted {
+			accepted++
+		}
+	}
+	rejected := len(results) - accepted
+	b.entries.WithLabelValues(string(BatchEntryAccepted)).Add(float64(accepted))
+	b.entries.WithLabelValues(string(BatchEntryRejected)).Add(float64(rejected))
+	requestLogger.InfoContext(c.Request.Context(), "handled batch publish request", "entries", len(results), "accepted", accepted, "rejected", rejected)
+	c.JSON(http.StatusAccepted, gin.H{"accepted": accepted, "rejected": rejected, "results": results})
+}
+
+func (b *batchPublisher) publish(c *gin.Context, entries []batchEntry, valid []int, results []BatchEntryResult) {
+	requestLogger := loggerFromGinContext(c)
+
+	for start := 0; start < len(valid); start += b.config.ChunkSize {
+		chunk := valid[start:min(start+b.config.ChunkSize, len(valid))]
+		requests := make([]PublishOrderRequest, len(chunk))
+		for i, index := range chunk {
+			requests[i] = entries[index].request
+		}
+
+		entryErrs, err := b.publisher.PublishBulk(c.Request.Context(), requests)
+		if err != nil {
+			reason := "failed to publish event"
+			if errors.Is(err, ErrCircuitOpen) {
+				reason = "event broker unavailable"
+			}
+			requestLogger.ErrorContext(c.Request.Context(), "bulk publish chunk failed", "offset", start, "entries", len(chunk), "error", err)
+			for _, index := range chunk {
+				results[index].Reason = reason
+			}
+			continue
+		}
+		for i, index := range chunk {
+			if entryErrs[i] != nil {
+				results[index].Reason = entryErrs[i].Error()
+				continue
+			}
+			results[index].Status = BatchEntryAccepted
+			b.published.Inc()
+		}
+	}
+}
+
+func (b *batchPublisher) acceptIntoOutbox(c *gin.Context, entries []batchEntry, valid []int, results []BatchEntryResult) {
+	requestLogger := loggerFromGinContext(c)
+	ctx := c.Request.Context()
+
+	traceContext := propagation.MapCarrier{}
+	b.propagator.Inject(ctx, traceContext)
+	now := time.Now().UTC()
+	for _, index := range valid {
+		request := entries[index].request
+		err := b.outbox.Enqueue(ctx, OutboxRecord{
+			OrderID:       request.ID,
+			Request:       request,
+			TraceContext:  traceContext,
+			CorrelationID: correlationIDFromContext(ctx),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		switch {
+		case errors.Is(err, ErrOutboxDuplicate):
+			results[index].Reason = "order already accepted"
+		case err != nil:
+			requestLogger.ErrorContext(c.Request.Context(), "failed to store order in outbox", "orderId", request.ID, "error", err)
+			results[index].Reason = "failed to accept order"
+		default:
+			results[index].Status = BatchEntryAccepted
+		}
+	}
+}
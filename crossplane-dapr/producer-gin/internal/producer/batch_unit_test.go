@@ -0,0 +1,249 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		body        string
+		wantIDs     []string
+		wantInvalid []int
+		wantErr     error
+	}{
+		{name: "json array", body: ` [{"id":"ORD-1","amount":1},{"id":"ORD-2","amount":2}]`, wantIDs: []string{"ORD-1", "ORD-2"}},
+		{name: "ndjson stream", body: "{\"id\":\"ORD-1\",\"amount\":1}\n\n{\"id\":\"ORD-2\",\"amount\":2}\n", wantIDs: []string{"ORD-1", "ORD-2"}},
+		{name: "keeps malformed entries", body: "{\"id\":\"ORD-1\",\"amount\":1}\nnot-json\n", wantIDs: []string{"ORD-1", ""}, wantInvalid: []int{1}},
+		{name: "empty body", body: "  \n", wantErr: ErrEmptyBatch},
+		{name: "empty array", body: `[]`, wantErr: ErrEmptyBatch},
+		{name: "too many entries", body: `[{"id":"1"},{"id":"2"},{"id":"3"},{"id":"4"}]`, wantErr: ErrBatchTooLarge},
+		{name: "stops reading past the limit", body: `[{"id":"1"},{"id":"2"},{"id":"3"},{"id":"4"},not-json`, wantErr: ErrBatchTooLarge},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := decodeBatch(strings.NewReader(tc.body), 3)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			if len(entries) != len(tc.wantIDs) {
+				t.Fatalf("expected %d entries, got %d", len(tc.wantIDs), len(entries))
+			}
+			invalid := []int{}
+			for i, entry := range entries {
+				if entry.request.ID != tc.wantIDs[i] {
+					t.Fatalf("entry %d id = %q, want %q", i, entry.request.ID, tc.wantIDs[i])
+				}
+				if entry.err != nil {
+					invalid = append(invalid, i)
+				}
+			}
+			if len(invalid) != len(tc.wantInvalid) {
+				t.Fatalf("invalid entries = %v, want %v", invalid, tc.wantInvalid)
+			}
+		})
+	}
+
+	if _, err := decodeBatch(strings.NewReader(`[{"id":`), 0); err == nil || errors.Is(err, ErrEmptyBatch) {
+		t.Fatalf("expected decode error for truncated array, got %v", err)
+	}
+}
+
+type batchResponse struct {
+	Accepted int                `json:"accepted"`
+	Rejected int                `json:"rejected"`
+	Results  []BatchEntryResult `json:"results"`
+}
+
+func postBatch(t *testing.T, router http.Handler, body string) (int, batchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/publish/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	var decoded batchResponse
+	_ = json.Unmarshal(res.Body.Bytes(), &decoded)
+	return res.Code, decoded
+}
+
+func TestPublishBatchUsesDaprBulkAPI(t *testing.T) {
+	t.Parallel()
+
+	var chunks [][]bulkPublishEntry
+	service := NewService(doerFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/v1.0-alpha1/publish/bulk/order-pubsub/orders" {
+			t.Errorf("unexpected bulk publish path %s", req.URL.Path)
+		}
+		var entries []bulkPublishEntry
+		if err := json.NewDecoder(req.Body).Decode(&entries); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, entries)
+		if len(chunks) == 2 {
+			body := `{"failedEntries":[{"entryId":"1","error":"broker rejected message"}],"errorCode":"ERR_PUBSUB_PUBLISH_MESSAGE"}`
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://localhost:3500/v1.0/publish/order-pubsub/orders",
+		WithBulkPublishURL("http://localhost:3500/v1.0-alpha1/publish/bulk/order-pubsub/orders"))
+	registry := prometheus.NewRegistry()
+	cfg := Config{PubSubName: "order-pubsub", TopicName: "orders", Batch: BatchConfig{ChunkSize: 2, MaxEntries: 10}}
+	router := NewRouter(cfg, service, registry, registry)
+
+	code, response := postBatch(t, router, strings.Join([]string{
+		`{"id":"ORD-1","amount":10}`,
+		`{"id":"ORD-2","amount":0}`,
+		`{"id":"ORD-3","amount":10}`,
+		`not-json`,
+		`{"id":"ORD-4","amount":10}`,
+		`{"id":"ORD-5","amount":10}`,
+	}, "\n"))
+
+	if code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 2 {
+		t.Fatalf("expected two chunks of two entries, got %+v", chunks)
+	}
+	var event struct {
+		Subject string         `json:"subject"`
+		Data    OrderCreatedV1 `json:"data"`
+	}
+	if err := json.Unmarshal(chunks[0][1].Event, &event); err != nil {
+		t.Fatalf("decode bulk entry event: %v", err)
+	}
+	if entry := chunks[0][1]; entry.EntryID != "1" || entry.ContentType != "application/cloudevents+json" || event.Subject != "ORD-3" || event.Data.EventVersion != "v1" {
+		t.Fatalf("unexpected bulk entry: %+v %s", entry, entry.Event)
+	}
+
+	want := []BatchEntryResult{
+		{Index: 0, OrderID: "ORD-1", Status: BatchEntryAccepted},
+		{Index: 1, OrderID: "ORD-2", Status: BatchEntryRejected, Reason: "amount must be greater than zero"},
+		{Index: 2, OrderID: "ORD-3", Status: BatchEntryAccepted},
+		{Index: 3, Status: BatchEntryRejected, Reason: "invalid entry payload"},
+		{Index: 4, OrderID: "ORD-4", Status: BatchEntryAccepted},
+		{Index: 5, OrderID: "ORD-5", Status: BatchEntryRejected, Reason: "broker rejected message"},
+	}
+	if response.Accepted != 3 || response.Rejected != 3 || len(response.Results) != len(want) {
+		t.Fatalf("unexpected batch summary: %+v", response)
+	}
+	for i := range want {
+		if response.Results[i] != want[i] {
+			t.Fatalf("result %d = %+v, want %+v", i, response.Results[i], want[i])
+		}
+	}
+	if got := counterValue(t, registry, "orders_published_total"); got != 3 {
+		t.Fatalf("published events = %v, want 3", got)
+	}
+}
+
+func TestPublishBatchRejectsChunkWhenSidecarFails(t *testing.T) {
+	t.Parallel()
+
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), "http://dapr.local/publish", WithBulkPublishURL("http://dapr.local/bulk"))
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, service, registry, registry)
+
+	code, response := postBatch(t, router, `[{"id":"ORD-1","amount":10},{"id":"ORD-2","amount":10}]`)
+	if code != http.StatusAccepted || response.Accepted != 0 || response.Rejected != 2 {
+		t.Fatalf("unexpected response %d %+v", code, response)
+	}
+	for _, result := range response.Results {
+		if result.Reason != "failed to publish event" {
+			t.Fatalf("unexpected rejection reason: %+v", result)
+		}
+	}
+
+	if code, _ := postBatch(t, router, ``); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty batch, got %d", code)
+	}
+}
+
+func TestPublishBatchRejectsOversizedBody(t *testing.T) {
+	t.Parallel()
+
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		t.Error("an oversized batch must not reach the sidecar")
+		return nil, errors.New("unexpected call")
+	}), "http://dapr.local/publish", WithBulkPublishURL("http://dapr.local/bulk"))
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{Batch: BatchConfig{MaxBodyBytes: 64}}, service, registry, registry)
+
+	body := strings.Repeat(`{"id":"ORD-1","amount":10}`+"\n", 4)
+	if code, _ := postBatch(t, router, body); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", code)
+	}
+}
+
+func TestBulkPartialFailureIsNotRetried(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	doer, _ := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		attempts++
+		body := `{"failedEntries":[{"entryId":"0","error":"broker rejected message"}],"errorCode":"ERR_PUBSUB_PUBLISH_MESSAGE"}`
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}), ResilienceConfig{MaxAttempts: 3, BreakerFailureThreshold: 1, BreakerOpenTimeout: time.Minute})
+	service := NewService(doer, "http://dapr.local/publish", WithBulkPublishURL("http://dapr.local/bulk"))
+
+	for i := 0; i < 2; i++ {
+		entryErrs, err := service.PublishBulk(context.Background(), []PublishOrderRequest{{ID: "ORD-1", Amount: money.MustParse("1")}})
+		if err != nil || len(entryErrs) != 1 || entryErrs[0] == nil {
+			t.Fatalf("call %d: expected a per-entry failure, got %v, %v", i+1, entryErrs, err)
+		}
+	}
+	if attempts != 2 {
+		t.Fatalf("sidecar attempts = %d, want one per call", attempts)
+	}
+	if state := doer.breaker.currentState(); state != circuitClosed {
+		t.Fatalf("partial failures must not open the breaker, got %s", state)
+	}
+}
+
+func TestPublishBatchInOutboxMode(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		t.Error("outbox mode must not call the sidecar inline")
+		return nil, errors.New("unexpected call")
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, service, registry, registry, WithOutbox(store))
+
+	_, response := postBatch(t, router, `[{"id":"ORD-1","amount":10},{"id":"ORD-1","amount":10}]`)
+	if response.Accepted != 1 || response.Results[1].Reason != "order already accepted" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if record, err := store.Get(context.Background(), "ORD-1"); err != nil || record.Status != OutboxStatusPending {
+		t.Fatalf("expected pending outbox record, got %+v %v", record, err)
+	}
+}
@@ -0,0 +1,178 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// OrderCreatedV1Type is the CloudEvents type of OrderCreatedV1 events.
+	OrderCreatedV1Type = "com.agnostic.order.created.v1"
+	// OrderCreatedV2Type is the CloudEvents type of OrderCreatedV2 events.
+	OrderCreatedV2Type = "com.agnostic.order.created.v2"
+
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+	jsonContentType        = "application/json"
+	correlationIDHeader    = "X-Correlation-ID"
+)
+
+// orderEventNamespace seeds deterministic CloudEvent ids, so consumers can
+// deduplicate republished orders.
+var orderEventNamespace = uuid.MustParse("6f1c9a52-9a4e-4c55-8d0e-2b7c4f1e9d31")
+
+// CloudEventConfig controls how order events are framed. Source is the
+// CloudEvents source attribute; RawPayload publishes the bare event JSON with
+// Dapr's metadata.rawPayload=true for subscribers that are not Dapr.
+type CloudEventConfig struct {
+	Source     string
+	RawPayload bool
+}
+
+// CloudEvent is a structured-mode CloudEvents 1.0 envelope. Extensions are
+// serialised as top-level attributes next to the context attributes.
+type CloudEvent struct {
+	SpecVersion     string
+	ID              string
+	Type            string
+	Source          string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	Data            any
+	Extensions      map[string]string
+}
+
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	document := make(map[string]any, 8+len(e.Extensions))
+	for name, value := range e.Extensions {
+		if value != "" {
+			document[name] = value
+		}
+	}
+	document["specversion"] = e.SpecVersion
+	document["id"] = e.ID
+	document["type"] = e.Type
+	document["source"] = e.Source
+	if e.Subject != "" {
+		document["subject"] = e.Subject
+	}
+	if !e.Time.IsZero() {
+		document["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if e.DataContentType != "" {
+		document["datacontenttype"] = e.DataContentType
+	}
+	document["data"] = e.Data
+	return json.Marshal(document)
+}
+
+// eventEncoder renders order events for the Dapr publish APIs.
+type eventEncoder struct {
+	config     CloudEventConfig
+	propagator propagation.TextMapPropagator
+	now        func() time.Time
+}
+
+func newEventEncoder(resolved options) eventEncoder {
+	config := resolved.cloudEvents
+	if config.Source == "" {
+		config.Source = defaultServiceName
+	}
+	return eventEncoder{config: config, propagator: resolved.propagator, now: time.Now}
+}
+
+// encode returns the publish payload for event and its content type: a
+// structured CloudEvent, or the bare event in raw-payload mode.
+func (e eventEncoder) encode(ctx context.Context, event OrderEvent) ([]byte, string, error) {
+	if e.config.RawPayload {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("encode event: %w", err)
+		}
+		return payload, jsonContentType, nil
+	}
+
+	occurredAt, ok := eventTimeFromContext(ctx)
+	if !ok {
+		occurredAt = e.now()
+	}
+	// publishtime is when the event was handed to Dapr; time stays when the
+	// order was accepted. Consumers measure end-to-end latency from it.
+	extensions := map[string]string{
+		"correlationid": correlationIDFromContext(ctx),
+		"publishtime":   e.now().UTC().Format(time.RFC3339Nano),
+	}
+	carrier := propagation.MapCarrier{}
+	e.propagator.Inject(ctx, carrier)
+	extensions["traceparent"] = carrier.Get("traceparent")
+	extensions["tracestate"] = carrier.Get("tracestate")
+
+	eventType := event.CloudEventType()
+	payload, err := json.Marshal(CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              uuid.NewSHA1(orderEventNamespace, []byte(eventType+"/"+event.OrderID())).String(),
+		Type:            eventType,
+		Source:          e.config.Source,
+		Subject:         event.OrderID(),
+		Time:            occurredAt,
+		DataContentType: jsonContentType,
+		Data:            event,
+		Extensions:      extensions,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("encode event: %w", err)
+	}
+	return payload, cloudEventsContentType, nil
+}
+
+// metadata returns the Dapr publish metadata implied by the encoding mode.
+func (e eventEncoder) metadata() map[string]string {
+	if e.config.RawPayload {
+		return map[string]string{"rawPayload": "true"}
+	}
+	return nil
+}
+
+type correlationIDKey struct{}
+
+type eventTimeKey struct{}
+
+func withCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+func correlationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+// withEventTime pins the CloudEvent time to when the order was accepted, for
+// events published later by the outbox relay.
+func withEventTime(ctx context.Context, at time.Time) context.Context {
+	return context.WithValue(ctx, eventTimeKey{}, at)
+}
+
+func eventTimeFromContext(ctx context.Context) (time.Time, bool) {
+	at, ok := ctx.Value(eventTimeKey{}).(time.Time)
+	return at, ok && !at.IsZero()
+}
+
+// correlationMiddleware reuses the caller's X-Correlation-ID (or generates
+// one), echoes it on the response and makes it available to publishers.
+func correlationMiddleware(c *gin.Context) {
+	correlationID := strings.TrimSpace(c.GetHeader(correlationIDHeader))
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+	c.Header(correlationIDHeader, correlationID)
+	c.Request = c.Request.WithContext(withCorrelationID(c.Request.Context(), correlationID))
+	c.Next()
+}
@@ -0,0 +1,146 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type capturedPublish struct {
+	contentType string
+	query       map[string][]string
+	body        []byte
+}
+
+func newCapturingService(captured *[]capturedPublish, opts ...Option) *Service {
+	return NewService(doerFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		*captured = append(*captured, capturedPublish{
+			contentType: req.Header.Get("Content-Type"),
+			query:       req.URL.Query(),
+			body:        body,
+		})
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish", opts...)
+}
+
+func TestPublishSendsStructuredCloudEvent(t *testing.T) {
+	t.Parallel()
+
+	var captured []capturedPublish
+	opts := []Option{WithCloudEvents(CloudEventConfig{Source: "checkout"}), WithPropagator(NewPropagator())}
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, newCapturingService(&captured, opts...), registry, registry, opts...)
+
+	publish := func(correlationID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		if correlationID != "" {
+			req.Header.Set("X-Correlation-ID", correlationID)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := publish("corr-123")
+	if res.Code != http.StatusAccepted || res.Header().Get("X-Correlation-ID") != "corr-123" {
+		t.Fatalf("expected 202 echoing the correlation id, got %d %q", res.Code, res.Header().Get("X-Correlation-ID"))
+	}
+	if captured[0].contentType != "application/cloudevents+json" {
+		t.Fatalf("content type = %q, want application/cloudevents+json", captured[0].contentType)
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(captured[0].body, &event); err != nil {
+		t.Fatalf("decode cloud event: %v", err)
+	}
+	want := map[string]any{
+		"specversion":     "1.0",
+		"type":            OrderCreatedV1Type,
+		"source":          "checkout",
+		"subject":         "ORD-1",
+		"datacontenttype": "application/json",
+		"correlationid":   "corr-123",
+	}
+	for attribute, value := range want {
+		if event[attribute] != value {
+			t.Fatalf("%s = %v, want %v", attribute, event[attribute], value)
+		}
+	}
+	for _, attribute := range []string{"time", "publishtime"} {
+		if _, err := time.Parse(time.RFC3339Nano, event[attribute].(string)); err != nil {
+			t.Fatalf("%s %v is not RFC 3339: %v", attribute, event[attribute], err)
+		}
+	}
+	if traceparent, _ := event["traceparent"].(string); len(traceparent) != 55 || traceparent[3:35] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected traceparent extension in the caller trace, got %v", event["traceparent"])
+	}
+	if data, _ := event["data"].(map[string]any); data["id"] != "ORD-1" || data["eventVersion"] != "v1" {
+		t.Fatalf("unexpected data: %v", event["data"])
+	}
+
+	res = publish("")
+	if res.Header().Get("X-Correlation-ID") == "" {
+		t.Fatal("expected a generated correlation id")
+	}
+	var republished map[string]any
+	_ = json.Unmarshal(captured[1].body, &republished)
+	if republished["id"] != event["id"] {
+		t.Fatalf("expected a stable event id per order, got %v and %v", event["id"], republished["id"])
+	}
+}
+
+func TestPublishRawPayloadMode(t *testing.T) {
+	t.Parallel()
+
+	var captured []capturedPublish
+	service := newCapturingService(&captured, WithCloudEvents(CloudEventConfig{RawPayload: true}))
+
+	if err := service.Publish(context.Background(), PublishOrderRequest{ID: "ORD-2", Amount: money.MustParse("5")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if captured[0].contentType != "application/json" || captured[0].query["metadata.rawPayload"][0] != "true" {
+		t.Fatalf("unexpected raw publish request: %+v", captured[0])
+	}
+	if string(captured[0].body) != `{"id":"ORD-2","amount":5,"eventVersion":"v1"}` {
+		t.Fatalf("unexpected raw payload: %s", captured[0].body)
+	}
+}
+
+func TestOutboxRelayKeepsAcceptanceTimeAndCorrelation(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	acceptedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := OutboxRecord{OrderID: "ORD-3", Request: PublishOrderRequest{ID: "ORD-3", Amount: money.MustParse("1")}, CorrelationID: "corr-outbox", CreatedAt: acceptedAt, UpdatedAt: acceptedAt}
+	if err := store.Enqueue(context.Background(), record); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	var captured []capturedPublish
+	relay := NewOutboxRelay(store, newCapturingService(&captured), OutboxConfig{}, prometheus.NewRegistry())
+	relay.Drain(context.Background())
+
+	var event map[string]any
+	if err := json.Unmarshal(captured[0].body, &event); err != nil {
+		t.Fatalf("decode cloud event: %v", err)
+	}
+	if event["time"] != "2026-03-01T12:00:00Z" || event["correlationid"] != "corr-outbox" || event["source"] != "producer-gin" {
+		t.Fatalf("unexpected relayed event: %s", captured[0].body)
+	}
+	if publishedAt, err := time.Parse(time.RFC3339Nano, event["publishtime"].(string)); err != nil || !publishedAt.After(acceptedAt) {
+		t.Fatalf("publishtime = %v, want the relay time after %s", event["publishtime"], acceptedAt)
+	}
+}
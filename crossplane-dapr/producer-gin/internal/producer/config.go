@@ -3,29 +3,120 @@ package producer
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/lifecycle"
 )
 
 type Config struct {
-	Port         string
-	PubSubName   string
-	TopicName    string
-	DaprHTTPPort string
+	Port          string
+	ServiceName   string
+	PubSubName    string
+	TopicName     string
+	DaprHTTPPort  string
+	DaprGRPCPort  string
+	DaprTransport string
+	Resilience    ResilienceConfig
+	Outbox        OutboxConfig
+	Idempotency   IdempotencyConfig
+	Batch         BatchConfig
+	CloudEvents   CloudEventConfig
+	EventVersions EventVersions
+	Money         MoneyConfig
+	Health        HealthConfig
+	Shutdown      lifecycle.Config
+	AccessLog     accesslog.Config
 }
 
 func LoadConfigFromEnv() Config {
+	moneyMaxScale := envIntOrDefault("MONEY_MAX_SCALE", defaultMoneyMaxScale)
 	return Config{
-		Port:         envOrDefault("PORT", "8080"),
-		PubSubName:   envOrDefault("DAPR_PUBSUB_NAME", "order-pubsub"),
-		TopicName:    envOrDefault("DAPR_TOPIC_NAME", "orders"),
-		DaprHTTPPort: envOrDefault("DAPR_HTTP_PORT", "3500"),
+		Port:          envOrDefault("PORT", "8080"),
+		ServiceName:   envOrDefault("OTEL_SERVICE_NAME", defaultServiceName),
+		PubSubName:    envOrDefault("DAPR_PUBSUB_NAME", "order-pubsub"),
+		TopicName:     envOrDefault("DAPR_TOPIC_NAME", "orders"),
+		DaprHTTPPort:  envOrDefault("DAPR_HTTP_PORT", "3500"),
+		DaprGRPCPort:  envOrDefault("DAPR_GRPC_PORT", "50001"),
+		DaprTransport: envOrDefault("DAPR_TRANSPORT", "http"),
+		Resilience:    loadResilienceConfigFromEnv(),
+		Outbox: OutboxConfig{
+			Enabled:         envBoolOrDefault("OUTBOX_ENABLED", false),
+			Path:            envOrDefault("OUTBOX_PATH", "/tmp/producer-gin-outbox.db"),
+			PollInterval:    envDurationOrDefault("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:       envIntOrDefault("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts:     envIntOrDefault("OUTBOX_MAX_ATTEMPTS", 10),
+			RetryBackoff:    envDurationOrDefault("OUTBOX_RETRY_BACKOFF", time.Second),
+			MaxRetryBackoff: envDurationOrDefault("OUTBOX_MAX_RETRY_BACKOFF", 5*time.Minute),
+			Retention:       envDurationOrDefault("OUTBOX_RETENTION", 24*time.Hour),
+		},
+		Idempotency: IdempotencyConfig{
+			Store:        envOrDefault("IDEMPOTENCY_STORE", "memory"),
+			StateStore:   envOrDefault("IDEMPOTENCY_STATE_STORE", "idempotency-statestore"),
+			Window:       envDurationOrDefault("IDEMPOTENCY_WINDOW", time.Hour),
+			MaxBodyBytes: int64(envIntOrDefault("IDEMPOTENCY_MAX_BODY_BYTES", defaultIdempotencyMaxBodyBytes)),
+		},
+		EventVersions: envEventVersionsOrDefault("PUBLISH_EVENT_VERSIONS", EventVersionsV1),
+		CloudEvents: CloudEventConfig{
+			Source:     envOrDefault("APP_SERVICE", defaultServiceName),
+			RawPayload: envBoolOrDefault("DAPR_RAW_PAYLOAD", false),
+		},
+		Batch: BatchConfig{
+			ChunkSize:    envIntOrDefault("PUBLISH_BATCH_CHUNK_SIZE", 100),
+			MaxEntries:   envIntOrDefault("PUBLISH_BATCH_MAX_ENTRIES", 10000),
+			MaxBodyBytes: int64(envIntOrDefault("PUBLISH_BATCH_MAX_BODY_BYTES", defaultBatchMaxBodyBytes)),
+		},
+		Money: MoneyConfig{
+			MaxScale: &moneyMaxScale,
+		},
+		Health: HealthConfig{
+			Timeout:    envDurationOrDefault("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			DaprChecks: envBoolOrDefault("HEALTH_DAPR_CHECKS_ENABLED", true),
+		},
+		Shutdown: lifecycle.Config{
+			DrainPeriod: envDurationOrDefault("SHUTDOWN_DRAIN_PERIOD", 5*time.Second),
+			Timeout:     envDurationOrDefault("SHUTDOWN_TIMEOUT", 20*time.Second),
+		},
+		AccessLog: accesslog.LoadConfigFromEnv(),
 	}
 }
 
+func loadResilienceConfigFromEnv() ResilienceConfig {
+	defaults := DefaultResilienceConfig()
+	return ResilienceConfig{
+		MaxAttempts:             envIntOrDefault("DAPR_PUBLISH_MAX_ATTEMPTS", defaults.MaxAttempts),
+		AttemptTimeout:          envDurationOrDefault("DAPR_PUBLISH_ATTEMPT_TIMEOUT", defaults.AttemptTimeout),
+		InitialBackoff:          envDurationOrDefault("DAPR_PUBLISH_INITIAL_BACKOFF", defaults.InitialBackoff),
+		MaxBackoff:              envDurationOrDefault("DAPR_PUBLISH_MAX_BACKOFF", defaults.MaxBackoff),
+		BackoffMultiplier:       defaults.BackoffMultiplier,
+		BreakerFailureThreshold: envIntOrDefault("DAPR_PUBLISH_BREAKER_FAILURE_THRESHOLD", defaults.BreakerFailureThreshold),
+		BreakerOpenTimeout:      envDurationOrDefault("DAPR_PUBLISH_BREAKER_OPEN_TIMEOUT", defaults.BreakerOpenTimeout),
+	}
+}
+
+// DaprBaseURL is the address of the Dapr sidecar HTTP API.
+func (c Config) DaprBaseURL() string {
+	return "http://localhost:" + c.DaprHTTPPort
+}
+
 func (c Config) PublishURL() string {
 	return fmt.Sprintf("http://localhost:%s/v1.0/publish/%s/%s", c.DaprHTTPPort, c.PubSubName, c.TopicName)
 }
 
+func (c Config) BulkPublishURL() string {
+	return fmt.Sprintf("http://localhost:%s/v1.0-alpha1/publish/bulk/%s/%s", c.DaprHTTPPort, c.PubSubName, c.TopicName)
+}
+
+func (c Config) DaprGRPCAddress() string {
+	return "localhost:" + c.DaprGRPCPort
+}
+
+func (c Config) StateURL(storeName string) string {
+	return fmt.Sprintf("http://localhost:%s/v1.0/state/%s", c.DaprHTTPPort, storeName)
+}
+
 func envOrDefault(key, fallback string) string {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
@@ -33,3 +124,55 @@ func envOrDefault(key, fallback string) string {
 	}
 	return value
 }
+
+func envIntOrDefault(key string, fallback int) int {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("ignoring invalid integer environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envBoolOrDefault(key string, fallback bool) bool {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("ignoring invalid boolean environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("ignoring invalid duration environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+func envEventVersionsOrDefault(key string, fallback EventVersions) EventVersions {
+	value := envOrDefault(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := ParseEventVersions(value)
+	if err != nil {
+		logger.Warn("ignoring invalid event versions environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
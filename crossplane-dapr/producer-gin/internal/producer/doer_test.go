@@ -0,0 +1,9 @@
+//go:build !contract && !e2e
+
+package producer
+
+import "net/http"
+
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (d doerFunc) Do(req *http.Request) (*http.Response, error) { return d(req) }
@@ -0,0 +1,32 @@
+package producer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+)
+
+// HealthConfig controls the dependency checks behind /health and the
+// readiness and startup probes.
+type HealthConfig struct {
+	Timeout    time.Duration
+	DaprChecks bool
+}
+
+// NewHealth builds the producer health checks: that the Dapr sidecar is up
+// and that it loaded the pubsub component, plus the idempotency state store
+// when idempotency keys are kept in Dapr.
+func NewHealth(cfg Config, client health.HTTPDoer) *health.Health {
+	h := health.New(health.WithTimeout(cfg.Health.Timeout))
+	if !cfg.Health.DaprChecks {
+		return h
+	}
+	components := []string{cfg.PubSubName}
+	if strings.EqualFold(cfg.Idempotency.Store, "dapr") {
+		components = append(components, cfg.Idempotency.StateStore)
+	}
+	h.Register("daprSidecar", health.DaprSidecar(client, cfg.DaprBaseURL()))
+	h.Register("daprMetadata", health.DaprMetadata(client, cfg.DaprBaseURL(), components, nil))
+	return h
+}
@@ -0,0 +1,104 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/daprfake"
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func getHealth(t *testing.T, router http.Handler, path string) (int, health.Report) {
+	t.Helper()
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+	var report health.Report
+	if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode GET %s %q: %v", path, res.Body.String(), err)
+	}
+	return res.Code, report
+}
+
+func newHealthRouter(t *testing.T, opts ...Option) http.Handler {
+	t.Helper()
+	publisher := NewService(doerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("unexpected publish")
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	return NewRouter(Config{}, publisher, registry, registry, opts...)
+}
+
+func TestHealthEndpointsAreUpWithoutChecks(t *testing.T) {
+	t.Parallel()
+
+	router := newHealthRouter(t)
+	for _, path := range []string{"/health", "/health/live", "/health/ready", "/health/started"} {
+		if code, report := getHealth(t, router, path); code != http.StatusOK || report.Status != health.StatusUp {
+			t.Fatalf("GET %s = %d %+v, want 200 UP", path, code, report)
+		}
+	}
+}
+
+func TestHealthReportsDaprDependencies(t *testing.T) {
+	t.Parallel()
+
+	sidecar := daprfake.New(daprfake.WithComponents(daprfake.Component{Name: "order-pubsub", Type: "pubsub.redis", Version: "v1"}))
+	cfg := Config{
+		PubSubName:   "order-pubsub",
+		DaprHTTPPort: "3500",
+		Idempotency:  IdempotencyConfig{Store: "dapr", StateStore: "idempotency-statestore"},
+		Health:       HealthConfig{DaprChecks: true},
+	}
+	router := newHealthRouter(t, WithHealth(NewHealth(cfg, sidecar)))
+
+	code, report := getHealth(t, router, "/health")
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusDown {
+		t.Fatalf("GET /health = %d %+v, want 503 DOWN", code, report)
+	}
+	if got := report.Components["daprSidecar"].Status; got != health.StatusUp {
+		t.Fatalf("daprSidecar = %s, want UP", got)
+	}
+	metadata := report.Components["daprMetadata"]
+	if metadata.Status != health.StatusDown || metadata.Details["error"] != "dapr components not loaded: idempotency-statestore" {
+		t.Fatalf("daprMetadata = %+v, want the missing state store", metadata)
+	}
+	if _, ok := metadata.Details["latencyMs"]; !ok {
+		t.Fatalf("daprMetadata has no latencyMs: %+v", metadata.Details)
+	}
+	if code, report := getHealth(t, router, "/health/ready"); code != http.StatusServiceUnavailable || report.Components != nil {
+		t.Fatalf("GET /health/ready = %d %+v, want 503 without details", code, report)
+	}
+	if code, _ := getHealth(t, router, "/health/started"); code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /health/started = %d, want 503", code)
+	}
+	if code, _ := getHealth(t, router, "/health/live"); code != http.StatusOK {
+		t.Fatalf("GET /health/live = %d, want 200", code)
+	}
+
+	cfg.Idempotency.Store = "memory"
+	router = newHealthRouter(t, WithHealth(NewHealth(cfg, sidecar)))
+	if code, _ := getHealth(t, router, "/health/started"); code != http.StatusOK {
+		t.Fatalf("GET /health/started = %d, want 200 once the sidecar is ready", code)
+	}
+	sidecar.SetHealthy(false)
+	if code, report := getHealth(t, router, "/health"); code != http.StatusServiceUnavailable || report.Components["daprSidecar"].Status != health.StatusDown {
+		t.Fatalf("GET /health = %d %+v, want the sidecar DOWN", code, report)
+	}
+	if code, _ := getHealth(t, router, "/health/started"); code != http.StatusOK {
+		t.Fatalf("GET /health/started = %d, want 200 to stay latched", code)
+	}
+}
+
+func TestNewHealthWithoutDaprChecks(t *testing.T) {
+	t.Parallel()
+
+	if names := NewHealth(Config{}, http.DefaultClient).Names(); len(names) != 0 {
+		t.Fatalf("checks = %v, want none when dapr checks are disabled", names)
+	}
+}
@@ -0,0 +1,312 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotency-Replayed"
+)
+
+// IdempotencyConfig selects the dedupe backend for POST /publish and how long
+// first responses are remembered. Store is "memory", "dapr" or "none".
+// MaxBodyBytes bounds the request bodies the middleware buffers; zero or less
+// selects defaultIdempotencyMaxBodyBytes.
+type IdempotencyConfig struct {
+	Store        string
+	StateStore   string
+	Window       time.Duration
+	MaxBodyBytes int64
+}
+
+const defaultIdempotencyMaxBodyBytes = 1 << 20
+
+// idempotencyLease bounds how long a replica that dies mid-request blocks
+// retries of its key.
+const idempotencyLease = 30 * time.Second
+
+func (c IdempotencyConfig) maxBodyBytes() int64 {
+	if c.MaxBodyBytes <= 0 {
+		return defaultIdempotencyMaxBodyBytes
+	}
+	return c.MaxBodyBytes
+}
+
+// NewIdempotencyStore builds the store selected by cfg.Idempotency.Store. It
+// returns nil when idempotency is disabled.
+func NewIdempotencyStore(cfg Config, httpClient HTTPDoer) (IdempotencyStore, error) {
+	switch strings.ToLower(cfg.Idempotency.Store) {
+	case "", "memory":
+		return NewMemoryIdempotencyStore(), nil
+	case "dapr":
+		return NewDaprIdempotencyStore(httpClient, cfg.StateURL(cfg.Idempotency.StateStore)), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported idempotency store %q", cfg.Idempotency.Store)
+	}
+}
+
+// IdempotencyRecord is the first response seen for an idempotency key. A zero
+// StatusCode means the first request is still being processed.
+type IdempotencyRecord struct {
+	Key         string          `json:"key"`
+	Fingerprint string          `json:"fingerprint"`
+	StatusCode  int             `json:"statusCode"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// IdempotencyStore remembers publish responses per idempotency key.
+// Implementations must make Reserve atomic across all producer replicas that
+// share the store.
+type IdempotencyStore interface {
+	// Reserve claims record.Key for ttl. When the key is already taken the
+	// stored record is returned with claimed set to false.
+	Reserve(ctx context.Context, record IdempotencyRecord, ttl time.Duration) (existing IdempotencyRecord, claimed bool, err error)
+	// Complete stores the final response for a reserved key.
+	Complete(ctx context.Context, record IdempotencyRecord, ttl time.Duration) error
+	// Release forgets a reserved key so the client can retry.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore for single-replica
+// deployments. Expired keys are swept lazily on Reserve.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: map[string]memoryIdempotencyEntry{}, now: time.Now}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now, ttl)
+	if entry, ok := s.entries[record.Key]; ok && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+	s.entries[record.Key] = memoryIdempotencyEntry{record: record, expiresAt: now.Add(ttl)}
+	return IdempotencyRecord{}, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, record IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[record.Key] = memoryIdempotencyEntry{record: record, expiresAt: record.CreatedAt.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(s.lastSweep) < interval {
+		return
+	}
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// idempotency replays the first response for a repeated Idempotency-Key,
+// or order id when the header is absent.
+type idempotency struct {
+	store        IdempotencyStore
+	window       time.Duration
+	maxBodyBytes int64
+	now          func() time.Time
+
+	replays   prometheus.Counter
+	conflicts *prometheus.CounterVec
+}
+
+func newIdempotency(store IdempotencyStore, window time.Duration, maxBodyBytes int64, registerer prometheus.Registerer) *idempotency {
+	if window <= 0 {
+		window = time.Hour
+	}
+	middleware := &idempotency{
+		store:        store,
+		window:       window,
+		maxBodyBytes: maxBodyBytes,
+		now:          time.Now,
+		replays: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_publish_duplicates_suppressed_total",
+			Help: "Total duplicate publish requests answered from the idempotency store in producer-gin.",
+		}),
+		conflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_publish_idempotency_conflicts_total",
+			Help: "Total publish requests rejected for idempotency key conflicts in producer-gin by reason.",
+		}, []string{"reason"}),
+	}
+	registerer.MustRegister(middleware.replays, middleware.conflicts)
+	return middleware
+}
+
+func (m *idempotency) handle(c *gin.Context) {
+	requestLogger := loggerFromGinContext(c)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, m.maxBodyBytes)
+	payload, err := c.GetRawData()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		requestLogger.WarnContext(c.Request.Context(), "publish request body too large", "limit", tooLarge.Limit)
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit)})
+		return
+	}
+	if err != nil {
+		c.Next()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+
+	key, fingerprint, ok := idempotencyIdentity(c.Request, payload)
+	if !ok {
+		// Malformed payloads are rejected by the handler and never stored.
+		c.Next()
+		return
+	}
+
+	ctx := c.Request.Context()
+	record := IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: m.now().UTC()}
+	existing, claimed, err := m.store.Reserve(ctx, record, min(idempotencyLease, m.window))
+	if err != nil {
+		requestLogger.ErrorContext(c.Request.Context(), "idempotency store unavailable", "idempotencyKey", key, "error", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "idempotency store unavailable"})
+		return
+	}
+
+	if !claimed {
+		m.replay(c, existing, fingerprint)
+		return
+	}
+
+	writer := &capturingResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	completed := false
+	defer func() {
+		// Server-side failures and panics are retryable, so the key must not
+		// pin them. This also runs while a panic unwinds to gin.Recovery.
+		if completed {
+			return
+		}
+		if err := m.store.Release(context.WithoutCancel(ctx), key); err != nil {
+			requestLogger.WarnContext(c.Request.Context(), "failed to release idempotency key", "idempotencyKey", key, "error", err)
+		}
+	}()
+	c.Next()
+
+	status := writer.Status()
+	if status >= http.StatusInternalServerError {
+		return
+	}
+
+	completed = true
+	record.StatusCode = status
+	record.Body = json.RawMessage(writer.body.Bytes())
+	if err := m.store.Complete(ctx, record, m.window); err != nil {
+		requestLogger.WarnContext(c.Request.Context(), "failed to store idempotent response", "idempotencyKey", key, "error", err)
+	}
+}
+
+func (m *idempotency) replay(c *gin.Context, existing IdempotencyRecord, fingerprint string) {
+	requestLogger := loggerFromGinContext(c)
+
+	if existing.Fingerprint != fingerprint {
+		m.conflicts.WithLabelValues("payload_mismatch").Inc()
+		requestLogger.WarnContext(c.Request.Context(), "idempotency key reused with a different payload", "idempotencyKey", existing.Key)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key reused with a different payload"})
+		return
+	}
+	if existing.StatusCode == 0 {
+		m.conflicts.WithLabelValues("in_progress").Inc()
+		requestLogger.WarnContext(c.Request.Context(), "idempotent request still in progress", "idempotencyKey", existing.Key)
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this idempotency key is in progress"})
+		return
+	}
+
+	m.replays.Inc()
+	requestLogger.InfoContext(c.Request.Context(), "replaying idempotent publish response", "idempotencyKey", existing.Key, "statusCode", existing.StatusCode)
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Body)
+	c.Abort()
+}
+
+// idempotencyIdentity returns the dedupe key and a payload fingerprint that
+// ignores formatting and field order.
+func idempotencyIdentity(r *http.Request, payload []byte) (string, string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return "", "", false
+	}
+	canonical, err := json.Marshal(document)
+	if err != nil {
+		return "", "", false
+	}
+	sum := sha256.Sum256(canonical)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	if key == "" {
+		fields, ok := document.(map[string]any)
+		if !ok {
+			return "", "", false
+		}
+		orderID, _ := fields["id"].(string)
+		if strings.TrimSpace(orderID) == "" {
+			return "", "", false
+		}
+		key = "order:" + orderID
+	}
+	return r.URL.Path + ":" + key, fingerprint, true
+}
+
+type capturingResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingResponseWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,152 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DaprIdempotencyStore keeps idempotency records in a Dapr state store so
+// every producer replica sees the same keys. Reserve relies on first-write
+// concurrency: a save without an ETag only succeeds when the key is absent.
+type DaprIdempotencyStore struct {
+	httpClient HTTPDoer
+	stateURL   string
+}
+
+func NewDaprIdempotencyStore(httpClient HTTPDoer, stateURL string) *DaprIdempotencyStore {
+	return &DaprIdempotencyStore{httpClient: httpClient, stateURL: stateURL}
+}
+
+type daprStateItem struct {
+	Key      string            `json:"key"`
+	Value    IdempotencyRecord `json:"value"`
+	Options  *daprStateOptions `json:"options,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type daprStateOptions struct {
+	Concurrency string `json:"concurrency"`
+}
+
+// maxReserveAttempts bounds how often Reserve retries when the competing
+// record expires between the conflicting save and the read.
+const maxReserveAttempts = 3
+
+func (s *DaprIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	for range maxReserveAttempts {
+		status, err := s.save(ctx, daprStateItem{
+			Key:      record.Key,
+			Value:    record,
+			Options:  &daprStateOptions{Concurrency: "first-write"},
+			Metadata: ttlMetadata(ttl),
+		})
+		if err != nil {
+			return IdempotencyRecord{}, false, err
+		}
+		switch {
+		case status >= 200 && status <= 299:
+			return IdempotencyRecord{}, true, nil
+		case status == http.StatusConflict:
+			existing, found, err := s.get(ctx, record.Key)
+			if err != nil {
+				return IdempotencyRecord{}, false, err
+			}
+			if found {
+				return existing, false, nil
+			}
+			// The competing record expired between the save and the read.
+		default:
+			return IdempotencyRecord{}, false, fmt.Errorf("reserve idempotency key: state store returned status %d", status)
+		}
+	}
+	return IdempotencyRecord{}, false, fmt.Errorf("reserve idempotency key: state store kept rejecting the key without returning it after %d attempts", maxReserveAttempts)
+}
+
+func (s *DaprIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord, ttl time.Duration) error {
+	status, err := s.save(ctx, daprStateItem{Key: record.Key, Value: record, Metadata: ttlMetadata(ttl)})
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("complete idempotency key: state store returned status %d", status)
+	}
+	return nil
+}
+
+func (s *DaprIdempotencyStore) Release(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.keyURL(key), nil)
+	if err != nil {
+		return fmt.Errorf("create state delete request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("state delete request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("release idempotency key: state store returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *DaprIdempotencyStore) save(ctx context.Context, item daprStateItem) (int, error) {
+	payload, err := json.Marshal([]daprStateItem{item})
+	if err != nil {
+		return 0, fmt.Errorf("encode state item: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.stateURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("create state save request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("state save request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (s *DaprIdempotencyStore) get(ctx context.Context, key string) (IdempotencyRecord, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.keyURL(key), nil)
+	if err != nil {
+		return IdempotencyRecord{}, false, fmt.Errorf("create state get request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return IdempotencyRecord{}, false, fmt.Errorf("state get request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound:
+		return IdempotencyRecord{}, false, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return IdempotencyRecord{}, false, fmt.Errorf("read idempotency key: state store returned status %d", resp.StatusCode)
+	}
+
+	var record IdempotencyRecord
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return IdempotencyRecord{}, false, fmt.Errorf("decode idempotency record: %w", err)
+	}
+	return record, true, nil
+}
+
+func (s *DaprIdempotencyStore) keyURL(key string) string {
+	return s.stateURL + "/" + url.PathEscape(key)
+}
+
+func ttlMetadata(ttl time.Duration) map[string]string {
+	seconds := int(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return map[string]string{"ttlInSeconds": strconv.Itoa(seconds)}
+}
@@ -0,0 +1,326 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newIdempotentRouter(t *testing.T, publishStatus *int) (http.Handler, *int, *prometheus.Registry) {
+	t.Helper()
+	calls := 0
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: *publishStatus, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, service, registry, registry, WithIdempotency(NewMemoryIdempotencyStore(), time.Minute))
+	return router, &calls, registry
+}
+
+func postPublish(router http.Handler, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestPublishIdempotency(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		first       string
+		second      string
+		key         string
+		wantStatus  int
+		wantCalls   int
+		wantReplays float64
+	}{
+		{name: "replays header key", first: `{"id":"ORD-1","amount":10}`, second: `{"amount":10, "id":"ORD-1"}`, key: "key-1", wantStatus: http.StatusAccepted, wantCalls: 1, wantReplays: 1},
+		{name: "falls back to order id", first: `{"id":"ORD-2","amount":10}`, second: `{"id":"ORD-2","amount":10}`, wantStatus: http.StatusAccepted, wantCalls: 1, wantReplays: 1},
+		{name: "rejects reused key with different payload", first: `{"id":"ORD-3","amount":10}`, second: `{"id":"ORD-3","amount":11}`, key: "key-3", wantStatus: http.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "distinct keys publish twice", first: `{"id":"ORD-4","amount":10}`, second: `{"id":"ORD-5","amount":10}`, wantStatus: http.StatusAccepted, wantCalls: 2},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			publishStatus := http.StatusNoContent
+			router, calls, registry := newIdempotentRouter(t, &publishStatus)
+
+			first := postPublish(router, tc.first, tc.key)
+			second := postPublish(router, tc.second, tc.key)
+
+			if first.Code != http.StatusAccepted {
+				t.Fatalf("first status = %d, want 202", first.Code)
+			}
+			if second.Code != tc.wantStatus {
+				t.Fatalf("second status = %d, want %d", second.Code, tc.wantStatus)
+			}
+			if *calls != tc.wantCalls {
+				t.Fatalf("sidecar calls = %d, want %d", *calls, tc.wantCalls)
+			}
+			if tc.wantReplays > 0 {
+				if second.Header().Get("Idempotency-Replayed") != "true" || second.Body.String() != first.Body.String() {
+					t.Fatalf("expected replay of %q, got %q", first.Body.String(), second.Body.String())
+				}
+			}
+			if got := counterValue(t, registry, "orders_publish_duplicates_suppressed_total"); got != tc.wantReplays {
+				t.Fatalf("duplicates suppressed = %v, want %v", got, tc.wantReplays)
+			}
+		})
+	}
+}
+
+func TestPublishIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	t.Parallel()
+
+	publishStatus := http.StatusInternalServerError
+	router, calls, _ := newIdempotentRouter(t, &publishStatus)
+
+	if res := postPublish(router, `{"id":"ORD-9","amount":10}`, "key-9"); res.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", res.Code)
+	}
+	publishStatus = http.StatusNoContent
+	res := postPublish(router, `{"id":"ORD-9","amount":10}`, "key-9")
+	if res.Code != http.StatusAccepted || res.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("expected fresh publish after server error, got %d replayed=%q", res.Code, res.Header().Get("Idempotency-Replayed"))
+	}
+	if *calls != 2 {
+		t.Fatalf("sidecar calls = %d, want 2", *calls)
+	}
+}
+
+func TestPublishIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			panic("sidecar client bug")
+		}
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, service, registry, registry, WithIdempotency(NewMemoryIdempotencyStore(), time.Hour))
+
+	if res := postPublish(router, `{"id":"ORD-10","amount":10}`, "key-10"); res.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 from the recovered panic, got %d", res.Code)
+	}
+	if res := postPublish(router, `{"id":"ORD-10","amount":10}`, "key-10"); res.Code != http.StatusAccepted {
+		t.Fatalf("expected a fresh publish after the panic, got %d", res.Code)
+	}
+}
+
+func TestPublishIdempotencyLeasesInFlightKeys(t *testing.T) {
+	t.Parallel()
+
+	// Records are stamped with the wall clock, so the store clock starts there.
+	now := time.Now()
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+	var router http.Handler
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		// A request still in flight after the lease no longer holds the key.
+		if res := postPublish(router, `{"id":"ORD-11","amount":10}`, "key-11"); res.Code != http.StatusConflict {
+			t.Errorf("in-flight duplicate status = %d, want 409", res.Code)
+		}
+		now = now.Add(idempotencyLease)
+		if _, claimed, _ := store.Reserve(context.Background(), IdempotencyRecord{Key: "/publish:key-11"}, time.Second); !claimed {
+			t.Error("expected the lease of the in-flight request to expire")
+		}
+		_ = store.Release(context.Background(), "/publish:key-11")
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router = NewRouter(Config{}, service, registry, registry, WithIdempotency(store, time.Hour))
+
+	if res := postPublish(router, `{"id":"ORD-11","amount":10}`, "key-11"); res.Code != http.StatusAccepted {
+		t.Fatalf("first status = %d, want 202", res.Code)
+	}
+	// Completing the request keeps the response for the whole window.
+	now = now.Add(30 * time.Minute)
+	if res := postPublish(router, `{"id":"ORD-11","amount":10}`, "key-11"); res.Header().Get("Idempotency-Replayed") != "true" {
+		t.Fatalf("expected a replay within the window, got %d", res.Code)
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiresKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	record := IdempotencyRecord{Key: "k", Fingerprint: "f", CreatedAt: now}
+	if _, claimed, _ := store.Reserve(ctx, record, time.Minute); !claimed {
+		t.Fatal("expected first reserve to claim the key")
+	}
+	if _, claimed, _ := store.Reserve(ctx, record, time.Minute); claimed {
+		t.Fatal("expected second reserve within the window to be rejected")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, claimed, _ := store.Reserve(ctx, record, time.Minute); !claimed {
+		t.Fatal("expected reserve after the window to claim the key again")
+	}
+}
+
+// fakeDaprStateStore emulates the Dapr state HTTP API with first-write
+// concurrency for inserts.
+func fakeDaprStateStore() HTTPDoer {
+	var mu sync.Mutex
+	state := map[string][]byte{}
+	return doerFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		respond := func(status int, body []byte) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(body))}, nil
+		}
+
+		key := strings.TrimPrefix(req.URL.Path, "/v1.0/state/idempotency/")
+		switch req.Method {
+		case http.MethodPost:
+			var items []struct {
+				Key     string          `json:"key"`
+				Value   json.RawMessage `json:"value"`
+				Options *struct {
+					Concurrency string `json:"concurrency"`
+				} `json:"options"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&items); err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				if _, exists := state[item.Key]; exists && item.Options != nil && item.Options.Concurrency == "first-write" {
+					return respond(http.StatusConflict, nil)
+				}
+				state[item.Key] = item.Value
+			}
+			return respond(http.StatusNoContent, nil)
+		case http.MethodGet:
+			value, ok := state[key]
+			if !ok {
+				return respond(http.StatusNoContent, nil)
+			}
+			return respond(http.StatusOK, value)
+		case http.MethodDelete:
+			delete(state, key)
+			return respond(http.StatusNoContent, nil)
+		}
+		return nil, errors.New("unexpected method " + req.Method)
+	})
+}
+
+func TestDaprIdempotencyStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewDaprIdempotencyStore(fakeDaprStateStore(), "http://localhost:3500/v1.0/state/idempotency")
+	record := IdempotencyRecord{Key: "publish-key", Fingerprint: "abc", CreatedAt: time.Now().UTC()}
+
+	if _, claimed, err := store.Reserve(ctx, record, time.Minute); err != nil || !claimed {
+		t.Fatalf("expected claim, got claimed=%v err=%v", claimed, err)
+	}
+
+	record.StatusCode = http.StatusAccepted
+	record.Body = json.RawMessage(`{"status":"accepted"}`)
+	if err := store.Complete(ctx, record, time.Minute); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	existing, claimed, err := store.Reserve(ctx, IdempotencyRecord{Key: "publish-key", Fingerprint: "abc"}, time.Minute)
+	if err != nil || claimed {
+		t.Fatalf("expected existing record, got claimed=%v err=%v", claimed, err)
+	}
+	if existing.StatusCode != http.StatusAccepted || string(existing.Body) != `{"status":"accepted"}` {
+		t.Fatalf("unexpected stored record: %+v", existing)
+	}
+
+	if err := store.Release(ctx, "publish-key"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, claimed, err := store.Reserve(ctx, record, time.Minute); err != nil || !claimed {
+		t.Fatalf("expected claim after release, got claimed=%v err=%v", claimed, err)
+	}
+}
+
+func TestDaprIdempotencyStoreBoundsReserveRetries(t *testing.T) {
+	t.Parallel()
+
+	// The key is taken on every save yet gone on every read, as when records
+	// keep expiring in between.
+	saves := 0
+	store := NewDaprIdempotencyStore(doerFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusNoContent
+		if req.Method == http.MethodPost {
+			saves++
+			status = http.StatusConflict
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	}), "http://localhost:3500/v1.0/state/idempotency")
+
+	_, claimed, err := store.Reserve(context.Background(), IdempotencyRecord{Key: "publish-key"}, time.Minute)
+	if err == nil || claimed {
+		t.Fatalf("expected an error, got claimed=%v err=%v", claimed, err)
+	}
+	if saves != maxReserveAttempts {
+		t.Fatalf("saves = %d, want %d", saves, maxReserveAttempts)
+	}
+}
+
+func TestPublishIdempotencyRejectsOversizedBodies(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	cfg := Config{Idempotency: IdempotencyConfig{MaxBodyBytes: 64}}
+	router := NewRouter(cfg, service, registry, registry, WithIdempotency(NewMemoryIdempotencyStore(), time.Minute))
+
+	if res := postPublish(router, `{"id":"ORD-1","amount":10}`, ""); res.Code != http.StatusAccepted {
+		t.Fatalf("small body = %d, want 202", res.Code)
+	}
+	res := postPublish(router, `{"id":"ORD-2","amount":10,"note":"`+strings.Repeat("x", 64)+`"}`, "")
+	if res.Code != http.StatusRequestEntityTooLarge || calls != 1 {
+		t.Fatalf("oversized body = %d after %d publishes, want 413 without publishing", res.Code, calls)
+	}
+}
+
+func counterValue(t *testing.T, gatherer prometheus.Gatherer, name string) float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("metric %s not registered", name)
+	return 0
+}
@@ -4,18 +4,19 @@ package producer
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/daprfake"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type doerFunc func(req *http.Request) (*http.Response, error)
-
-func (d doerFunc) Do(req *http.Request) (*http.Response, error) { return d(req) }
-
 func TestIntegrationPublishEndpoint(t *testing.T) {
 	t.Parallel()
 
@@ -50,3 +51,80 @@ func TestIntegrationPublishEndpoint(t *testing.T) {
 		t.Fatalf("expected publish metric in payload")
 	}
 }
+
+func TestIntegrationPublishThroughDaprSidecar(t *testing.T) {
+	t.Parallel()
+
+	sidecar := daprfake.New(daprfake.WithAppID("producer-gin"))
+	var mu sync.Mutex
+	var delivered []map[string]any
+	subscriber := http.NewServeMux()
+	subscriber.HandleFunc("GET /dapr/subscribe", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `[{"pubsubname":"order-pubsub","topic":"orders","route":"/orders"}]`)
+	})
+	subscriber.HandleFunc("POST /orders", func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode delivered event: %v", err)
+		}
+		mu.Lock()
+		delivered = append(delivered, event)
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"status":"SUCCESS"}`)
+	})
+	if err := sidecar.Subscribe(subscriber); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	cfg := Config{
+		PubSubName:    "order-pubsub",
+		TopicName:     "orders",
+		DaprHTTPPort:  "3500",
+		Resilience:    DefaultResilienceConfig(),
+		EventVersions: EventVersionsBoth,
+	}
+	registry := prometheus.NewRegistry()
+	publisher, closePublisher, err := NewPublisher(cfg, sidecar, registry)
+	if err != nil {
+		t.Fatalf("create publisher: %v", err)
+	}
+	defer closePublisher()
+	router := NewRouter(cfg, publisher, registry, registry)
+
+	for path, body := range map[string]string{
+		"/publish":       `{"id":"ORD-1","amount":10}`,
+		"/v2/publish":    `{"id":"ORD-2","amount":20,"currency":"EUR","customerId":"C-1","lineItems":[{"sku":"SKU-1","quantity":2,"unitPrice":10}],"createdAt":"2026-03-01T12:00:00Z"}`,
+		"/publish/batch": `[{"id":"ORD-3","amount":30},{"id":"ORD-4","amount":40}]`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code < 200 || res.Code > 299 {
+			t.Fatalf("POST %s = %d %s", path, res.Code, res.Body.String())
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sidecar.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[string][]string{}
+	for _, event := range delivered {
+		if event["source"] != "producer-gin" || event["topic"] != "orders" || event["pubsubname"] != "order-pubsub" {
+			t.Fatalf("unexpected delivered event: %v", event)
+		}
+		subject, _ := event["subject"].(string)
+		types[subject] = append(types[subject], event["type"].(string))
+	}
+	want := map[string]int{"ORD-1": 1, "ORD-2": 2, "ORD-3": 1, "ORD-4": 1}
+	for orderID, count := range want {
+		if len(types[orderID]) != count {
+			t.Fatalf("%s delivered as %v, want %d events", orderID, types[orderID], count)
+		}
+	}
+	if len(delivered) != 5 {
+		t.Fatalf("delivered %d events, want 5", len(delivered))
+	}
+}
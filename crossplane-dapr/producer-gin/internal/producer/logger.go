@@ -5,35 +5,32 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/logging"
 	"github.com/gin-gonic/gin"
 )
 
-var logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-	Level: resolveLogLevel(),
-}))
+var logger = newLogger()
 
 const requestLoggerKey = "requestLogger"
 
 type contextLoggerKey struct{}
 
-func resolveLogLevel() slog.Level {
-	switch strings.ToUpper(strings.TrimSpace(os.Getenv("LOG_LEVEL"))) {
-	case "DEBUG":
-		return slog.LevelDebug
-	case "WARN", "WARNING":
-		return slog.LevelWarn
-	case "ERROR":
-		return slog.LevelError
-	default:
-		return slog.LevelInfo
+// newLogger writes to stdout as configured by logging.LoadConfigFromEnv.
+func newLogger() *slog.Logger {
+	cfg, err := logging.LoadConfigFromEnv()
+	logger := logging.New(os.Stdout, cfg)
+	if err != nil {
+		logger.Warn("ignoring invalid LOG_FORMAT, logging as text", "error", err)
 	}
+	return logger
 }
 
 // Logger returns the package-level slog.Logger configured with the LOG_LEVEL
-// environment variable. Call slog.SetDefault(producer.Logger()) in main to
-// ensure startup/shutdown logs respect the same level as request logs.
+// and LOG_FORMAT environment variables. Call slog.SetDefault(producer.Logger())
+// in main to ensure startup/shutdown logs match the request logs.
 func Logger() *slog.Logger {
 	return logger
 }
@@ -60,54 +57,27 @@ func loggerFromContext(ctx context.Context) *slog.Logger {
 	return logger
 }
 
-func requestTraceContext(r *http.Request) (string, string) {
-	if traceID, spanID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
-		return traceID, spanID
-	}
-
-	traceID := strings.TrimSpace(r.Header.Get("x-b3-traceid"))
-	if traceID == "" {
-		traceID = strings.TrimSpace(r.Header.Get("x-request-id"))
-	}
-	if traceID == "" {
-		traceID = "unknown"
-	}
-
-	spanID := strings.TrimSpace(r.Header.Get("x-b3-spanid"))
-	if spanID == "" {
-		spanID = "unknown"
-	}
-
-	return strings.ToLower(traceID), strings.ToLower(spanID)
-}
-
-func parseTraceparent(header string) (string, string, bool) {
-	parts := strings.Split(strings.TrimSpace(header), "-")
-	if len(parts) != 4 {
-		return "", "", false
-	}
-
-	traceID := parts[1]
-	spanID := parts[2]
-	if len(traceID) != 32 || len(spanID) != 16 || !isHex(traceID) || !isHex(spanID) {
-		return "", "", false
-	}
-	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
-		return "", "", false
-	}
-
-	return strings.ToLower(traceID), strings.ToLower(spanID), true
+// newRequestLogger tags logs with the request. Trace ids come from the
+// context passed to the *Context logging methods.
+func newRequestLogger(r *http.Request) *slog.Logger {
+	return logger.With("http_method", r.Method, "http_path", r.URL.Path)
 }
 
-func isHex(value string) bool {
-	for _, char := range value {
-		switch {
-		case char >= '0' && char <= '9':
-		case char >= 'a' && char <= 'f':
-		case char >= 'A' && char <= 'F':
-		default:
-			return false
-		}
+// accessLogMiddleware must run inside the server span for the record to
+// carry its trace ids.
+func accessLogMiddleware(accessLog *accesslog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		body := accessLog.CaptureBody(c.Request)
+		c.Next()
+		accessLog.Log(c.Request.Context(), loggerFromGinContext(c), accesslog.Entry{
+			Request:  c.Request,
+			Route:    c.FullPath(),
+			Status:   c.Writer.Status(),
+			Latency:  time.Since(start),
+			Bytes:    c.Writer.Size(),
+			ClientIP: c.ClientIP(),
+			Body:     body,
+		})
 	}
-	return true
 }
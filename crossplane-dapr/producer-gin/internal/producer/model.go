@@ -2,26 +2,153 @@ package producer
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
 )
 
+// OrderEvent is an order event payload that a Publisher can publish.
+type OrderEvent interface {
+	OrderID() string
+	Version() string
+	CloudEventType() string
+}
+
 type PublishOrderRequest struct {
-	ID     string  `json:"id"`
-	Amount float64 `json:"amount"`
+	ID     string      `json:"id"`
+	Amount money.Money `json:"amount"`
 }
 
 type OrderCreatedV1 struct {
-	ID           string  `json:"id"`
-	Amount       float64 `json:"amount"`
-	EventVersion string  `json:"eventVersion"`
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	EventVersion string      `json:"eventVersion"`
+}
+
+// LineItem is one ordered product in an OrderCreatedV2 event.
+type LineItem struct {
+	SKU       string      `json:"sku"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unitPrice"`
+}
+
+// PublishOrderV2Request is the POST /v2/publish payload. Currency is the
+// ISO 4217 alphabetic code of a currency in circulation.
+type PublishOrderV2Request struct {
+	ID         string     `json:"id"`
+	Currency   string     `json:"currency"`
+	CustomerID string     `json:"customerId"`
+	LineItems  []LineItem `json:"lineItems"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// OrderCreatedV2 extends OrderCreatedV1 with the order currency, line items,
+// customer and creation time. Amount is the order total.
+type OrderCreatedV2 struct {
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	Currency     string      `json:"currency"`
+	CustomerID   string      `json:"customerId"`
+	LineItems    []LineItem  `json:"lineItems"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	EventVersion string      `json:"eventVersion"`
 }
 
-func (r PublishOrderRequest) Validate() error {
+// Validate checks the request; amounts may have at most maxScale decimal
+// places and must not have a negative scale, as in 1E+3.
+func (r PublishOrderRequest) Validate(maxScale int) error {
 	if strings.TrimSpace(r.ID) == "" {
 		return errors.New("id must not be blank")
 	}
-	if r.Amount <= 0 {
+	if r.Amount.Sign() <= 0 {
 		return errors.New("amount must be greater than zero")
 	}
+	if r.Amount.Scale() > maxScale {
+		return fmt.Errorf("amount must not have more than %d decimal places", maxScale)
+	}
+	if r.Amount.Scale() < 0 {
+		return errors.New("amount must not have a negative scale")
+	}
+	return nil
+}
+
+// Event returns the OrderCreatedV1 event for the request.
+func (r PublishOrderRequest) Event() OrderCreatedV1 {
+	return OrderCreatedV1{ID: r.ID, Amount: r.Amount, EventVersion: "v1"}
+}
+
+// Validate checks the request; unit prices may have at most maxScale decimal
+// places and must not have a negative scale.
+func (r PublishOrderV2Request) Validate(maxScale int) error {
+	if strings.TrimSpace(r.ID) == "" {
+		return errors.New("id must not be blank")
+	}
+	if !isCurrencyCode(r.Currency) {
+		return errors.New("currency must be an ISO 4217 code")
+	}
+	if strings.TrimSpace(r.CustomerID) == "" {
+		return errors.New("customerId must not be blank")
+	}
+	if len(r.LineItems) == 0 {
+		return errors.New("lineItems must not be empty")
+	}
+	for i, item := range r.LineItems {
+		if strings.TrimSpace(item.SKU) == "" {
+			return fmt.Errorf("lineItems[%d].sku must not be blank", i)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("lineItems[%d].quantity must be greater than zero", i)
+		}
+		if item.UnitPrice.Sign() <= 0 {
+			return fmt.Errorf("lineItems[%d].unitPrice must be greater than zero", i)
+		}
+		if item.UnitPrice.Scale() > maxScale {
+			return fmt.Errorf("lineItems[%d].unitPrice must not have more than %d decimal places", i, maxScale)
+		}
+		if item.UnitPrice.Scale() < 0 {
+			return fmt.Errorf("lineItems[%d].unitPrice must not have a negative scale", i)
+		}
+	}
+	if r.CreatedAt.IsZero() {
+		return errors.New("createdAt must be set")
+	}
 	return nil
 }
+
+// Total returns the exact sum of the line item prices.
+func (r PublishOrderV2Request) Total() money.Money {
+	var total money.Money
+	for _, item := range r.LineItems {
+		total = total.Add(item.UnitPrice.MulInt(int64(item.Quantity)))
+	}
+	return total
+}
+
+// EventV1 returns the OrderCreatedV1 event for consumers that have not
+// migrated to V2 yet.
+func (r PublishOrderV2Request) EventV1() OrderCreatedV1 {
+	return OrderCreatedV1{ID: r.ID, Amount: r.Total(), EventVersion: "v1"}
+}
+
+// EventV2 returns the OrderCreatedV2 event for the request.
+func (r PublishOrderV2Request) EventV2() OrderCreatedV2 {
+	return OrderCreatedV2{
+		ID:           r.ID,
+		Amount:       r.Total(),
+		Currency:     r.Currency,
+		CustomerID:   r.CustomerID,
+		LineItems:    r.LineItems,
+		CreatedAt:    r.CreatedAt.UTC(),
+		EventVersion: "v2",
+	}
+}
+
+func (e OrderCreatedV1) OrderID() string        { return e.ID }
+func (e OrderCreatedV1) Version() string        { return "v1" }
+func (e OrderCreatedV1) CloudEventType() string { return OrderCreatedV1Type }
+
+func (e OrderCreatedV2) OrderID() string        { return e.ID }
+func (e OrderCreatedV2) Version() string        { return "v2" }
+func (e OrderCreatedV2) CloudEventType() string { return OrderCreatedV2Type }
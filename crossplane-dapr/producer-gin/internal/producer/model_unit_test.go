@@ -2,7 +2,16 @@
 
 package producer
 
-import "testing"
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 func TestPublishOrderRequestValidate(t *testing.T) {
 	t.Parallel()
@@ -12,16 +21,19 @@ func TestPublishOrderRequestValidate(t *testing.T) {
 		request PublishOrderRequest
 		wantErr bool
 	}{
-		{name: "valid", request: PublishOrderRequest{ID: "ORD-1", Amount: 10}, wantErr: false},
-		{name: "blank id", request: PublishOrderRequest{ID: "  ", Amount: 10}, wantErr: true},
-		{name: "zero amount", request: PublishOrderRequest{ID: "ORD-1", Amount: 0}, wantErr: true},
+		{name: "valid", request: PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("10")}, wantErr: false},
+		{name: "blank id", request: PublishOrderRequest{ID: "  ", Amount: money.MustParse("10")}, wantErr: true},
+		{name: "zero amount", request: PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("0")}, wantErr: true},
+		{name: "amount at max scale", request: PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("55.10")}, wantErr: false},
+		{name: "amount beyond max scale", request: PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("55.105")}, wantErr: true},
+		{name: "amount with negative scale", request: PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("1E+3")}, wantErr: true},
 	}
 
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			err := tc.request.Validate()
+			err := tc.request.Validate(2)
 			if tc.wantErr && err == nil {
 				t.Fatalf("expected validation error")
 			}
@@ -32,6 +44,59 @@ func TestPublishOrderRequestValidate(t *testing.T) {
 	}
 }
 
+func TestPublishOrderV2RequestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() PublishOrderV2Request {
+		return PublishOrderV2Request{
+			ID:         "ORD-1",
+			Currency:   "EUR",
+			CustomerID: "CUST-1",
+			LineItems:  []LineItem{{SKU: "SKU-1", Quantity: 2, UnitPrice: money.MustParse("5")}},
+			CreatedAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*PublishOrderV2Request)
+		wantErr string
+	}{
+		{name: "valid", mutate: func(*PublishOrderV2Request) {}},
+		{name: "blank id", mutate: func(r *PublishOrderV2Request) { r.ID = " " }, wantErr: "id must not be blank"},
+		{name: "lowercase currency", mutate: func(r *PublishOrderV2Request) { r.Currency = "eur" }, wantErr: "currency must be an ISO 4217 code"},
+		{name: "unknown currency", mutate: func(r *PublishOrderV2Request) { r.Currency = "ABC" }, wantErr: "currency must be an ISO 4217 code"},
+		{name: "withdrawn currency", mutate: func(r *PublishOrderV2Request) { r.Currency = "HRK" }, wantErr: "currency must be an ISO 4217 code"},
+		{name: "precious metal", mutate: func(r *PublishOrderV2Request) { r.Currency = "XAU" }, wantErr: "currency must be an ISO 4217 code"},
+		{name: "no currency code", mutate: func(r *PublishOrderV2Request) { r.Currency = "XXX" }, wantErr: "currency must be an ISO 4217 code"},
+		{name: "other currency", mutate: func(r *PublishOrderV2Request) { r.Currency = "JPY" }},
+		{name: "blank customer", mutate: func(r *PublishOrderV2Request) { r.CustomerID = "" }, wantErr: "customerId must not be blank"},
+		{name: "no line items", mutate: func(r *PublishOrderV2Request) { r.LineItems = nil }, wantErr: "lineItems must not be empty"},
+		{name: "blank sku", mutate: func(r *PublishOrderV2Request) { r.LineItems[0].SKU = "" }, wantErr: "lineItems[0].sku must not be blank"},
+		{name: "zero quantity", mutate: func(r *PublishOrderV2Request) { r.LineItems[0].Quantity = 0 }, wantErr: "lineItems[0].quantity must be greater than zero"},
+		{name: "negative unit price", mutate: func(r *PublishOrderV2Request) { r.LineItems[0].UnitPrice = money.MustParse("-1") }, wantErr: "lineItems[0].unitPrice must be greater than zero"},
+		{name: "unit price beyond max scale", mutate: func(r *PublishOrderV2Request) { r.LineItems[0].UnitPrice = money.MustParse("0.001") }, wantErr: "lineItems[0].unitPrice must not have more than 2 decimal places"},
+		{name: "unit price with negative scale", mutate: func(r *PublishOrderV2Request) { r.LineItems[0].UnitPrice = money.MustParse("5E+2") }, wantErr: "lineItems[0].unitPrice must not have a negative scale"},
+		{name: "missing createdAt", mutate: func(r *PublishOrderV2Request) { r.CreatedAt = time.Time{} }, wantErr: "createdAt must be set"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			request := valid()
+			tc.mutate(&request)
+			err := request.Validate(2)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestPublishURL(t *testing.T) {
 	t.Parallel()
 
@@ -39,4 +104,36 @@ func TestPublishURL(t *testing.T) {
 	if got := cfg.PublishURL(); got != "http://localhost:3500/v1.0/publish/order-pubsub/orders" {
 		t.Fatalf("unexpected publish URL: %s", got)
 	}
+	if got := cfg.BulkPublishURL(); got != "http://localhost:3500/v1.0-alpha1/publish/bulk/order-pubsub/orders" {
+		t.Fatalf("unexpected bulk publish URL: %s", got)
+	}
+}
+
+func TestPublishRejectsOutOfRangeAmounts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{path: "/publish", body: `{"id":"ORD-1","amount":1E+999999999}`},
+		{path: "/publish", body: `{"id":"ORD-1","amount":"1E+999999999"}`},
+		{path: "/publish", body: `{"id":"ORD-1","amount":1E+3}`},
+		{path: "/v2/publish", body: `{"id":"ORD-1","currency":"EUR","customerId":"CUST-1","lineItems":[{"sku":"SKU-1","quantity":2,"unitPrice":1E+999999999}],"createdAt":"2026-03-01T12:00:00Z"}`},
+		{path: "/v2/publish", body: `{"id":"ORD-1","currency":"EUR","customerId":"CUST-1","lineItems":[{"sku":"SKU-1","quantity":2,"unitPrice":1E+3}],"createdAt":"2026-03-01T12:00:00Z"}`},
+	}
+
+	for _, tc := range tests {
+		var captured []capturedPublish
+		registry := prometheus.NewRegistry()
+		router := NewRouter(Config{}, newCapturingService(&captured), registry, registry)
+
+		req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code != http.StatusBadRequest || len(captured) != 0 {
+			t.Fatalf("POST %s %s = %d with %d publishes, want 400 without publishing", tc.path, tc.body, res.Code, len(captured))
+		}
+	}
 }
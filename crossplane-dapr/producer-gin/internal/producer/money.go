@@ -0,0 +1,17 @@
+package producer
+
+// MoneyConfig bounds monetary amounts accepted by the publish endpoints.
+// MaxScale is the maximum number of decimal places, so zero accepts whole
+// amounts only; nil or a negative value selects defaultMoneyMaxScale.
+type MoneyConfig struct {
+	MaxScale *int
+}
+
+const defaultMoneyMaxScale = 2
+
+func (c MoneyConfig) maxScale() int {
+	if c.MaxScale == nil || *c.MaxScale < 0 {
+		return defaultMoneyMaxScale
+	}
+	return *c.MaxScale
+}
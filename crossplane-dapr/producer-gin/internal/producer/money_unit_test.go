@@ -0,0 +1,120 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPublishOrderV2RequestTotalIsExact(t *testing.T) {
+	t.Parallel()
+
+	request := PublishOrderV2Request{LineItems: []LineItem{
+		{Quantity: 3, UnitPrice: money.MustParse("0.10")},
+		{Quantity: 1, UnitPrice: money.MustParse("55.1")},
+	}}
+	if got := request.Total(); got.String() != "55.40" {
+		t.Fatalf("total = %s, want 55.40", got)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Parallel()
+
+	var request PublishOrderRequest
+	if err := json.Unmarshal([]byte(`{"id":"ORD-1","amount":"42.50"}`), &request); err != nil {
+		t.Fatalf("decode string amount: %v", err)
+	}
+	if request.Amount.String() != "42.50" {
+		t.Fatalf("amount = %s, want 42.50", request.Amount)
+	}
+	if err := json.Unmarshal([]byte(`{"id":"ORD-1","amount":true}`), &request); err == nil {
+		t.Fatal("expected error for a non-numeric amount")
+	}
+
+	encoded, err := json.Marshal(OrderCreatedV1{ID: "ORD-1", EventVersion: "v1"})
+	if err != nil {
+		t.Fatalf("encode zero amount: %v", err)
+	}
+	if string(encoded) != `{"id":"ORD-1","amount":0,"eventVersion":"v1"}` {
+		t.Fatalf("unexpected zero amount encoding: %s", encoded)
+	}
+}
+
+type moneyGoldenCase struct {
+	Name      string `json:"name"`
+	Input     string `json:"input"`
+	Canonical string `json:"canonical"`
+}
+
+// loadMoneyGoldenCases reads the fixture the Ktor BigDecimalAsNumberSerializer
+// golden test asserts, so both stacks agree on the OrderCreatedV1 bytes.
+func loadMoneyGoldenCases(t *testing.T) []moneyGoldenCase {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("failed to resolve test file path")
+	}
+	path := filepath.Join(filepath.Dir(file), "..", "..", "..", "common-ktor", "src", "test", "resources", "golden", "order-created-v1-amounts.json")
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden fixture: %v", err)
+	}
+	var cases []moneyGoldenCase
+	if err := json.Unmarshal(fixture, &cases); err != nil {
+		t.Fatalf("decode golden fixture: %v", err)
+	}
+	return cases
+}
+
+func TestOrderCreatedV1MatchesKtorSerializer(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range loadMoneyGoldenCases(t) {
+		var event OrderCreatedV1
+		if err := json.Unmarshal([]byte(tc.Input), &event); err != nil {
+			t.Fatalf("%s: decode: %v", tc.Name, err)
+		}
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tc.Name, err)
+		}
+		if string(encoded) != tc.Canonical {
+			t.Fatalf("%s: encoded %s, want %s", tc.Name, encoded, tc.Canonical)
+		}
+	}
+}
+
+func TestMoneyMaxScaleFromEnv(t *testing.T) {
+	tests := map[string]int{"": defaultMoneyMaxScale, "0": 0, "4": 4, "-1": defaultMoneyMaxScale, "two": defaultMoneyMaxScale}
+	for value, want := range tests {
+		t.Setenv("MONEY_MAX_SCALE", value)
+		if got := LoadConfigFromEnv().Money.maxScale(); got != want {
+			t.Fatalf("MONEY_MAX_SCALE=%q max scale = %d, want %d", value, got, want)
+		}
+	}
+	if got := (MoneyConfig{}).maxScale(); got != defaultMoneyMaxScale {
+		t.Fatalf("zero MoneyConfig max scale = %d, want %d", got, defaultMoneyMaxScale)
+	}
+
+	whole := 0
+	var captured []capturedPublish
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{Money: MoneyConfig{MaxScale: &whole}}, newCapturingService(&captured), registry, registry)
+	for body, want := range map[string]int{
+		`{"id":"ORD-1","amount":10}`:   http.StatusAccepted,
+		`{"id":"ORD-2","amount":10.5}`: http.StatusBadRequest,
+	} {
+		if res := postPublish(router, body, ""); res.Code != want {
+			t.Fatalf("POST %s = %d %s, want %d", body, res.Code, res.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package producer
+
+import (
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option customises optional collaborators shared by the producer
+// constructors (NewService, NewRouter, NewOutboxRelay). Defaults come from the
+// global OpenTelemetry providers installed by SetupTelemetry.
+type Option func(*options)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	outbox         OutboxStore
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+	bulkPublishURL string
+	cloudEvents    CloudEventConfig
+	health         *health.Health
+}
+
+func newOptions(opts []Option) options {
+	resolved := options{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&resolved)
+		}
+	}
+	return resolved
+}
+
+// WithTracerProvider sets the tracer provider used for server and messaging
+// spans instead of the global one.
+func WithTracerProvider(tracerProvider trace.TracerProvider) Option {
+	return func(o *options) {
+		if tracerProvider != nil {
+			o.tracerProvider = tracerProvider
+		}
+	}
+}
+
+// WithPropagator sets the propagator used to extract and inject trace
+// context instead of the global one.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		if propagator != nil {
+			o.propagator = propagator
+		}
+	}
+}
+
+// WithOutbox switches POST /publish to outbox mode: orders are stored in the
+// given store and answered with 202 before they reach Dapr, and
+// GET /publish/{orderId} reports their delivery status.
+func WithOutbox(store OutboxStore) Option {
+	return func(o *options) {
+		o.outbox = store
+	}
+}
+
+// WithIdempotency makes POST /publish replay the first response for a
+// repeated Idempotency-Key (or order id) seen within window.
+func WithIdempotency(store IdempotencyStore, window time.Duration) Option {
+	return func(o *options) {
+		o.idempotency = store
+		o.idempotencyTTL = window
+	}
+}
+
+// WithBulkPublishURL sets the Dapr bulk publish endpoint used by
+// Service.PublishBulk.
+func WithBulkPublishURL(url string) Option {
+	return func(o *options) {
+		o.bulkPublishURL = url
+	}
+}
+
+// WithCloudEvents sets the CloudEvents source and framing used by the
+// publishers.
+func WithCloudEvents(config CloudEventConfig) Option {
+	return func(o *options) {
+		o.cloudEvents = config
+	}
+}
+
+// WithHealth serves the checks registered on h on /health and the readiness
+// and startup probes. Without it the probes report UP unconditionally.
+func WithHealth(h *health.Health) Option {
+	return func(o *options) {
+		if h != nil {
+			o.health = h
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// OutboxConfig enables outbox mode and tunes the background relay. A failed
+// record is retried after RetryBackoff, doubling per attempt up to
+// MaxRetryBackoff, and marked failed after MaxAttempts. Published records
+// are deleted once they are older than Retention.
+type OutboxConfig struct {
+	Enabled         bool
+	Path            string
+	PollInterval    time.Duration
+	BatchSize       int
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	Retention       time.Duration
+}
+
+// OutboxStatus is the delivery state of an order accepted in outbox mode.
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusPublished OutboxStatus = "published"
+	OutboxStatusFailed    OutboxStatus = "failed"
+)
+
+var (
+	// ErrOutboxRecordNotFound is returned when no record exists for an order id.
+	ErrOutboxRecordNotFound = errors.New("outbox record not found")
+	// ErrOutboxDuplicate is returned when an order id has already been accepted.
+	ErrOutboxDuplicate = errors.New("order already accepted")
+)
+
+// OutboxRecord is an accepted order waiting for, or done with, relay to Dapr.
+// Orders accepted on POST /v2/publish carry RequestV2 and the EventVersions
+// in effect at acceptance. TraceContext holds the propagation headers of the
+// accepting request so the relayed publish joins the original trace, and
+// CorrelationID its X-Correlation-ID. NextAttemptAt is set after a failed
+// attempt; the relay skips the record until then.
+type OutboxRecord struct {
+	OrderID       string                 `json:"orderId"`
+	Request       PublishOrderRequest    `json:"request"`
+	RequestV2     *PublishOrderV2Request `json:"requestV2,omitempty"`
+	EventVersions EventVersions          `json:"eventVersions,omitempty"`
+	Status        OutboxStatus           `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	LastError     string                 `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time             `json:"nextAttemptAt,omitempty"`
+	TraceContext  map[string]string      `json:"traceContext,omitempty"`
+	CorrelationID string                 `json:"correlationId,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	UpdatedAt     time.Time              `json:"updatedAt"`
+	PublishedAt   *time.Time             `json:"publishedAt,omitempty"`
+}
+
+// OutboxStats summarises pending outbox work for metrics.
+type OutboxStats struct {
+	Pending       int
+	OldestPending time.Time
+}
+
+// OutboxStore persists accepted orders until the relay has published them.
+type OutboxStore interface {
+	Enqueue(ctx context.Context, record OutboxRecord) error
+	Get(ctx context.Context, orderID string) (OutboxRecord, error)
+	// Pending returns up to limit pending records that are due at now, in
+	// FIFO order.
+	Pending(ctx context.Context, now time.Time, limit int) ([]OutboxRecord, error)
+	MarkPublished(ctx context.Context, orderID string, at time.Time) error
+	// MarkFailedAttempt counts a failed attempt. The record stays pending
+	// until nextAttempt unless giveUp marks it failed.
+	MarkFailedAttempt(ctx context.Context, orderID string, cause error, at, nextAttempt time.Time, giveUp bool) error
+	Stats(ctx context.Context) (OutboxStats, error)
+	// PrunePublished deletes records published before the given time and
+	// returns how many were deleted.
+	PrunePublished(ctx context.Context, before time.Time) (int, error)
+}
+
+var (
+	outboxRecordsBucket  = []byte("outbox_records")
+	outboxPendingBucket  = []byte("outbox_pending")
+	outboxSequenceIndex  = []byte("outbox_sequence")
+	outboxPublishedIndex = []byte("outbox_published")
+)
+
+// BoltOutboxStore is an OutboxStore backed by an embedded bbolt file. Pending
+// records are indexed by insertion sequence so the relay drains them in FIFO
+// order, and published records by publish time so they can be pruned.
+type BoltOutboxStore struct {
+	db *bolt.DB
+}
+
+func OpenBoltOutboxStore(path string) (*BoltOutboxStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open outbox store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{outboxRecordsBucket, outboxPendingBucket, outboxSequenceIndex, outboxPublishedIndex} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise outbox buckets: %w", err)
+	}
+	return &BoltOutboxStore{db: db}, nil
+}
+
+func (s *BoltOutboxStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltOutboxStore) Enqueue(_ context.Context, record OutboxRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(outboxRecordsBucket)
+		key := []byte(record.OrderID)
+		if records.Get(key) != nil {
+			return ErrOutboxDuplicate
+		}
+
+		pending := tx.Bucket(outboxPendingBucket)
+		seq, err := pending.NextSequence()
+		if err != nil {
+			return err
+		}
+		seqKey := make([]byte, 8)
+		binary.BigEndian.PutUint64(seqKey, seq)
+
+		record.Status = OutboxStatusPending
+		if err := putRecord(records, record); err != nil {
+			return err
+		}
+		if err := pending.Put(seqKey, key); err != nil {
+			return err
+		}
+		return tx.Bucket(outboxSequenceIndex).Put(key, seqKey)
+	})
+}
+
+func (s *BoltOutboxStore) Get(_ context.Context, orderID string) (OutboxRecord, error) {
+	var record OutboxRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getRecord(tx.Bucket(outboxRecordsBucket), orderID)
+		return err
+	})
+	return record, err
+}
+
+// Pending skips records that are backing off, so they never hold up due
+// records queued behind them.
+func (s *BoltOutboxStore) Pending(_ context.Context, now time.Time, limit int) ([]OutboxRecord, error) {
+	var records []OutboxRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		recordsBucket := tx.Bucket(outboxRecordsBucket)
+		cursor := tx.Bucket(outboxPendingBucket).Cursor()
+		for key, orderID := cursor.First(); key != nil && (limit <= 0 || len(records) < limit); key, orderID = cursor.Next() {
+			record, err := getRecord(recordsBucket, string(orderID))
+			if err != nil {
+				return err
+			}
+			if record.NextAttemptAt != nil && now.Before(*record.NextAttemptAt) {
+				continue
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (s *BoltOutboxStore) MarkPublished(_ context.Context, orderID string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(outboxRecordsBucket)
+		record, err := getRecord(records, orderID)
+		if err != nil {
+			return err
+		}
+		record.Status = OutboxStatusPublished
+		record.Attempts++
+		record.LastError = ""
+		record.NextAttemptAt = nil
+		record.UpdatedAt = at
+		record.PublishedAt = &at
+		if err := putRecord(records, record); err != nil {
+			return err
+		}
+		if err := tx.Bucket(outboxPublishedIndex).Put(publishedKey(at, orderID), []byte(orderID)); err != nil {
+			return err
+		}
+		return removePending(tx, orderID)
+	})
+}
+
+func (s *BoltOutboxStore) MarkFailedAttempt(_ context.Context, orderID string, cause error, at, nextAttempt time.Time, giveUp bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(outboxRecordsBucket)
+		record, err := getRecord(records, orderID)
+		if err != nil {
+			return err
+		}
+		record.Attempts++
+		record.UpdatedAt = at
+		if cause != nil {
+			record.LastError = cause.Error()
+		}
+		if !giveUp {
+			record.NextAttemptAt = &nextAttempt
+			return putRecord(records, record)
+		}
+		record.Status = OutboxStatusFailed
+		record.NextAttemptAt = nil
+		if err := putRecord(records, record); err != nil {
+			return err
+		}
+		return removePending(tx, orderID)
+	})
+}
+
+func (s *BoltOutboxStore) Stats(_ context.Context) (OutboxStats, error) {
+	var stats OutboxStats
+	err := s.db.View(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(outboxPendingBucket)
+		stats.Pending = pending.Stats().KeyN
+		_, orderID := pending.Cursor().First()
+		if orderID == nil {
+			return nil
+		}
+		oldest, err := getRecord(tx.Bucket(outboxRecordsBucket), string(orderID))
+		if err != nil {
+			return err
+		}
+		stats.OldestPending = oldest.CreatedAt
+		return nil
+	})
+	return stats, err
+}
+
+func (s *BoltOutboxStore) PrunePublished(_ context.Context, before time.Time) (int, error) {
+	limit := publishedKey(before, "")
+	expired := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		oldest, _ := tx.Bucket(outboxPublishedIndex).Cursor().First()
+		expired = oldest != nil && bytes.Compare(oldest, limit) < 0
+		return nil
+	})
+	if err != nil || !expired {
+		// Skip the write transaction on the common poll with nothing to prune.
+		return 0, err
+	}
+
+	pruned := 0
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(outboxRecordsBucket)
+		cursor := tx.Bucket(outboxPublishedIndex).Cursor()
+		for key, orderID := cursor.First(); key != nil && bytes.Compare(key, limit) < 0; key, orderID = cursor.First() {
+			if err := records.Delete(orderID); err != nil {
+				return err
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// publishedKey orders the published index by publish time.
+func publishedKey(at time.Time, orderID string) []byte {
+	key := make([]byte, 8, 8+len(orderID))
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	return append(key, orderID...)
+}
+
+func getRecord(bucket *bolt.Bucket, orderID string) (OutboxRecord, error) {
+	raw := bucket.Get([]byte(orderID))
+	if raw == nil {
+		return OutboxRecord{}, ErrOutboxRecordNotFound
+	}
+	var record OutboxRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return OutboxRecord{}, fmt.Errorf("decode outbox record %q: %w", orderID, err)
+	}
+	return record, nil
+}
+
+func putRecord(bucket *bolt.Bucket, record OutboxRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode outbox record %q: %w", record.OrderID, err)
+	}
+	return bucket.Put([]byte(record.OrderID), raw)
+}
+
+func removePending(tx *bolt.Tx, orderID string) error {
+	sequences := tx.Bucket(outboxSequenceIndex)
+	seqKey := sequences.Get([]byte(orderID))
+	if seqKey == nil {
+		return nil
+	}
+	if err := tx.Bucket(outboxPendingBucket).Delete(seqKey); err != nil {
+		return err
+	}
+	return sequences.Delete([]byte(orderID))
+}
@@ -0,0 +1,326 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func openTestOutbox(t *testing.T) *BoltOutboxStore {
+	t.Helper()
+	store, err := OpenBoltOutboxStore(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func enqueueOrder(t *testing.T, store OutboxStore, id string, createdAt time.Time) {
+	t.Helper()
+	record := OutboxRecord{OrderID: id, Request: PublishOrderRequest{ID: id, Amount: money.MustParse("10")}, CreatedAt: createdAt, UpdatedAt: createdAt}
+	if err := store.Enqueue(context.Background(), record); err != nil {
+		t.Fatalf("enqueue %s: %v", id, err)
+	}
+}
+
+func TestBoltOutboxStoreLifecycle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestOutbox(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	enqueueOrder(t, store, "ORD-1", start)
+	enqueueOrder(t, store, "ORD-2", start.Add(time.Second))
+	enqueueOrder(t, store, "ORD-3", start.Add(2*time.Second))
+
+	if err := store.Enqueue(ctx, OutboxRecord{OrderID: "ORD-1"}); !errors.Is(err, ErrOutboxDuplicate) {
+		t.Fatalf("expected ErrOutboxDuplicate, got %v", err)
+	}
+
+	pending, err := store.Pending(ctx, start, 2)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].OrderID != "ORD-1" || pending[1].OrderID != "ORD-2" {
+		t.Fatalf("expected FIFO batch [ORD-1 ORD-2], got %+v", pending)
+	}
+
+	if err := store.MarkPublished(ctx, "ORD-1", start.Add(time.Minute)); err != nil {
+		t.Fatalf("mark published: %v", err)
+	}
+	if err := store.MarkFailedAttempt(ctx, "ORD-2", errors.New("sidecar down"), start.Add(time.Minute), start.Add(2*time.Minute), false); err != nil {
+		t.Fatalf("mark failed attempt: %v", err)
+	}
+	if err := store.MarkFailedAttempt(ctx, "ORD-3", errors.New("sidecar down"), start.Add(time.Minute), start.Add(2*time.Minute), true); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+
+	published, _ := store.Get(ctx, "ORD-1")
+	if published.Status != OutboxStatusPublished || published.PublishedAt == nil {
+		t.Fatalf("unexpected published record: %+v", published)
+	}
+	retrying, _ := store.Get(ctx, "ORD-2")
+	if retrying.Status != OutboxStatusPending || retrying.Attempts != 1 || retrying.LastError != "sidecar down" ||
+		retrying.NextAttemptAt == nil || !retrying.NextAttemptAt.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("unexpected retrying record: %+v", retrying)
+	}
+	failed, _ := store.Get(ctx, "ORD-3")
+	if failed.Status != OutboxStatusFailed {
+		t.Fatalf("unexpected failed record: %+v", failed)
+	}
+	if _, err := store.Get(ctx, "ORD-404"); !errors.Is(err, ErrOutboxRecordNotFound) {
+		t.Fatalf("expected ErrOutboxRecordNotFound, got %v", err)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Pending != 1 || !stats.OldestPending.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestOutboxRelayDrain(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	start := time.Now().Add(-time.Minute)
+	enqueueOrder(t, store, "ORD-OK", start)
+	enqueueOrder(t, store, "ORD-FAIL", start)
+
+	var published []string
+	service := NewService(doerFunc(func(req *http.Request) (*http.Response, error) {
+		var event struct {
+			Data OrderCreatedV1 `json:"data"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&event)
+		if event.Data.ID == "ORD-FAIL" {
+			return nil, errors.New("connection refused")
+		}
+		published = append(published, event.Data.ID)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	relay := NewOutboxRelay(store, service, OutboxConfig{MaxAttempts: 2}, prometheus.NewRegistry())
+	now := time.Now()
+	relay.now = func() time.Time { return now }
+
+	if got := relay.Drain(context.Background()); got != 1 {
+		t.Fatalf("expected one published record, got %d", got)
+	}
+	if len(published) != 1 || published[0] != "ORD-OK" {
+		t.Fatalf("unexpected published events: %v", published)
+	}
+	if got := testutil.ToFloat64(relay.depth); got != 1 {
+		t.Fatalf("outbox depth = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(relay.oldestAge); got < 60 {
+		t.Fatalf("oldest pending age = %v, want >= 60s", got)
+	}
+
+	retrying, _ := store.Get(context.Background(), "ORD-FAIL")
+	if retrying.Attempts != 1 || retrying.NextAttemptAt == nil || !retrying.NextAttemptAt.Equal(now.Add(time.Second)) {
+		t.Fatalf("expected a retry after the initial backoff, got %+v", retrying)
+	}
+	relay.Drain(context.Background())
+	if retrying, _ = store.Get(context.Background(), "ORD-FAIL"); retrying.Attempts != 1 {
+		t.Fatalf("relay retried before the backoff elapsed, got %+v", retrying)
+	}
+
+	now = now.Add(time.Second)
+	relay.Drain(context.Background())
+	failed, _ := store.Get(context.Background(), "ORD-FAIL")
+	if failed.Status != OutboxStatusFailed || failed.Attempts != 2 {
+		t.Fatalf("expected record to fail after max attempts, got %+v", failed)
+	}
+	if got := testutil.ToFloat64(relay.depth); got != 0 {
+		t.Fatalf("outbox depth = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(relay.abandoned); got != 1 {
+		t.Fatalf("failed outbox orders = %v, want 1", got)
+	}
+}
+
+func TestOutboxRelaySkipsRecordsBackingOff(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestOutbox(t)
+	now := time.Now()
+	for _, id := range []string{"ORD-1", "ORD-2", "ORD-3"} {
+		enqueueOrder(t, store, id, now)
+	}
+	for _, id := range []string{"ORD-1", "ORD-2"} {
+		if err := store.MarkFailedAttempt(ctx, id, errors.New("sidecar down"), now, now.Add(time.Hour), false); err != nil {
+			t.Fatalf("mark failed attempt: %v", err)
+		}
+	}
+
+	var captured []capturedPublish
+	relay := NewOutboxRelay(store, newCapturingService(&captured), OutboxConfig{BatchSize: 2}, prometheus.NewRegistry())
+	relay.now = func() time.Time { return now }
+
+	if got := relay.Drain(ctx); got != 1 {
+		t.Fatalf("published %d records, want the due ORD-3 behind a full batch backing off", got)
+	}
+	if record, _ := store.Get(ctx, "ORD-3"); record.Status != OutboxStatusPublished {
+		t.Fatalf("ORD-3 = %+v, want published", record)
+	}
+}
+
+func TestOutboxRelayPrunesPublishedRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := openTestOutbox(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	enqueueOrder(t, store, "ORD-OLD", start)
+	enqueueOrder(t, store, "ORD-NEW", start)
+	enqueueOrder(t, store, "ORD-PENDING", start)
+	if err := store.MarkPublished(ctx, "ORD-OLD", start); err != nil {
+		t.Fatalf("mark published: %v", err)
+	}
+	if err := store.MarkPublished(ctx, "ORD-NEW", start.Add(2*time.Hour)); err != nil {
+		t.Fatalf("mark published: %v", err)
+	}
+
+	relay := NewOutboxRelay(store, nil, OutboxConfig{Retention: 2 * time.Hour}, prometheus.NewRegistry())
+	relay.now = func() time.Time { return start.Add(3 * time.Hour) }
+
+	if got := relay.Prune(ctx); got != 1 {
+		t.Fatalf("pruned %d records, want 1", got)
+	}
+	if got := relay.Prune(ctx); got != 0 {
+		t.Fatalf("second prune deleted %d records, want 0", got)
+	}
+	if _, err := store.Get(ctx, "ORD-OLD"); !errors.Is(err, ErrOutboxRecordNotFound) {
+		t.Fatalf("expected ORD-OLD to be pruned, got %v", err)
+	}
+	for _, id := range []string{"ORD-NEW", "ORD-PENDING"} {
+		if _, err := store.Get(ctx, id); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+}
+
+func TestOutboxRelayBackoffDoubles(t *testing.T) {
+	t.Parallel()
+
+	relay := NewOutboxRelay(openTestOutbox(t), nil, OutboxConfig{RetryBackoff: time.Second, MaxRetryBackoff: 5 * time.Second}, prometheus.NewRegistry())
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := relay.backoff(i + 1); got != delay {
+			t.Fatalf("backoff after %d attempts = %s, want %s", i+1, got, delay)
+		}
+	}
+}
+
+func TestOutboxRelayDoesNotCountOpenCircuitAsAttempts(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	start := time.Now()
+	enqueueOrder(t, store, "ORD-1", start)
+	enqueueOrder(t, store, "ORD-2", start)
+
+	calls := 0
+	doer, _ := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	}), ResilienceConfig{MaxAttempts: 1, BreakerFailureThreshold: 1, BreakerOpenTimeout: time.Hour})
+	relay := NewOutboxRelay(store, NewService(doer, "http://dapr.local/publish"), OutboxConfig{MaxAttempts: 3}, prometheus.NewRegistry())
+	now := start
+	relay.now = func() time.Time { return now }
+
+	// The breaker clock stands still, so it stays open while the relay polls
+	// well past MaxAttempts with every backoff elapsed.
+	for range 10 {
+		relay.Drain(context.Background())
+		now = now.Add(time.Hour)
+	}
+
+	if calls != 1 {
+		t.Fatalf("sidecar calls = %d, want 1 before the breaker opened", calls)
+	}
+	for id, attempts := range map[string]int{"ORD-1": 1, "ORD-2": 0} {
+		record, err := store.Get(context.Background(), id)
+		if err != nil || record.Status != OutboxStatusPending || record.Attempts != attempts {
+			t.Fatalf("%s = %+v, %v; want pending after %d attempts", id, record, err, attempts)
+		}
+	}
+	if got := testutil.ToFloat64(relay.abandoned); got != 0 {
+		t.Fatalf("failed outbox orders = %v, want 0", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	relay.Drain(ctx)
+	if record, _ := store.Get(context.Background(), "ORD-2"); record.Attempts != 0 {
+		t.Fatalf("cancelled drain counted an attempt: %+v", record)
+	}
+}
+
+func TestOutboxModeRoutes(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	calls := 0
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, service, registry, registry, WithOutbox(store))
+	relay := NewOutboxRelay(store, service, OutboxConfig{}, registry)
+
+	publish := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-7","amount":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res.Code
+	}
+	status := func(id string) (int, map[string]any) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/publish/"+id, nil))
+		var body map[string]any
+		_ = json.Unmarshal(res.Body.Bytes(), &body)
+		return res.Code, body
+	}
+
+	if code := publish(); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if calls != 0 {
+		t.Fatalf("outbox mode must not call the sidecar inline, got %d calls", calls)
+	}
+	if code, body := status("ORD-7"); code != http.StatusOK || body["status"] != "pending" {
+		t.Fatalf("expected pending status, got %d %v", code, body)
+	}
+	if code := publish(); code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate order, got %d", code)
+	}
+
+	relay.Drain(context.Background())
+
+	if code, body := status("ORD-7"); code != http.StatusOK || body["status"] != "published" {
+		t.Fatalf("expected published status, got %d %v", code, body)
+	}
+	if code, _ := status("ORD-404"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown order, got %d", code)
+	}
+}
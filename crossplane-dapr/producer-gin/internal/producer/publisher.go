@@ -0,0 +1,105 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Publisher publishes order events to the Dapr sidecar. Service talks to the
+// Dapr HTTP API and GRPCPublisher to the Dapr gRPC API; DAPR_TRANSPORT picks
+// one at startup.
+type Publisher interface {
+	// Publish publishes the OrderCreatedV1 event for request.
+	Publish(ctx context.Context, request PublishOrderRequest) error
+	PublishEvent(ctx context.Context, event OrderEvent) error
+	// PublishBulk publishes requests in one call. The returned slice holds
+	// the per-entry failure (nil when accepted); the error is set when the
+	// call failed as a whole.
+	PublishBulk(ctx context.Context, requests []PublishOrderRequest) ([]error, error)
+}
+
+var (
+	_ Publisher = (*Service)(nil)
+	_ Publisher = (*GRPCPublisher)(nil)
+)
+
+// NewPublisher builds the Publisher selected by cfg.DaprTransport, wrapped in
+// the cfg.Resilience retry and circuit breaker policy. The returned func
+// releases the transport.
+func NewPublisher(cfg Config, httpClient HTTPDoer, registerer prometheus.Registerer, opts ...Option) (Publisher, func() error, error) {
+	opts = append([]Option{WithCloudEvents(cfg.CloudEvents)}, opts...)
+	switch strings.ToLower(cfg.DaprTransport) {
+	case "", "http":
+		doer := NewResilientDoer(httpClient, cfg.Resilience, registerer)
+		opts = append(opts, WithBulkPublishURL(cfg.BulkPublishURL()))
+		return NewService(doer, cfg.PublishURL(), opts...), func() error { return nil }, nil
+	case "grpc":
+		conn, err := DialDaprGRPC(cfg.DaprGRPCAddress(), NewResilientUnaryInterceptor(cfg.Resilience, registerer))
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewGRPCPublisher(conn, cfg.PubSubName, cfg.TopicName, opts...), conn.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported dapr transport %q", cfg.DaprTransport)
+	}
+}
+
+// tracePublish runs publish inside the "orders.publish" producer span.
+func tracePublish(ctx context.Context, tracer trace.Tracer, event OrderEvent, publish func(context.Context, OrderEvent) error) error {
+	ctx, span := tracer.Start(ctx, "orders.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "dapr"),
+			attribute.String("order.id", event.OrderID()),
+			attribute.String("order.event_version", event.Version()),
+		),
+	)
+	defer span.End()
+
+	err := publish(ctx, event)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetStatus(codes.Ok, "")
+	return nil
+}
+
+// tracePublishBulk runs publish inside the "orders.publish_bulk" producer
+// span and marks the span failed when any entry failed.
+func tracePublishBulk(ctx context.Context, tracer trace.Tracer, count int, publish func(context.Context) ([]error, error)) ([]error, error) {
+	ctx, span := tracer.Start(ctx, "orders.publish_bulk",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "dapr"),
+			attribute.Int("messaging.batch.message_count", count),
+		),
+	)
+	defer span.End()
+
+	entryErrs, err := publish(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	failed := 0
+	for _, entryErr := range entryErrs {
+		if entryErr != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d entries failed", failed, count))
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	return entryErrs, nil
+}
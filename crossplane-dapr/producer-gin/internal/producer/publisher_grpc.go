@@ -0,0 +1,224 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	runtimev1pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCPublisher publishes order events through the Dapr gRPC API
+// (PublishEvent and BulkPublishEventAlpha1) on DAPR_GRPC_PORT.
+type GRPCPublisher struct {
+	client     runtimev1pb.DaprClient
+	pubsubName string
+	topicName  string
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	encoder    eventEncoder
+}
+
+func NewGRPCPublisher(conn grpc.ClientConnInterface, pubsubName, topicName string, opts ...Option) *GRPCPublisher {
+	resolved := newOptions(opts)
+	return &GRPCPublisher{
+		client:     runtimev1pb.NewDaprClient(conn),
+		pubsubName: pubsubName,
+		topicName:  topicName,
+		tracer:     resolved.tracerProvider.Tracer(instrumentationName),
+		propagator: resolved.propagator,
+		encoder:    newEventEncoder(resolved),
+	}
+}
+
+// DialDaprGRPC opens a plaintext client connection to the sidecar gRPC API.
+// The connection is established lazily on the first call.
+func DialDaprGRPC(address string, interceptors ...grpc.UnaryClientInterceptor) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial dapr grpc %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+func (p *GRPCPublisher) Publish(ctx context.Context, request PublishOrderRequest) error {
+	return p.PublishEvent(ctx, request.Event())
+}
+
+func (p *GRPCPublisher) PublishEvent(ctx context.Context, event OrderEvent) error {
+	return tracePublish(ctx, p.tracer, event, p.publish)
+}
+
+func (p *GRPCPublisher) publish(ctx context.Context, event OrderEvent) error {
+	requestLogger := loggerFromContext(ctx)
+	orderID := event.OrderID()
+
+	payload, contentType, err := p.encoder.encode(ctx, event)
+	if err != nil {
+		requestLogger.ErrorContext(ctx, "failed to encode order event", "orderId", orderID, "error", err)
+		return err
+	}
+
+	ctx, eventMetadata := p.injectTraceContext(ctx)
+	requestLogger.DebugContext(ctx, "publishing order event", "orderId", orderID, "version", event.Version(), "pubsub", p.pubsubName, "topic", p.topicName, "transport", "grpc")
+
+	_, err = p.client.PublishEvent(ctx, &runtimev1pb.PublishEventRequest{
+		PubsubName:      p.pubsubName,
+		Topic:           p.topicName,
+		Data:            payload,
+		DataContentType: contentType,
+		Metadata:        eventMetadata,
+	})
+	if err != nil {
+		requestLogger.ErrorContext(ctx, "publish request failed", "orderId", orderID, "error", err)
+		return fmt.Errorf("publish request failed: %w", err)
+	}
+
+	requestLogger.DebugContext(ctx, "publish request succeeded", "orderId", orderID)
+	return nil
+}
+
+func (p *GRPCPublisher) PublishBulk(ctx context.Context, requests []PublishOrderRequest) ([]error, error) {
+	return tracePublishBulk(ctx, p.tracer, len(requests), func(ctx context.Context) ([]error, error) {
+		return p.publishBulk(ctx, requests)
+	})
+}
+
+func (p *GRPCPublisher) publishBulk(ctx context.Context, requests []PublishOrderRequest) ([]error, error) {
+	requestLogger := loggerFromContext(ctx)
+
+	entries := make([]*runtimev1pb.BulkPublishRequestEntry, len(requests))
+	for i, request := range requests {
+		payload, contentType, err := p.encoder.encode(ctx, request.Event())
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = &runtimev1pb.BulkPublishRequestEntry{
+			EntryId:     strconv.Itoa(i),
+			Event:       payload,
+			ContentType: contentType,
+		}
+	}
+
+	ctx, eventMetadata := p.injectTraceContext(ctx)
+	requestLogger.DebugContext(ctx, "publishing order events in bulk", "entries", len(requests), "pubsub", p.pubsubName, "topic", p.topicName, "transport", "grpc")
+
+	resp, err := p.client.BulkPublishEventAlpha1(ctx, &runtimev1pb.BulkPublishRequest{
+		PubsubName: p.pubsubName,
+		Topic:      p.topicName,
+		Entries:    entries,
+		Metadata:   eventMetadata,
+	})
+	if err != nil {
+		requestLogger.ErrorContext(ctx, "bulk publish request failed", "entries", len(requests), "error", err)
+		return nil, fmt.Errorf("bulk publish request failed: %w", err)
+	}
+
+	entryErrs := make([]error, len(requests))
+	for _, failed := range resp.GetFailedEntries() {
+		index, err := strconv.Atoi(failed.GetEntryId())
+		if err != nil || index < 0 || index >= len(requests) {
+			continue
+		}
+		entryErrs[index] = errors.New(failed.GetError())
+	}
+	if failed := len(resp.GetFailedEntries()); failed > 0 {
+		requestLogger.WarnContext(ctx, "bulk publish partially failed", "entries", len(requests), "failed", failed)
+	}
+	return entryErrs, nil
+}
+
+// injectTraceContext mirrors Service.injectTraceContext over gRPC metadata.
+func (p *GRPCPublisher) injectTraceContext(ctx context.Context) (context.Context, map[string]string) {
+	eventMetadata := map[string]string{}
+	for key, value := range p.encoder.metadata() {
+		eventMetadata[key] = value
+	}
+	carrier := propagation.MapCarrier{}
+	p.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return ctx, eventMetadata
+	}
+
+	pairs := make([]string, 0, 2*len(carrier))
+	for key, value := range carrier {
+		pairs = append(pairs, key, value)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+
+	if traceparent := carrier.Get("traceparent"); traceparent != "" {
+		eventMetadata["cloudevent.traceparent"] = traceparent
+		if tracestate := carrier.Get("tracestate"); tracestate != "" {
+			eventMetadata["cloudevent.tracestate"] = tracestate
+		}
+	}
+	return ctx, eventMetadata
+}
+
+// NewResilientUnaryInterceptor applies the ResilientDoer retry and circuit
+// breaker policy to Dapr gRPC calls. Every attempt gets its own
+// AttemptTimeout. Unavailable, ResourceExhausted, Aborted, Internal and
+// Unknown errors and timed out attempts are retried with exponential backoff.
+func NewResilientUnaryInterceptor(config ResilienceConfig, registerer prometheus.Registerer) grpc.UnaryClientInterceptor {
+	return newResilience(config, registerer).interceptUnary
+}
+
+func (r *resilience) interceptUnary(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	for attempt := 1; ; attempt++ {
+		if err := r.admit(ctx, attempt); err != nil {
+			return err
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, r.config.AttemptTimeout)
+		err := invoker(attemptCtx, method, req, reply, cc, opts...)
+		cancel()
+		reason, retryable := classifyGRPCAttempt(ctx, err)
+		if !retryable {
+			// Cancellations say nothing about sidecar health.
+			cancelled := err != nil && ctx.Err() != nil
+			r.breaker.record(!cancelled, cancelled)
+			return err
+		}
+		r.breaker.record(false, false)
+
+		delay := r.backoff(attempt)
+		if !r.shouldRetry(ctx, attempt, delay) {
+			return err
+		}
+		if err := r.wait(ctx, attempt, reason, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// classifyGRPCAttempt takes the context of the whole call, so an attempt
+// that only ran out of its own AttemptTimeout is still retried.
+func classifyGRPCAttempt(ctx context.Context, err error) (string, bool) {
+	if err == nil || ctx.Err() != nil {
+		return "", false
+	}
+	switch status.Code(err) {
+	case grpccodes.Unavailable:
+		return "transport_error", true
+	case grpccodes.ResourceExhausted:
+		return "throttled", true
+	case grpccodes.DeadlineExceeded:
+		return "timeout", true
+	case grpccodes.Internal, grpccodes.Unknown, grpccodes.Aborted:
+		return "server_error", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,273 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	runtimev1pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeDaprGRPCServer records publish calls. failures holds the errors
+// returned by the next PublishEvent calls, in order. The next stalls publish
+// calls block until the client gives up.
+type fakeDaprGRPCServer struct {
+	runtimev1pb.UnimplementedDaprServer
+
+	mu             sync.Mutex
+	stalls         int
+	failures       []error
+	published      []*runtimev1pb.PublishEventRequest
+	traceparents   []string
+	bulk           []*runtimev1pb.BulkPublishRequest
+	bulkFailedIDs  []string
+	publishAttempt int
+}
+
+// stall blocks until ctx ends if a stalled call is left.
+func (s *fakeDaprGRPCServer) stall(ctx context.Context) error {
+	s.mu.Lock()
+	stalled := s.stalls > 0
+	if stalled {
+		s.stalls--
+	}
+	s.mu.Unlock()
+	if !stalled {
+		return nil
+	}
+	<-ctx.Done()
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+func (s *fakeDaprGRPCServer) PublishEvent(ctx context.Context, req *runtimev1pb.PublishEventRequest) (*emptypb.Empty, error) {
+	err := s.stall(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishAttempt++
+	if err != nil {
+		return nil, err
+	}
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.traceparents = append(s.traceparents, md.Get("traceparent")...)
+	s.published = append(s.published, req)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *fakeDaprGRPCServer) BulkPublishEventAlpha1(ctx context.Context, req *runtimev1pb.BulkPublishRequest) (*runtimev1pb.BulkPublishResponse, error) {
+	if err := s.stall(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulk = append(s.bulk, req)
+	resp := &runtimev1pb.BulkPublishResponse{}
+	for _, id := range s.bulkFailedIDs {
+		resp.FailedEntries = append(resp.FailedEntries, &runtimev1pb.BulkPublishResponseFailedEntry{EntryId: id, Error: "broker rejected message"})
+	}
+	return resp, nil
+}
+
+// startFakeDaprGRPC serves fake over an in-memory listener and returns a
+// client connection that goes through the resilient interceptor.
+func startFakeDaprGRPC(t *testing.T, fake *fakeDaprGRPCServer, registerer prometheus.Registerer, config ResilienceConfig) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	runtimev1pb.RegisterDaprServer(server, fake)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	policy := newResilience(config, registerer)
+	policy.sleep = func(context.Context, time.Duration) error { return nil }
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(policy.interceptUnary),
+	)
+	if err != nil {
+		t.Fatalf("dial fake dapr: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestGRPCPublisherPublishesThroughRouter(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tracerProvider.Shutdown(context.Background()) })
+	opts := []Option{WithTracerProvider(tracerProvider), WithPropagator(NewPropagator())}
+
+	fake := &fakeDaprGRPCServer{failures: []error{status.Error(grpccodes.Unavailable, "sidecar starting")}}
+	registry := prometheus.NewRegistry()
+	conn := startFakeDaprGRPC(t, fake, registry, DefaultResilienceConfig())
+	publisher := NewGRPCPublisher(conn, "order-pubsub", "orders", opts...)
+	router := NewRouter(Config{PubSubName: "order-pubsub", TopicName: "orders"}, publisher, registry, registry, opts...)
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", res.Code, res.Body.String())
+	}
+	if fake.publishAttempt != 2 || len(fake.published) != 1 {
+		t.Fatalf("expected one retry and one published event, got %d attempts", fake.publishAttempt)
+	}
+	published := fake.published[0]
+	if published.GetPubsubName() != "order-pubsub" || published.GetTopic() != "orders" || published.GetDataContentType() != "application/cloudevents+json" {
+		t.Fatalf("unexpected publish request: %+v", published)
+	}
+	var event struct {
+		Data OrderCreatedV1 `json:"data"`
+	}
+	if err := json.Unmarshal(published.GetData(), &event); err != nil || event.Data.ID != "ORD-1" || event.Data.EventVersion != "v1" {
+		t.Fatalf("unexpected event payload %s: %v", published.GetData(), err)
+	}
+
+	producerSpan := spanByName(t, exporter.GetSpans(), "orders.publish")
+	wantTraceparent := "00-" + producerSpan.SpanContext.TraceID().String() + "-" + producerSpan.SpanContext.SpanID().String() + "-01"
+	if got := published.GetMetadata()["cloudevent.traceparent"]; got != wantTraceparent {
+		t.Fatalf("cloudevent.traceparent = %q, want %q", got, wantTraceparent)
+	}
+	if len(fake.traceparents) != 1 || fake.traceparents[0] != wantTraceparent {
+		t.Fatalf("expected traceparent gRPC metadata %q, got %v", wantTraceparent, fake.traceparents)
+	}
+	if got := counterValue(t, registry, "orders_publish_retries_total"); got != 1 {
+		t.Fatalf("retries = %v, want 1", got)
+	}
+}
+
+func TestGRPCPublisherCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	unavailable := status.Error(grpccodes.Unavailable, "sidecar down")
+	fake := &fakeDaprGRPCServer{failures: []error{unavailable, unavailable, unavailable}}
+	registry := prometheus.NewRegistry()
+	conn := startFakeDaprGRPC(t, fake, registry, ResilienceConfig{MaxAttempts: 1, BreakerFailureThreshold: 2, BreakerOpenTimeout: time.Minute})
+	publisher := NewGRPCPublisher(conn, "order-pubsub", "orders")
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(ctx, PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("10")}); status.Code(err) != grpccodes.Unavailable {
+			t.Fatalf("attempt %d: expected Unavailable, got %v", i, err)
+		}
+	}
+	if err := publisher.Publish(ctx, PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("10")}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if fake.publishAttempt != 2 {
+		t.Fatalf("open circuit must not reach the sidecar, got %d attempts", fake.publishAttempt)
+	}
+}
+
+func TestGRPCPublisherTimesOutStalledAttempts(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeDaprGRPCServer{stalls: 1}
+	registry := prometheus.NewRegistry()
+	conn := startFakeDaprGRPC(t, fake, registry, ResilienceConfig{MaxAttempts: 2, AttemptTimeout: 50 * time.Millisecond, BreakerFailureThreshold: 5})
+	publisher := NewGRPCPublisher(conn, "order-pubsub", "orders")
+
+	if err := publisher.Publish(context.Background(), PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("10")}); err != nil {
+		t.Fatalf("expected the retry after a stalled attempt to succeed, got %v", err)
+	}
+	if fake.publishAttempt != 2 || len(fake.published) != 1 {
+		t.Fatalf("expected a stalled attempt and a published retry, got %d attempts", fake.publishAttempt)
+	}
+
+	fake.stalls = 2
+	_, err := publisher.PublishBulk(context.Background(), []PublishOrderRequest{{ID: "ORD-2", Amount: money.MustParse("2")}})
+	if status.Code(err) != grpccodes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded after every bulk attempt stalled, got %v", err)
+	}
+	if got := counterValue(t, registry, "orders_publish_retries_total"); got != 2 {
+		t.Fatalf("retries = %v, want 2", got)
+	}
+}
+
+func TestGRPCPublisherPublishBulk(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeDaprGRPCServer{bulkFailedIDs: []string{"1"}}
+	conn := startFakeDaprGRPC(t, fake, prometheus.NewRegistry(), DefaultResilienceConfig())
+	publisher := NewGRPCPublisher(conn, "order-pubsub", "orders")
+
+	entryErrs, err := publisher.PublishBulk(context.Background(), []PublishOrderRequest{{ID: "ORD-1", Amount: money.MustParse("1")}, {ID: "ORD-2", Amount: money.MustParse("2")}})
+	if err != nil {
+		t.Fatalf("publish bulk: %v", err)
+	}
+	if entryErrs[0] != nil || entryErrs[1] == nil || entryErrs[1].Error() != "broker rejected message" {
+		t.Fatalf("unexpected entry errors: %v", entryErrs)
+	}
+	if len(fake.bulk) != 1 || len(fake.bulk[0].GetEntries()) != 2 || fake.bulk[0].GetEntries()[1].GetEntryId() != "1" {
+		t.Fatalf("unexpected bulk request: %+v", fake.bulk)
+	}
+}
+
+func TestNewPublisherSelectsTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		transport string
+		want      string
+		wantErr   bool
+	}{
+		{transport: "", want: "*producer.Service"},
+		{transport: "http", want: "*producer.Service"},
+		{transport: "GRPC", want: "*producer.GRPCPublisher"},
+		{transport: "amqp", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.transport, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := Config{DaprTransport: tc.transport, DaprGRPCPort: "50001"}
+			publisher, closePublisher, err := NewPublisher(cfg, http.DefaultClient, prometheus.NewRegistry())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error for an unsupported transport")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("new publisher: %v", err)
+			}
+			t.Cleanup(func() { _ = closePublisher() })
+			if got := fmt.Sprintf("%T", publisher); got != tc.want {
+				t.Fatalf("publisher type = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// OutboxRelay drains pending outbox records through Publisher.Publish with
+// at-least-once semantics: a record is only marked published after Dapr
+// accepted it, so a crash in between causes a redelivery, never a loss.
+// Failed records back off exponentially. Publishes rejected by an open
+// circuit breaker or cut short by shutdown are not counted as attempts, so a
+// sidecar outage does not use up MaxAttempts.
+type OutboxRelay struct {
+	store      OutboxStore
+	publisher  Publisher
+	config     OutboxConfig
+	propagator propagation.TextMapPropagator
+	now        func() time.Time
+
+	depth     prometheus.Gauge
+	oldestAge prometheus.Gauge
+	relayed   prometheus.Counter
+	failures  prometheus.Counter
+	abandoned prometheus.Counter
+}
+
+func NewOutboxRelay(store OutboxStore, publisher Publisher, config OutboxConfig, registerer prometheus.Registerer, opts ...Option) *OutboxRelay {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 10
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.MaxRetryBackoff <= 0 {
+		config.MaxRetryBackoff = 5 * time.Minute
+	}
+	if config.MaxRetryBackoff < config.RetryBackoff {
+		config.MaxRetryBackoff = config.RetryBackoff
+	}
+	if config.Retention <= 0 {
+		config.Retention = 24 * time.Hour
+	}
+
+	relay := &OutboxRelay{
+		store:      store,
+		publisher:  publisher,
+		config:     config,
+		propagator: newOptions(opts).propagator,
+		now:        time.Now,
+		depth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "orders_outbox_pending",
+			Help: "Orders accepted in outbox mode and not yet published by producer-gin.",
+		}),
+		oldestAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "orders_outbox_oldest_pending_age_seconds",
+			Help: "Age of the oldest pending outbox order in producer-gin.",
+		}),
+		relayed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_outbox_relayed_total",
+			Help: "Total outbox orders published to Dapr by the producer-gin relay.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_outbox_relay_errors_total",
+			Help: "Total failed outbox relay attempts in producer-gin.",
+		}),
+		abandoned: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_outbox_failed_total",
+			Help: "Total outbox orders marked failed after exhausting relay attempts in producer-gin.",
+		}),
+	}
+	registerer.MustRegister(relay.depth, relay.oldestAge, relay.relayed, relay.failures, relay.abandoned)
+	return relay
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	logger.InfoContext(ctx, "outbox relay started", "pollInterval", r.config.PollInterval, "batchSize", r.config.BatchSize)
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.Drain(ctx)
+		r.Prune(ctx)
+		select {
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain publishes one batch of due pending records and refreshes the outbox
+// gauges. It stops early when the circuit breaker is open. It returns the
+// number of records published.
+func (r *OutboxRelay) Drain(ctx context.Context) int {
+	defer r.refreshGauges(ctx)
+
+	records, err := r.store.Pending(ctx, r.now(), r.config.BatchSize)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load pending outbox records", "error", err)
+		return 0
+	}
+
+	published := 0
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return published
+		}
+		ok, deferred := r.relay(ctx, record)
+		if deferred {
+			return published
+		}
+		if ok {
+			published++
+		}
+	}
+	return published
+}
+
+// Prune deletes records published longer than Retention ago, after which
+// GET /publish/{orderId} no longer knows them. It returns the number of
+// records deleted.
+func (r *OutboxRelay) Prune(ctx context.Context) int {
+	pruned, err := r.store.PrunePublished(ctx, r.now().Add(-r.config.Retention))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to prune published outbox records", "error", err)
+		return 0
+	}
+	if pruned > 0 {
+		logger.DebugContext(ctx, "pruned published outbox records", "count", pruned)
+	}
+	return pruned
+}
+
+// relay publishes record and reports whether it was published, or deferred
+// without counting an attempt because the breaker is open or ctx is done.
+func (r *OutboxRelay) relay(ctx context.Context, record OutboxRecord) (published, deferred bool) {
+	publishCtx := r.propagator.Extract(ctx, propagation.MapCarrier(record.TraceContext))
+	recordLogger := logger.With("orderId", record.OrderID, "attempt", record.Attempts+1)
+	publishCtx = withRequestLogger(publishCtx, recordLogger)
+	publishCtx = withCorrelationID(publishCtx, record.CorrelationID)
+	publishCtx = withEventTime(publishCtx, record.CreatedAt)
+
+	if err := r.publish(publishCtx, record); err != nil {
+		if errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil {
+			recordLogger.DebugContext(publishCtx, "outbox relay deferred", "error", err)
+			return false, true
+		}
+		r.failures.Inc()
+		attempts := record.Attempts + 1
+		giveUp := attempts >= r.config.MaxAttempts
+		now := r.now()
+		if markErr := r.store.MarkFailedAttempt(ctx, record.OrderID, err, now, now.Add(r.backoff(attempts)), giveUp); markErr != nil {
+			recordLogger.ErrorContext(publishCtx, "failed to record outbox relay failure", "error", markErr)
+		}
+		if giveUp {
+			r.abandoned.Inc()
+			recordLogger.ErrorContext(publishCtx, "outbox order failed permanently", "error", err)
+		} else {
+			recordLogger.WarnContext(publishCtx, "outbox relay attempt failed", "retryIn", r.backoff(attempts), "error", err)
+		}
+		return false, false
+	}
+
+	if err := r.store.MarkPublished(ctx, record.OrderID, r.now()); err != nil {
+		// The event is out; it will be published again on the next poll.
+		recordLogger.ErrorContext(publishCtx, "failed to mark outbox order as published", "error", err)
+		return false, false
+	}
+	r.relayed.Inc()
+	recordLogger.InfoContext(publishCtx, "relayed outbox order event")
+	return true, false
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := float64(r.config.RetryBackoff) * math.Pow(2, float64(attempts-1))
+	if delay > float64(r.config.MaxRetryBackoff) {
+		return r.config.MaxRetryBackoff
+	}
+	return time.Duration(delay)
+}
+
+// publish publishes every event of record. A failure retries the whole
+// record, so events published before it are delivered again.
+func (r *OutboxRelay) publish(ctx context.Context, record OutboxRecord) error {
+	if record.RequestV2 == nil {
+		return r.publisher.Publish(ctx, record.Request)
+	}
+	for _, event := range record.EventVersions.events(*record.RequestV2) {
+		if err := r.publisher.PublishEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *OutboxRelay) refreshGauges(ctx context.Context) {
+	stats, err := r.store.Stats(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to read outbox stats", "error", err)
+		return
+	}
+	r.depth.Set(float64(stats.Pending))
+	if stats.Pending == 0 || stats.OldestPending.IsZero() {
+		r.oldestAge.Set(0)
+		return
+	}
+	r.oldestAge.Set(r.now().Sub(stats.OldestPending).Seconds())
+}
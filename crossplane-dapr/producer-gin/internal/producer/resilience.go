@@ -0,0 +1,410 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrCircuitOpen is returned without calling the sidecar while the circuit
+// breaker considers Dapr unhealthy.
+var ErrCircuitOpen = errors.New("dapr publish circuit breaker is open")
+
+// ResilienceConfig controls retries and circuit breaking around Dapr publish
+// calls. A MaxAttempts of 1 disables retries and a BreakerFailureThreshold of
+// 0 disables the circuit breaker. AttemptTimeout bounds every single publish
+// attempt; a timed out attempt is retried like a server error.
+type ResilienceConfig struct {
+	MaxAttempts             int
+	AttemptTimeout          time.Duration
+	InitialBackoff          time.Duration
+	MaxBackoff              time.Duration
+	BackoffMultiplier       float64
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+}
+
+// DefaultResilienceConfig returns the settings used when no DAPR_PUBLISH_*
+// environment variables are set.
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxAttempts:             3,
+		AttemptTimeout:          5 * time.Second,
+		InitialBackoff:          100 * time.Millisecond,
+		MaxBackoff:              2 * time.Second,
+		BackoffMultiplier:       2,
+		BreakerFailureThreshold: 5,
+		BreakerOpenTimeout:      30 * time.Second,
+	}
+}
+
+func (c ResilienceConfig) normalized() ResilienceConfig {
+	if c.MaxAttempts < 1 {
+		c.MaxAttempts = 1
+	}
+	if c.AttemptTimeout <= 0 {
+		c.AttemptTimeout = DefaultResilienceConfig().AttemptTimeout
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultResilienceConfig().InitialBackoff
+	}
+	if c.MaxBackoff < c.InitialBackoff {
+		c.MaxBackoff = c.InitialBackoff
+	}
+	if c.BackoffMultiplier < 1 {
+		c.BackoffMultiplier = DefaultResilienceConfig().BackoffMultiplier
+	}
+	if c.BreakerOpenTimeout <= 0 {
+		c.BreakerOpenTimeout = DefaultResilienceConfig().BreakerOpenTimeout
+	}
+	return c
+}
+
+// resilience is the retry policy, circuit breaker and metrics shared by the
+// HTTP (ResilientDoer) and gRPC (NewResilientUnaryInterceptor) publish paths.
+type resilience struct {
+	config  ResilienceConfig
+	breaker *circuitBreaker
+
+	retries          *prometheus.CounterVec
+	circuitRejection prometheus.Counter
+
+	now    func() time.Time
+	sleep  func(ctx context.Context, delay time.Duration) error
+	jitter func(delay time.Duration) time.Duration
+}
+
+func newResilience(config ResilienceConfig, registerer prometheus.Registerer) *resilience {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	config = config.normalized()
+
+	retries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_publish_retries_total",
+		Help: "Total Dapr publish retries in producer-gin by reason.",
+	}, []string{"reason"})
+	circuitRejection := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_publish_circuit_rejections_total",
+		Help: "Total Dapr publish calls rejected by the open circuit breaker in producer-gin.",
+	})
+	circuitState := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orders_publish_circuit_state",
+		Help: "Current Dapr publish circuit breaker state in producer-gin (1 for the active state).",
+	}, []string{"state"})
+	registerer.MustRegister(retries, circuitRejection, circuitState)
+
+	r := &resilience{
+		config:           config,
+		retries:          retries,
+		circuitRejection: circuitRejection,
+		now:              time.Now,
+		sleep:            sleepContext,
+		jitter:           equalJitter,
+	}
+	r.breaker = newCircuitBreaker(config.BreakerFailureThreshold, config.BreakerOpenTimeout, func() time.Time {
+		return r.now()
+	}, circuitState)
+	return r
+}
+
+// admit asks the circuit breaker for permission to make an attempt.
+func (r *resilience) admit(ctx context.Context, attempt int) error {
+	if r.breaker.allow() {
+		return nil
+	}
+	r.circuitRejection.Inc()
+	loggerFromContext(ctx).WarnContext(ctx, "dapr publish rejected by open circuit breaker", "attempt", attempt)
+	return ErrCircuitOpen
+}
+
+// shouldRetry reports whether a failed attempt may be followed by another one
+// after delay without exceeding the attempt budget or the context deadline.
+func (r *resilience) shouldRetry(ctx context.Context, attempt int, delay time.Duration) bool {
+	if attempt >= r.config.MaxAttempts || r.breaker.currentState() == circuitOpen {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && r.now().Add(delay).After(deadline) {
+		loggerFromContext(ctx).WarnContext(ctx, "dapr publish retry budget exceeds request deadline", "attempt", attempt, "delay", delay)
+		return false
+	}
+	return true
+}
+
+// wait records a retry and sleeps for delay unless ctx ends first.
+func (r *resilience) wait(ctx context.Context, attempt int, reason string, delay time.Duration) error {
+	r.retries.WithLabelValues(reason).Inc()
+	trace.SpanFromContext(ctx).AddEvent("publish.retry", trace.WithAttributes(
+		attribute.Int("retry.attempt", attempt),
+		attribute.String("retry.reason", reason),
+		attribute.String("retry.delay", delay.String()),
+	))
+	loggerFromContext(ctx).WarnContext(ctx, "retrying dapr publish", "attempt", attempt, "reason", reason, "delay", delay)
+
+	if err := r.sleep(ctx, delay); err != nil {
+		return fmt.Errorf("retry aborted: %w", err)
+	}
+	return nil
+}
+
+func (r *resilience) backoff(attempt int) time.Duration {
+	delay := float64(r.config.InitialBackoff) * math.Pow(r.config.BackoffMultiplier, float64(attempt-1))
+	if delay > float64(r.config.MaxBackoff) {
+		delay = float64(r.config.MaxBackoff)
+	}
+	return r.jitter(time.Duration(delay))
+}
+
+// ResilientDoer decorates an HTTPDoer with exponential backoff retries and a
+// circuit breaker. Transport errors, 408, 429 and 5xx responses are retried,
+// honoring Retry-After up to MaxBackoff and never sleeping past the request
+// context deadline.
+type ResilientDoer struct {
+	*resilience
+	next HTTPDoer
+}
+
+func NewResilientDoer(next HTTPDoer, config ResilienceConfig, registerer prometheus.Registerer) *ResilientDoer {
+	return &ResilientDoer{resilience: newResilience(config, registerer), next: next}
+}
+
+func (d *ResilientDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		if err := d.admit(ctx, attempt); err != nil {
+			return nil, err
+		}
+
+		attemptReq, err := requestForAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := d.next.Do(attemptReq)
+		reason, retryable := classifyAttempt(ctx, resp, err)
+		if !retryable {
+			// Cancellations say nothing about sidecar health.
+			d.breaker.record(err == nil, err != nil)
+			return resp, err
+		}
+		d.breaker.record(false, false)
+
+		delay := d.retryDelay(attempt, resp)
+		if !d.shouldRetry(ctx, attempt, delay) {
+			return resp, err
+		}
+		if resp != nil {
+			drainAndClose(resp)
+		}
+		if err := d.wait(ctx, attempt, reason, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay honors Retry-After up to MaxBackoff, and otherwise backs off
+// exponentially.
+func (d *ResilientDoer) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), d.now()); ok {
+			return min(retryAfter, d.config.MaxBackoff)
+		}
+	}
+	return d.backoff(attempt)
+}
+
+func requestForAttempt(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("publish request body cannot be replayed for retry")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("replay publish request body: %w", err)
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+func classifyAttempt(ctx context.Context, resp *http.Response, err error) (string, bool) {
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", false
+		}
+		return "transport_error", true
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "throttled", true
+	case resp.StatusCode >= 500 && isBulkPartialFailure(resp):
+		return "", false
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return "server_error", true
+	default:
+		return "", false
+	}
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func equalJitter(delay time.Duration) time.Duration {
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half_open"
+)
+
+// circuitBreaker opens after threshold consecutive failures, rejects calls
+// for openTimeout and then lets a single trial call through (half-open).
+type circuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+	stateGauge  *prometheus.GaugeVec
+
+	state         circuitState
+	failures      int
+	openedAt      time.Time
+	trialInFlight bool
+}
+
+func newCircuitBreaker(threshold int, openTimeout time.Duration, now func() time.Time, stateGauge *prometheus.GaugeVec) *circuitBreaker {
+	breaker := &circuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         now,
+		stateGauge:  stateGauge,
+	}
+	breaker.transition(circuitClosed)
+	return breaker
+}
+
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.transition(circuitHalfOpen)
+		b.trialInFlight = true
+		return true
+	case circuitHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record stores the outcome of an allowed call. Ignored outcomes only release
+// a half-open trial slot without changing the failure count.
+func (b *circuitBreaker) record(success, ignored bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialInFlight = false
+	if ignored {
+		return
+	}
+	if success {
+		b.failures = 0
+		if b.state != circuitClosed {
+			logger.Info("dapr publish circuit breaker closed")
+			b.transition(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state != circuitOpen {
+			logger.Warn("dapr publish circuit breaker opened", "consecutiveFailures", b.failures)
+		}
+		b.openedAt = b.now()
+		b.transition(circuitOpen)
+	}
+}
+
+func (b *circuitBreaker) currentState() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *circuitBreaker) transition(state circuitState) {
+	b.state = state
+	for _, candidate := range []circuitState{circuitClosed, circuitOpen, circuitHalfOpen} {
+		value := 0.0
+		if candidate == state {
+			value = 1
+		}
+		b.stateGauge.WithLabelValues(string(candidate)).Set(value)
+	}
+}
@@ -0,0 +1,284 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Sleep(_ context.Context, delay time.Duration) error {
+	f.sleeps = append(f.sleeps, delay)
+	f.now = f.now.Add(delay)
+	return nil
+}
+
+func newTestResilientDoer(t *testing.T, next HTTPDoer, config ResilienceConfig) (*ResilientDoer, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	doer := NewResilientDoer(next, config, prometheus.NewRegistry())
+	doer.now = clock.Now
+	doer.sleep = clock.Sleep
+	doer.jitter = func(delay time.Duration) time.Duration { return delay }
+	return doer, clock
+}
+
+func statusResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(bytes.NewBuffer(nil))}
+}
+
+func newPublishRequest(t *testing.T, ctx context.Context) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://dapr.local/publish", bytes.NewBufferString(`{"id":"ORD-1"}`))
+	if err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	return req
+}
+
+func TestResilientDoerRetries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		responses    []int
+		wantStatus   int
+		wantAttempts int
+		wantSleeps   []time.Duration
+	}{
+		{name: "success first try", responses: []int{204}, wantStatus: 204, wantAttempts: 1},
+		{name: "retries server errors", responses: []int{500, 503, 204}, wantStatus: 204, wantAttempts: 3, wantSleeps: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "retries throttling", responses: []int{429, 204}, wantStatus: 204, wantAttempts: 2, wantSleeps: []time.Duration{100 * time.Millisecond}},
+		{name: "gives up after max attempts", responses: []int{500, 500, 500, 500}, wantStatus: 500, wantAttempts: 3, wantSleeps: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "does not retry client errors", responses: []int{400, 204}, wantStatus: 400, wantAttempts: 1},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			doer, clock := newTestResilientDoer(t, doerFunc(func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != `{"id":"ORD-1"}` {
+					t.Errorf("attempt %d sent body %q", attempts+1, body)
+				}
+				status := tc.responses[attempts]
+				attempts++
+				return statusResponse(status), nil
+			}), ResilienceConfig{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, BackoffMultiplier: 2})
+
+			resp, err := doer.Do(newPublishRequest(t, context.Background()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+			if attempts != tc.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", attempts, tc.wantAttempts)
+			}
+			if len(clock.sleeps) != len(tc.wantSleeps) {
+				t.Fatalf("sleeps = %v, want %v", clock.sleeps, tc.wantSleeps)
+			}
+			for i := range tc.wantSleeps {
+				if clock.sleeps[i] != tc.wantSleeps[i] {
+					t.Fatalf("sleeps = %v, want %v", clock.sleeps, tc.wantSleeps)
+				}
+			}
+		})
+	}
+}
+
+func TestResilientDoerRetriesTransportErrors(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	doer, _ := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return statusResponse(http.StatusNoContent), nil
+	}), DefaultResilienceConfig())
+
+	resp, err := doer.Do(newPublishRequest(t, context.Background()))
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected recovery after transport error, got resp=%v err=%v", resp, err)
+	}
+	if got := testutil.ToFloat64(doer.retries.WithLabelValues("transport_error")); got != 1 {
+		t.Fatalf("transport_error retries = %v, want 1", got)
+	}
+}
+
+func TestResilientDoerHonorsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{name: "within max backoff", retryAfter: "2", want: 2 * time.Second},
+		{name: "clamped to max backoff", retryAfter: "3600", want: 5 * time.Second},
+		{name: "http date clamped to max backoff", retryAfter: "Thu, 01 Jan 2099 00:00:00 GMT", want: 5 * time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			config := DefaultResilienceConfig()
+			config.MaxBackoff = 5 * time.Second
+			doer, clock := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					resp := statusResponse(http.StatusServiceUnavailable)
+					resp.Header.Set("Retry-After", tc.retryAfter)
+					return resp, nil
+				}
+				return statusResponse(http.StatusNoContent), nil
+			}), config)
+
+			if _, err := doer.Do(newPublishRequest(t, context.Background())); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(clock.sleeps) != 1 || clock.sleeps[0] != tc.want {
+				t.Fatalf("expected Retry-After delay of %s, got %v", tc.want, clock.sleeps)
+			}
+		})
+	}
+}
+
+func TestResilientDoerStopsAtContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	doer, clock := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		attempts++
+		return statusResponse(http.StatusInternalServerError), nil
+	}), ResilienceConfig{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Second})
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(500*time.Millisecond))
+	defer cancel()
+
+	resp, err := doer.Do(newPublishRequest(t, ctx))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || attempts != 1 {
+		t.Fatalf("expected a single attempt returning 500, got status=%d attempts=%d", resp.StatusCode, attempts)
+	}
+	if len(clock.sleeps) != 0 {
+		t.Fatalf("expected no sleep past the deadline, got %v", clock.sleeps)
+	}
+}
+
+func TestResilientDoerCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	healthy := false
+	attempts := 0
+	doer, clock := newTestResilientDoer(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		attempts++
+		if healthy {
+			return statusResponse(http.StatusNoContent), nil
+		}
+		return nil, errors.New("connection refused")
+	}), ResilienceConfig{MaxAttempts: 1, BreakerFailureThreshold: 2, BreakerOpenTimeout: 10 * time.Second})
+
+	for i := 0; i < 2; i++ {
+		if _, err := doer.Do(newPublishRequest(t, context.Background())); err == nil {
+			t.Fatalf("expected failure on call %d", i+1)
+		}
+	}
+	if state := doer.breaker.currentState(); state != circuitOpen {
+		t.Fatalf("expected open breaker, got %s", state)
+	}
+
+	if _, err := doer.Do(newPublishRequest(t, context.Background())); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("open breaker must not call the sidecar, attempts=%d", attempts)
+	}
+	if got := testutil.ToFloat64(doer.circuitRejection); got != 1 {
+		t.Fatalf("circuit rejections = %v, want 1", got)
+	}
+
+	clock.now = clock.now.Add(11 * time.Second)
+	healthy = true
+	resp, err := doer.Do(newPublishRequest(t, context.Background()))
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected half-open trial to succeed, got resp=%v err=%v", resp, err)
+	}
+	if state := doer.breaker.currentState(); state != circuitClosed {
+		t.Fatalf("expected closed breaker after successful trial, got %s", state)
+	}
+	if got := testutil.ToFloat64(doer.breaker.stateGauge.WithLabelValues(string(circuitClosed))); got != 1 {
+		t.Fatalf("closed state gauge = %v, want 1", got)
+	}
+}
+
+func TestPublishReturnsServiceUnavailableWhenCircuitOpen(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	doer := NewResilientDoer(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), ResilienceConfig{MaxAttempts: 1, BreakerFailureThreshold: 1, BreakerOpenTimeout: time.Minute}, registry)
+	router := NewRouter(Config{}, NewService(doer, "http://dapr.local/publish"), registry, registry)
+
+	statuses := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		statuses = append(statuses, res.Code)
+	}
+
+	if statuses[0] != http.StatusBadGateway || statuses[1] != http.StatusServiceUnavailable {
+		t.Fatalf("expected 502 then 503, got %v", statuses)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "3", want: 3 * time.Second, wantOK: true},
+		{value: now.Add(5 * time.Second).Format(http.TimeFormat), want: 5 * time.Second, wantOK: true},
+		{value: "soon", wantOK: false},
+	}
+
+	for _, tc := range tests {
+		got, ok := parseRetryAfter(tc.value, now)
+		if ok != tc.wantOK || got != tc.want {
+			t.Fatalf("parseRetryAfter(%q) = %v,%v want %v,%v", tc.value, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
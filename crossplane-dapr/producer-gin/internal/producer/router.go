@@ -1,26 +1,42 @@
 package producer
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/health"
+	"github.com/agnostic/crossplane-dapr/common-gin/telemetry"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/propagation"
 )
 
-func NewRouter(cfg Config, service *Service, registerer prometheus.Registerer, gatherer prometheus.Gatherer) *gin.Engine {
+func NewRouter(cfg Config, publisher Publisher, registerer prometheus.Registerer, gatherer prometheus.Gatherer, opts ...Option) *gin.Engine {
 	if registerer == nil {
 		registerer = prometheus.DefaultRegisterer
 	}
 	if gatherer == nil {
 		gatherer = prometheus.DefaultGatherer
 	}
+	resolved := newOptions(opts)
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(otelgin.Middleware(serviceName,
+		otelgin.WithTracerProvider(resolved.tracerProvider),
+		otelgin.WithPropagators(resolved.propagator),
+		otelgin.WithFilter(telemetry.IsTracedRequest),
+	))
+	router.Use(accessLogMiddleware(accesslog.New(cfg.AccessLog)), gin.Recovery())
 
 	publishRequests := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "orders_publish_requests_total",
@@ -58,56 +74,196 @@ func NewRouter(cfg Config, service *Service, registerer prometheus.Registerer, g
 		).Observe(time.Since(start).Seconds())
 	})
 	router.Use(func(c *gin.Context) {
-		traceID, spanID := requestTraceContext(c.Request)
-		requestLogger := logger.With(
-			"trace_id", traceID,
-			"span_id", spanID,
-			"http_method", c.Request.Method,
-			"http_path", c.Request.URL.Path,
-		)
+		requestLogger := newRequestLogger(c.Request)
 		c.Set(requestLoggerKey, requestLogger)
 		c.Request = c.Request.WithContext(withRequestLogger(c.Request.Context(), requestLogger))
 		c.Next()
 	})
+	router.Use(correlationMiddleware)
 
-	router.GET("/health/live", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "UP"})
-	})
-	router.GET("/health/ready", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "UP"})
-	})
+	checks := resolved.health
+	if checks == nil {
+		checks = health.New()
+	}
+	router.GET("/health", gin.WrapH(checks.DetailHandler()))
+	router.GET("/health/live", gin.WrapH(checks.LiveHandler()))
+	router.GET("/health/ready", gin.WrapH(checks.ReadyHandler()))
+	router.GET("/health/started", gin.WrapH(checks.StartupHandler()))
 	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
 
-	router.POST("/publish", func(c *gin.Context) {
+	// Idempotency keys are scoped by route, so both publish endpoints share
+	// one middleware.
+	publishHandlers := []gin.HandlerFunc{}
+	if resolved.idempotency != nil {
+		publishHandlers = append(publishHandlers, newIdempotency(resolved.idempotency, resolved.idempotencyTTL, cfg.Idempotency.maxBodyBytes(), registerer).handle)
+	}
+	publishV2Handlers := append([]gin.HandlerFunc{}, publishHandlers...)
+	publishHandlers = append(publishHandlers, func(c *gin.Context) {
 		requestLogger := loggerFromGinContext(c)
 		publishRequests.Inc()
-		requestLogger.Debug("received publish request")
+		requestLogger.DebugContext(c.Request.Context(), "received publish request")
 
 		var req PublishOrderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			publishErrors.Inc()
-			requestLogger.Warn("invalid publish request payload", "error", err)
+			requestLogger.WarnContext(c.Request.Context(), "invalid publish request payload", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
 			return
 		}
-		if err := req.Validate(); err != nil {
+		if err := req.Validate(cfg.Money.maxScale()); err != nil {
 			publishErrors.Inc()
-			requestLogger.Warn("publish validation failed", "orderId", req.ID, "error", err)
+			requestLogger.WarnContext(c.Request.Context(), "publish validation failed", "orderId", req.ID, "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if err := service.Publish(c.Request.Context(), req); err != nil {
+		if resolved.outbox != nil {
+			acceptIntoOutbox(c, resolved, OutboxRecord{OrderID: req.ID, Request: req})
+			return
+		}
+
+		if err := publisher.Publish(c.Request.Context(), req); err != nil {
 			publishErrors.Inc()
-			requestLogger.Error("publish failed", "orderId", req.ID, "error", err)
-			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to publish event"})
+			respondPublishFailure(c, req.ID, err)
 			return
 		}
 
 		publishedEvents.Inc()
-		requestLogger.Info("published order event", "id", req.ID, "version", "v1", "pubsub", cfg.PubSubName, "topic", cfg.TopicName)
+		requestLogger.InfoContext(c.Request.Context(), "published order event", "id", req.ID, "version", "v1", "pubsub", cfg.PubSubName, "topic", cfg.TopicName)
 		c.JSON(http.StatusAccepted, gin.H{"status": "accepted", "orderId": req.ID})
 	})
+	router.POST("/publish", publishHandlers...)
+
+	publishV2Handlers = append(publishV2Handlers, func(c *gin.Context) {
+		requestLogger := loggerFromGinContext(c)
+		publishRequests.Inc()
+		requestLogger.DebugContext(c.Request.Context(), "received v2 publish request")
+
+		var req PublishOrderV2Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			publishErrors.Inc()
+			requestLogger.WarnContext(c.Request.Context(), "invalid publish request payload", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+			return
+		}
+		if err := req.Validate(cfg.Money.maxScale()); err != nil {
+			publishErrors.Inc()
+			requestLogger.WarnContext(c.Request.Context(), "publish validation failed", "orderId", req.ID, "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if resolved.outbox != nil {
+			acceptIntoOutbox(c, resolved, OutboxRecord{OrderID: req.ID, Request: PublishOrderRequest{ID: req.ID, Amount: req.Total()}, RequestV2: &req, EventVersions: cfg.EventVersions})
+			return
+		}
+
+		var published []string
+		for _, event := range cfg.EventVersions.events(req) {
+			if err := publisher.PublishEvent(c.Request.Context(), event); err != nil {
+				publishErrors.Inc()
+				if len(published) > 0 {
+					respondPartialPublish(c, req.ID, published, event.Version(), err)
+					return
+				}
+				respondPublishFailure(c, req.ID, err)
+				return
+			}
+			published = append(published, event.Version())
+			publishedEvents.Inc()
+			requestLogger.InfoContext(c.Request.Context(), "published order event", "id", req.ID, "version", event.Version(), "pubsub", cfg.PubSubName, "topic", cfg.TopicName)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"status": "accepted", "orderId": req.ID})
+	})
+	router.POST("/v2/publish", publishV2Handlers...)
+	router.POST("/publish/batch", newBatchPublisher(cfg.Batch, cfg.Money.maxScale(), publisher, resolved, publishedEvents, registerer).handle)
+
+	if resolved.outbox != nil {
+		router.GET("/publish/:orderId", func(c *gin.Context) {
+			requestLogger := loggerFromGinContext(c)
+			orderID := c.Param("orderId")
+
+			record, err := resolved.outbox.Get(c.Request.Context(), orderID)
+			if errors.Is(err, ErrOutboxRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+				return
+			}
+			if err != nil {
+				requestLogger.ErrorContext(c.Request.Context(), "failed to read outbox record", "orderId", orderID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read order status"})
+				return
+			}
+
+			response := gin.H{
+				"orderId":   record.OrderID,
+				"status":    record.Status,
+				"attempts":  record.Attempts,
+				"createdAt": record.CreatedAt,
+			}
+			if record.LastError != "" {
+				response["lastError"] = record.LastError
+			}
+			if record.PublishedAt != nil {
+				response["publishedAt"] = record.PublishedAt
+			}
+			c.JSON(http.StatusOK, response)
+		})
+	}
 
 	return router
 }
+
+// acceptIntoOutbox stores record, stamped with the request trace context,
+// correlation id and acceptance time, and answers 202.
+func acceptIntoOutbox(c *gin.Context, resolved options, record OutboxRecord) {
+	requestLogger := loggerFromGinContext(c)
+	ctx := c.Request.Context()
+
+	traceContext := propagation.MapCarrier{}
+	resolved.propagator.Inject(ctx, traceContext)
+	now := time.Now().UTC()
+	record.TraceContext = traceContext
+	record.CorrelationID = correlationIDFromContext(ctx)
+	record.CreatedAt = now
+	record.UpdatedAt = now
+
+	err := resolved.outbox.Enqueue(ctx, record)
+	if errors.Is(err, ErrOutboxDuplicate) {
+		requestLogger.WarnContext(ctx, "order already accepted into outbox", "orderId", record.OrderID)
+		c.JSON(http.StatusConflict, gin.H{"error": "order already accepted", "orderId": record.OrderID})
+		return
+	}
+	if err != nil {
+		requestLogger.ErrorContext(ctx, "failed to store order in outbox", "orderId", record.OrderID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept order"})
+		return
+	}
+
+	requestLogger.InfoContext(ctx, "accepted order into outbox", "id", record.OrderID)
+	c.JSON(http.StatusAccepted, gin.H{"status": "accepted", "orderId": record.OrderID})
+}
+
+func respondPublishFailure(c *gin.Context, orderID string, err error) {
+	requestLogger := loggerFromGinContext(c)
+	if errors.Is(err, ErrCircuitOpen) {
+		requestLogger.WarnContext(c.Request.Context(), "publish rejected while dapr is unavailable", "orderId", orderID)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event broker unavailable"})
+		return
+	}
+	requestLogger.ErrorContext(c.Request.Context(), "publish failed", "orderId", orderID, "error", err)
+	c.JSON(http.StatusBadGateway, gin.H{"error": "failed to publish event"})
+}
+
+// respondPartialPublish answers 207, which the idempotency middleware pins,
+// so a retry does not publish the earlier versions again.
+func respondPartialPublish(c *gin.Context, orderID string, published []string, failed string, err error) {
+	requestLogger := loggerFromGinContext(c)
+	requestLogger.ErrorContext(c.Request.Context(), "publish partially failed", "orderId", orderID, "published", published, "failedVersion", failed, "error", err)
+	c.JSON(http.StatusMultiStatus, gin.H{
+		"status":    "partial",
+		"orderId":   orderID,
+		"published": published,
+		"failed":    []string{failed},
+		"error":     "failed to publish event",
+	})
+}
@@ -3,9 +3,12 @@ package producer
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type HTTPDoer interface {
@@ -13,44 +16,97 @@ type HTTPDoer interface {
 }
 
 type Service struct {
-	httpClient HTTPDoer
-	publishURL string
+	httpClient     HTTPDoer
+	publishURL     string
+	bulkPublishURL string
+	tracer         trace.Tracer
+	propagator     propagation.TextMapPropagator
+	encoder        eventEncoder
 }
 
-func NewService(httpClient HTTPDoer, publishURL string) *Service {
-	return &Service{httpClient: httpClient, publishURL: publishURL}
+func NewService(httpClient HTTPDoer, publishURL string, opts ...Option) *Service {
+	resolved := newOptions(opts)
+	return &Service{
+		httpClient:     httpClient,
+		publishURL:     publishURL,
+		bulkPublishURL: resolved.bulkPublishURL,
+		tracer:         resolved.tracerProvider.Tracer(instrumentationName),
+		propagator:     resolved.propagator,
+		encoder:        newEventEncoder(resolved),
+	}
 }
 
 func (s *Service) Publish(ctx context.Context, request PublishOrderRequest) error {
+	return s.PublishEvent(ctx, request.Event())
+}
+
+func (s *Service) PublishEvent(ctx context.Context, event OrderEvent) error {
+	return tracePublish(ctx, s.tracer, event, s.publish)
+}
+
+func (s *Service) publish(ctx context.Context, event OrderEvent) error {
 	requestLogger := loggerFromContext(ctx)
+	orderID := event.OrderID()
 
-	event := OrderCreatedV1{ID: request.ID, Amount: request.Amount, EventVersion: "v1"}
-	payload, err := json.Marshal(event)
+	payload, contentType, err := s.encoder.encode(ctx, event)
 	if err != nil {
-		requestLogger.Error("failed to encode order event", "orderId", request.ID, "error", err)
-		return fmt.Errorf("encode event: %w", err)
+		requestLogger.ErrorContext(ctx, "failed to encode order event", "orderId", orderID, "error", err)
+		return err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.publishURL, bytes.NewBuffer(payload))
 	if err != nil {
-		requestLogger.Error("failed to create publish request", "orderId", request.ID, "error", err)
+		requestLogger.ErrorContext(ctx, "failed to create publish request", "orderId", orderID, "error", err)
 		return fmt.Errorf("create publish request: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	requestLogger.Debug("publishing order event", "orderId", request.ID, "url", s.publishURL)
+	httpReq.Header.Set("Content-Type", contentType)
+	s.injectTraceContext(ctx, httpReq)
+	setPublishMetadata(httpReq, s.encoder.metadata())
+	requestLogger.DebugContext(ctx, "publishing order event", "orderId", orderID, "version", event.Version(), "url", s.publishURL)
 
 	resp, err := s.httpClient.Do(httpReq)
 	if err != nil {
-		requestLogger.Error("publish request failed", "orderId", request.ID, "error", err)
+		requestLogger.ErrorContext(ctx, "publish request failed", "orderId", orderID, "error", err)
 		return fmt.Errorf("publish request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		requestLogger.Warn("publish endpoint returned non-2xx status", "orderId", request.ID, "statusCode", resp.StatusCode)
+		requestLogger.WarnContext(ctx, "publish endpoint returned non-2xx status", "orderId", orderID, "statusCode", resp.StatusCode)
 		return fmt.Errorf("publish endpoint returned status %d", resp.StatusCode)
 	}
 
-	requestLogger.Debug("publish request succeeded", "orderId", request.ID, "statusCode", resp.StatusCode)
+	requestLogger.DebugContext(ctx, "publish request succeeded", "orderId", orderID, "statusCode", resp.StatusCode)
 	return nil
 }
+
+// injectTraceContext also pins the trace context as the CloudEvent
+// traceparent/tracestate extensions, so consumers continue the trace.
+func (s *Service) injectTraceContext(ctx context.Context, httpReq *http.Request) {
+	s.propagator.Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	traceparent := httpReq.Header.Get("traceparent")
+	if traceparent == "" {
+		return
+	}
+	query := httpReq.URL.Query()
+	query.Set("metadata.cloudevent.traceparent", traceparent)
+	if tracestate := httpReq.Header.Get("tracestate"); tracestate != "" {
+		query.Set("metadata.cloudevent.tracestate", tracestate)
+	}
+	httpReq.URL.RawQuery = query.Encode()
+}
+
+// setPublishMetadata adds Dapr publish metadata as metadata.<key> query
+// parameters.
+func setPublishMetadata(httpReq *http.Request, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+	query := httpReq.URL.Query()
+	for key, value := range metadata {
+		query.Set("metadata."+key, value)
+	}
+	httpReq.URL.RawQuery = query.Encode()
+}
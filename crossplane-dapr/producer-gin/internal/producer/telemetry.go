@@ -0,0 +1,30 @@
+package producer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/telemetry"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	instrumentationName = "github.com/agnostic/crossplane-dapr/producer-gin/internal/producer"
+	defaultServiceName  = "producer-gin"
+)
+
+// SetupTelemetry installs the global OpenTelemetry tracer provider and
+// propagator, see telemetry.Setup; a blank serviceName selects producer-gin.
+// The returned function flushes pending spans and must be called on shutdown.
+func SetupTelemetry(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if strings.TrimSpace(serviceName) == "" {
+		serviceName = defaultServiceName
+	}
+	return telemetry.Setup(ctx, serviceName, logger)
+}
+
+// NewPropagator returns the W3C and B3 propagator shared by the Gin services,
+// see telemetry.NewPropagator.
+func NewPropagator() propagation.TextMapPropagator {
+	return telemetry.NewPropagator()
+}
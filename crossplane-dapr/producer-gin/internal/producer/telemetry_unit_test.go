@@ -0,0 +1,329 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnostic/crossplane-dapr/common-gin/accesslog"
+	"github.com/agnostic/crossplane-dapr/common-gin/logging"
+	"github.com/agnostic/crossplane-dapr/common-gin/money"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedRouter(t *testing.T, doer HTTPDoer) (http.Handler, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tracerProvider.Shutdown(context.Background()) })
+
+	opts := []Option{WithTracerProvider(tracerProvider), WithPropagator(NewPropagator())}
+	service := NewService(doer, "http://dapr.local/publish", opts...)
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{PubSubName: "order-pubsub", TopicName: "orders"}, service, registry, registry, opts...)
+	return router, exporter
+}
+
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not recorded, got %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func TestPublishRecordsServerAndProducerSpans(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", res.Code)
+	}
+
+	spans := exporter.GetSpans()
+	server := spanByName(t, spans, "POST /publish")
+	publish := spanByName(t, spans, "orders.publish")
+
+	if server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("expected server span kind, got %v", server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("server span did not continue incoming trace, got %s", got)
+	}
+	if publish.SpanKind != trace.SpanKindProducer {
+		t.Fatalf("expected producer span kind, got %v", publish.SpanKind)
+	}
+	if publish.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("expected producer span to be a child of the server span")
+	}
+	if publish.Status.Code != codes.Ok {
+		t.Fatalf("expected ok status, got %v", publish.Status)
+	}
+}
+
+func TestPublishForwardsTraceContextToDapr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		traceID string
+	}{
+		{
+			name:    "w3c traceparent",
+			headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "b3 multi header",
+			headers: map[string]string{
+				"x-b3-traceid": "463ac35c9f6413ad48485a3953bb6124",
+				"x-b3-spanid":  "a2fb4a1d1a96d312",
+				"x-b3-sampled": "1",
+			},
+			traceID: "463ac35c9f6413ad48485a3953bb6124",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var daprReq *http.Request
+			router, exporter := newTracedRouter(t, doerFunc(func(req *http.Request) (*http.Response, error) {
+				daprReq = req
+				return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-3","amount":10}`))
+			req.Header.Set("Content-Type", "application/json")
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+
+			if res.Code != http.StatusAccepted {
+				t.Fatalf("expected 202, got %d", res.Code)
+			}
+			if daprReq == nil {
+				t.Fatal("expected a publish request to the sidecar")
+			}
+
+			publish := spanByName(t, exporter.GetSpans(), "orders.publish")
+			wantPrefix := "00-" + tc.traceID + "-" + publish.SpanContext.SpanID().String()
+			traceparent := daprReq.Header.Get("traceparent")
+			if !strings.HasPrefix(traceparent, wantPrefix) {
+				t.Fatalf("traceparent = %q, want prefix %q", traceparent, wantPrefix)
+			}
+			if got := daprReq.URL.Query().Get("metadata.cloudevent.traceparent"); got != traceparent {
+				t.Fatalf("cloudevent traceparent = %q, want %q", got, traceparent)
+			}
+		})
+	}
+}
+
+func TestPublishSpanRecordsFailures(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-2","amount":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", res.Code)
+	}
+
+	publish := spanByName(t, exporter.GetSpans(), "orders.publish")
+	if publish.Status.Code != codes.Error {
+		t.Fatalf("expected error status, got %v", publish.Status)
+	}
+	if len(publish.Events) == 0 || publish.Events[0].Name != "exception" {
+		t.Fatalf("expected recorded exception event, got %+v", publish.Events)
+	}
+}
+
+func TestProbeRequestsAreNotTraced(t *testing.T) {
+	t.Parallel()
+
+	router, exporter := newTracedRouter(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("unexpected publish")
+	}))
+
+	for _, path := range []string{"/health", "/health/live", "/health/ready", "/health/started", "/metrics"} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("expected no spans for probe traffic, got %d", len(spans))
+	}
+}
+
+// captureLogs points the package logger at a JSON buffer for the rest of the
+// test and returns a function that decodes the records written so far.
+// Tests using it must not run in parallel.
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	previous := logger
+	logger = logging.New(&out, logging.Config{Level: slog.LevelInfo, Format: logging.FormatJSON})
+	t.Cleanup(func() { logger = previous })
+	return func() []map[string]any {
+		t.Helper()
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("decode log line %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func recordsWithMessage(records []map[string]any, msg string) []map[string]any {
+	var matching []map[string]any
+	for _, record := range records {
+		if record["msg"] == msg {
+			matching = append(matching, record)
+		}
+	}
+	return matching
+}
+
+func TestRequestLogsCarryTheServerSpanContext(t *testing.T) {
+	records := captureLogs(t)
+	router, exporter := newTracedRouter(t, doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{`)))
+
+	server := spanByName(t, exporter.GetSpans(), "POST /publish")
+	published := recordsWithMessage(records(), "published order event")
+	if len(published) != 1 || published[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		published[0]["span_id"] != server.SpanContext.SpanID().String() {
+		t.Fatalf("published records = %v, want the server span context", published)
+	}
+	rejected := recordsWithMessage(records(), "invalid publish request payload")
+	if len(rejected) != 1 || rejected[0]["trace_id"] == "4bf92f3577b34da6a3ce929d0e0e4736" || rejected[0]["trace_id"] == nil {
+		t.Fatalf("rejected records = %v, want a trace of its own", rejected)
+	}
+}
+
+func TestOutboxRelayLogsCarryTheAcceptedTraceContext(t *testing.T) {
+	records := captureLogs(t)
+	store := openTestOutbox(t)
+	record := OutboxRecord{
+		OrderID:      "ORD-1",
+		Request:      PublishOrderRequest{ID: "ORD-1", Amount: money.MustParse("10")},
+		TraceContext: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		CreatedAt:    time.Now(),
+	}
+	if err := store.Enqueue(context.Background(), record); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	relay := NewOutboxRelay(store, service, OutboxConfig{}, prometheus.NewRegistry(), WithPropagator(NewPropagator()))
+
+	if got := relay.Drain(context.Background()); got != 1 {
+		t.Fatalf("relayed %d records, want 1", got)
+	}
+	relayed := recordsWithMessage(records(), "relayed outbox order event")
+	if len(relayed) != 1 || relayed[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || relayed[0]["span_id"] != "00f067aa0ba902b7" {
+		t.Fatalf("relayed records = %v, want the accepted trace context", relayed)
+	}
+}
+
+func TestAccessLogRecordsRequests(t *testing.T) {
+	records := captureLogs(t)
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tracerProvider.Shutdown(context.Background()) })
+	opts := []Option{WithTracerProvider(tracerProvider), WithPropagator(NewPropagator())}
+	service := NewService(doerFunc(func(_ *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish", opts...)
+	registry := prometheus.NewRegistry()
+	cfg := Config{AccessLog: accesslog.Config{Headers: []string{"Authorization"}, Body: true, RedactFields: []string{"id"}}}
+	router := NewRouter(cfg, service, registry, registry, opts...)
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", bytes.NewBufferString(`{"id":"ORD-1","amount":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.RemoteAddr = "10.0.0.7:51234"
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("POST /publish = %d, want 202", res.Code)
+	}
+	for _, path := range []string{"/health/live", "/health/ready", "/metrics"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	access := recordsWithMessage(records(), "http request")
+	if len(access) != 2 {
+		t.Fatalf("logged %d access records, want 2 with probes sampled away: %v", len(access), access)
+	}
+	server := spanByName(t, exporter.GetSpans(), "POST /publish")
+	published := access[0]
+	if published["level"] != "INFO" || published["status"] != 202.0 || published["route"] != "/publish" ||
+		published["http_method"] != "POST" || published["clientIp"] != "10.0.0.7" || published["bytes"] != float64(res.Body.Len()) ||
+		published["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || published["span_id"] != server.SpanContext.SpanID().String() {
+		t.Fatalf("unexpected access record %v", published)
+	}
+	if _, ok := published["latencyMs"].(float64); !ok {
+		t.Fatalf("access record has no latencyMs: %v", published)
+	}
+	if headers, _ := published["headers"].(map[string]any); headers["Authorization"] != accesslog.Redacted {
+		t.Fatalf("headers = %v, want Authorization redacted", published["headers"])
+	}
+	if published["body"] != `{"amount":10,"id":"[REDACTED]"}` {
+		t.Fatalf("body = %v, want the id redacted", published["body"])
+	}
+	if missing := access[1]; missing["level"] != "WARN" || missing["status"] != 404.0 || missing["route"] != "" || missing["http_path"] != "/unknown" {
+		t.Fatalf("unexpected access record %v", missing)
+	}
+}
@@ -0,0 +1,41 @@
+package producer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventVersions selects the event versions published for orders received on
+// POST /v2/publish while subscribers migrate from OrderCreatedV1 to
+// OrderCreatedV2. POST /publish always publishes OrderCreatedV1.
+type EventVersions string
+
+const (
+	EventVersionsV1   EventVersions = "v1"
+	EventVersionsV2   EventVersions = "v2"
+	EventVersionsBoth EventVersions = "both"
+)
+
+// ParseEventVersions parses a PUBLISH_EVENT_VERSIONS value.
+func ParseEventVersions(value string) (EventVersions, error) {
+	switch versions := EventVersions(strings.ToLower(strings.TrimSpace(value))); versions {
+	case EventVersionsV1, EventVersionsV2, EventVersionsBoth:
+		return versions, nil
+	case "":
+		return EventVersionsV1, nil
+	default:
+		return "", fmt.Errorf("unsupported event versions %q", value)
+	}
+}
+
+// events returns the events to publish for request, V1 first.
+func (v EventVersions) events(request PublishOrderV2Request) []OrderEvent {
+	switch v {
+	case EventVersionsV2:
+		return []OrderEvent{request.EventV2()}
+	case EventVersionsBoth:
+		return []OrderEvent{request.EventV1(), request.EventV2()}
+	default:
+		return []OrderEvent{request.EventV1()}
+	}
+}
@@ -0,0 +1,194 @@
+//go:build !integration && !contract && !e2e
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const publishV2Body = `{"id":"ORD-1","currency":"EUR","customerId":"CUST-1","lineItems":[{"sku":"SKU-1","quantity":2,"unitPrice":5},{"sku":"SKU-2","quantity":1,"unitPrice":2.5}],"createdAt":"2026-03-01T12:00:00Z"}`
+
+func TestParseEventVersions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    EventVersions
+		wantErr bool
+	}{
+		{value: "", want: EventVersionsV1},
+		{value: "v1", want: EventVersionsV1},
+		{value: " V2 ", want: EventVersionsV2},
+		{value: "both", want: EventVersionsBoth},
+		{value: "v3", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseEventVersions(tc.value)
+		if tc.wantErr != (err != nil) || got != tc.want {
+			t.Fatalf("ParseEventVersions(%q) = %q, %v; want %q", tc.value, got, err, tc.want)
+		}
+	}
+}
+
+func decodeCapturedEvents(t *testing.T, captured []capturedPublish) []map[string]any {
+	t.Helper()
+	events := make([]map[string]any, len(captured))
+	for i, publish := range captured {
+		if err := json.Unmarshal(publish.body, &events[i]); err != nil {
+			t.Fatalf("decode cloud event %d: %v", i, err)
+		}
+	}
+	return events
+}
+
+func TestPublishV2HonorsEventVersions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		versions  EventVersions
+		wantTypes []string
+	}{
+		{versions: EventVersionsV1, wantTypes: []string{OrderCreatedV1Type}},
+		{versions: EventVersionsV2, wantTypes: []string{OrderCreatedV2Type}},
+		{versions: EventVersionsBoth, wantTypes: []string{OrderCreatedV1Type, OrderCreatedV2Type}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(string(tc.versions), func(t *testing.T) {
+			t.Parallel()
+
+			var captured []capturedPublish
+			registry := prometheus.NewRegistry()
+			router := NewRouter(Config{EventVersions: tc.versions}, newCapturingService(&captured), registry, registry)
+
+			req := httptest.NewRequest(http.MethodPost, "/v2/publish", bytes.NewBufferString(publishV2Body))
+			req.Header.Set("Content-Type", "application/json")
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+			if res.Code != http.StatusAccepted {
+				t.Fatalf("expected 202, got %d: %s", res.Code, res.Body.String())
+			}
+
+			events := decodeCapturedEvents(t, captured)
+			if len(events) != len(tc.wantTypes) {
+				t.Fatalf("expected %d events, got %d", len(tc.wantTypes), len(events))
+			}
+			for i, event := range events {
+				if event["type"] != tc.wantTypes[i] {
+					t.Fatalf("event %d type = %v, want %s", i, event["type"], tc.wantTypes[i])
+				}
+				data, _ := event["data"].(map[string]any)
+				if data["amount"] != 12.5 {
+					t.Fatalf("event %d amount = %v, want the order total", i, data["amount"])
+				}
+				if event["type"] == OrderCreatedV2Type && (data["eventVersion"] != "v2" || data["currency"] != "EUR" || data["customerId"] != "CUST-1") {
+					t.Fatalf("unexpected v2 data: %v", data)
+				}
+				if event["type"] == OrderCreatedV1Type && (data["eventVersion"] != "v1" || data["currency"] != nil) {
+					t.Fatalf("unexpected v1 data: %v", data)
+				}
+			}
+			if got := counterValue(t, registry, "orders_published_total"); got != float64(len(tc.wantTypes)) {
+				t.Fatalf("published events = %v, want %d", got, len(tc.wantTypes))
+			}
+		})
+	}
+}
+
+func TestPublishV2ReportsPartialPublish(t *testing.T) {
+	t.Parallel()
+
+	var types []string
+	service := NewService(doerFunc(func(req *http.Request) (*http.Response, error) {
+		var event map[string]any
+		_ = json.NewDecoder(req.Body).Decode(&event)
+		eventType, _ := event["type"].(string)
+		types = append(types, eventType)
+		status := http.StatusNoContent
+		if eventType == OrderCreatedV2Type {
+			status = http.StatusInternalServerError
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBuffer(nil))}, nil
+	}), "http://dapr.local/publish")
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{EventVersions: EventVersionsBoth}, service, registry, registry, WithIdempotency(NewMemoryIdempotencyStore(), time.Hour))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v2/publish", bytes.NewBufferString(publishV2Body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "key-1")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send()
+	want := `{"error":"failed to publish event","failed":["v2"],"orderId":"ORD-1","published":["v1"],"status":"partial"}`
+	if res.Code != http.StatusMultiStatus || res.Body.String() != want {
+		t.Fatalf("expected 207 %s, got %d %s", want, res.Code, res.Body.String())
+	}
+
+	// The retry replays the partial result instead of publishing v1 again.
+	retry := send()
+	if retry.Code != http.StatusMultiStatus || retry.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Fatalf("expected the replayed 207, got %d %s", retry.Code, retry.Body.String())
+	}
+	if len(types) != 2 || types[0] != OrderCreatedV1Type || types[1] != OrderCreatedV2Type {
+		t.Fatalf("published %v, want one v1 and one v2 attempt", types)
+	}
+}
+
+func TestPublishV2RejectsInvalidOrder(t *testing.T) {
+	t.Parallel()
+
+	var captured []capturedPublish
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{}, newCapturingService(&captured), registry, registry)
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/publish", bytes.NewBufferString(`{"id":"ORD-1","currency":"EUR","customerId":"CUST-1","lineItems":[],"createdAt":"2026-03-01T12:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusBadRequest || len(captured) != 0 {
+		t.Fatalf("expected 400 without publishing, got %d and %d publishes", res.Code, len(captured))
+	}
+}
+
+func TestOutboxRelayPublishesV2Record(t *testing.T) {
+	t.Parallel()
+
+	store := openTestOutbox(t)
+	registry := prometheus.NewRegistry()
+	router := NewRouter(Config{EventVersions: EventVersionsBoth}, newCapturingService(new([]capturedPublish)), registry, registry, WithOutbox(store))
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/publish", bytes.NewBufferString(publishV2Body))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", res.Code)
+	}
+
+	var captured []capturedPublish
+	relay := NewOutboxRelay(store, newCapturingService(&captured), OutboxConfig{}, prometheus.NewRegistry())
+	relay.Drain(context.Background())
+
+	events := decodeCapturedEvents(t, captured)
+	if len(events) != 2 || events[0]["type"] != OrderCreatedV1Type || events[1]["type"] != OrderCreatedV2Type {
+		t.Fatalf("expected relayed v1 and v2 events, got %v", events)
+	}
+	if record, err := store.Get(context.Background(), "ORD-1"); err != nil || record.Status != OutboxStatusPublished {
+		t.Fatalf("expected published outbox record, got %+v %v", record, err)
+	}
+}
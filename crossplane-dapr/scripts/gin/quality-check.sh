@@ -5,7 +5,7 @@ usage() {
   cat <<USAGE
 Usage: ./scripts/gin/quality-check.sh
 
-Runs Gin stack quality checks for producer-gin + consumer-gin:
+Runs Gin stack quality checks for producer-gin + consumer-gin + common-gin:
   1) gofmt check (fails if files are not formatted)
   2) go vet
   3) unit tests
@@ -26,7 +26,7 @@ ROOT_DIR="$(cd "${SCRIPT_DIR}/../.." && pwd)"
 GOCACHE="${GOCACHE:-/tmp/go-build}"
 GOMODCACHE="${GOMODCACHE:-/tmp/go-mod}"
 
-GO_FILES="$(find "${ROOT_DIR}/producer-gin" "${ROOT_DIR}/consumer-gin" -type f -name '*.go' | sort)"
+GO_FILES="$(find "${ROOT_DIR}/producer-gin" "${ROOT_DIR}/consumer-gin" "${ROOT_DIR}/common-gin" -type f -name '*.go' | sort)"
 if [[ -z "${GO_FILES}" ]]; then
   echo "No Go files found under producer-gin/consumer-gin."
   exit 1
@@ -54,7 +54,9 @@ run_module_unit_test() {
 
 run_module_vet "producer-gin"
 run_module_vet "consumer-gin"
+run_module_vet "common-gin"
 run_module_unit_test "producer-gin"
 run_module_unit_test "consumer-gin"
+run_module_unit_test "common-gin"
 
 echo "Gin quality checks passed."
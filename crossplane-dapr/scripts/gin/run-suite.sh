@@ -6,7 +6,7 @@ usage() {
 Usage: ./scripts/gin/run-suite.sh <suite>
 
 Suites:
-  unit         Run unit tests for producer-gin + consumer-gin
+  unit         Run unit tests for producer-gin + consumer-gin + common-gin
   integration  Run integration tests for producer-gin + consumer-gin
   contract     Run contract tests for producer-gin + consumer-gin
   e2e          Run e2e tests for producer-gin + consumer-gin
@@ -72,3 +72,8 @@ else
   echo "Running ${suite} tests for consumer-gin"
   run_module "consumer-gin"
 fi
+
+if [[ "${suite}" == "unit" ]]; then
+  echo "Running ${suite} tests for common-gin"
+  run_module "common-gin"
+fi